@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+func newCacheCommand(a *app) *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local plugin cache",
+		Long:  `Manage the data wpcli keeps in its cache directory`,
+	}
+
+	cacheCmd.AddCommand(newCachePruneCommand(a), newCacheClearCommand(a))
+	return cacheCmd
+}
+
+func newCacheClearCommand(a *app) *cobra.Command {
+	var compiled bool
+
+	cmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove cached data wpcli can regenerate",
+		Long: `Remove the data wpcli caches to run faster: the compiled plugin modules
+and the plugin commands parsed from the repository. The repository clone is
+kept. Use --compiled to only remove the compiled plugin modules.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+
+			var removed []string
+			cleared, err := clearDir(dirs.CompiledDir())
+			if err != nil {
+				return fmt.Errorf("failed to clear the compiled module cache: %w", err)
+			}
+			if cleared {
+				removed = append(removed, dirs.CompiledDir())
+			}
+
+			if !compiled {
+				files, err := dirs.CommandCacheFiles()
+				if err != nil {
+					return fmt.Errorf("failed to clear the command cache: %w", err)
+				}
+				for _, file := range files {
+					if err := os.Remove(file); err != nil {
+						return fmt.Errorf("failed to clear the command cache: %w", err)
+					}
+					removed = append(removed, file)
+				}
+			}
+
+			if len(removed) == 0 {
+				fmt.Fprintln(out, "Nothing to clear")
+			}
+			for _, path := range removed {
+				fmt.Fprintf(out, "Removed %s\n", path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&compiled, "compiled", false, "Only remove the compiled plugin modules")
+	return cmd
+}
+
+// clearDir removes a cache directory, reporting whether it existed
+func clearDir(dir string) (bool, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func newCachePruneCommand(a *app) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove plugin directories no longer in the catalog",
+		Long: `Remove plugin and version directories of the local repository clone that are
+no longer referenced by plugins.yml, and the compiled plugin modules of wasm
+runtime versions other than the one of this wpcli. Only directories that are
+not tracked by git are removed, and local repositories are left untouched.
+Use --dry-run to list what would be deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			// Local repositories are not managed by wpcli, but the compiled
+			// modules in the cache directory are still pruned
+			var orphans []string
+			if repoManager.IsLocal() {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipping the plugin directories of the local repository %s\n", repoManager.GetRepoPath())
+			} else {
+				orphans, err = a.pruneOrphans(repoManager, dryRun)
+				if err != nil {
+					return err
+				}
+			}
+
+			shards, err := a.pruneCompiledShards(dryRun)
+			if err != nil {
+				return err
+			}
+			pruned := append(orphans, shards...)
+
+			if len(pruned) == 0 {
+				fmt.Fprintln(out, "Nothing to prune")
+				return nil
+			}
+
+			for _, path := range pruned {
+				if dryRun {
+					fmt.Fprintf(out, "Would remove %s\n", path)
+				} else {
+					fmt.Fprintf(out, "Removed %s\n", path)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the directories that would be removed without deleting them")
+	return cmd
+}
+
+// pruneCompiledShards finds, and unless dryRun is set removes, the compiled
+// modules of other runtime versions, which this wpcli never loads
+func (a *app) pruneCompiledShards(dryRun bool) ([]string, error) {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, err
+	}
+
+	stale, err := dirs.StaleCompiledShards(runtime.Version())
+	if err != nil || dryRun {
+		return stale, err
+	}
+
+	for _, shard := range stale {
+		if err := os.RemoveAll(shard); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", shard, err)
+		}
+	}
+	return stale, nil
+}
+
+// pruneOrphans finds, and unless dryRun is set removes, the untracked plugin
+// directories of the local clone that the catalog no longer references
+func (a *app) pruneOrphans(repoManager *git.RepoManager, dryRun bool) ([]string, error) {
+	if repoManager.IsLocal() {
+		return nil, fmt.Errorf("refusing to prune a local repository outside the cache directory")
+	}
+
+	configManager := a.newConfigManager(repoManager.GetRepoPath())
+	if err := configManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load plugins configuration: %w", err)
+	}
+
+	candidates, err := plugins.FindOrphans(repoManager.GetRepoPath(), configManager.GetPlugins())
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, candidate := range candidates {
+		tracked, err := repoManager.IsTracked(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !tracked {
+			orphans = append(orphans, candidate)
+		}
+	}
+
+	if dryRun {
+		return orphans, nil
+	}
+
+	if err := plugins.RemoveOrphans(repoManager.GetRepoPath(), orphans); err != nil {
+		return nil, err
+	}
+
+	return orphans, nil
+}
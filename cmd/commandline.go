@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// commandLine is the command line parsed ahead of cobra. Plugin commands are
+// built, and logging is set up, before cobra parses the flags, while which
+// commands are built depends on the flags and the command given, so the
+// flags wpcli declares are parsed here first, into copies of them that leave
+// the ones cobra parses untouched. Flags wpcli does not declare, such as the
+// flags of plugin commands, are taken as boolean flags. A value that does not
+// parse ends the parsing, and is reported by cobra.
+type commandLine struct {
+	flags *pflag.FlagSet
+	// positional holds the arguments that are not flags or flag values
+	positional []string
+}
+
+// parseCommandLine parses args with copies of the flags of flagSets
+func parseCommandLine(args []string, flagSets ...*pflag.FlagSet) *commandLine {
+	parsed := pflag.NewFlagSet("wpcli", pflag.ContinueOnError)
+	parsed.Usage = func() {}
+	parsed.SetOutput(io.Discard)
+	parsed.ParseErrorsWhitelist.UnknownFlags = true
+	for _, flagSet := range flagSets {
+		flagSet.VisitAll(func(flag *pflag.Flag) {
+			if parsed.Lookup(flag.Name) == nil {
+				copyFlag(parsed, flag)
+			}
+		})
+	}
+	declareUnknownFlags(parsed, args)
+
+	// Parse errors leave the arguments parsed so far
+	_ = parsed.Parse(args)
+	return &commandLine{flags: parsed, positional: parsed.Args()}
+}
+
+// copyFlag declares in flagSet a flag of the same name, shorthand and type
+// as flag, with a value of its own
+func copyFlag(flagSet *pflag.FlagSet, flag *pflag.Flag) {
+	switch flag.Value.Type() {
+	case "bool":
+		flagSet.BoolP(flag.Name, flag.Shorthand, false, "")
+	case "count":
+		flagSet.CountP(flag.Name, flag.Shorthand, "")
+	case "stringArray":
+		flagSet.StringArrayP(flag.Name, flag.Shorthand, nil, "")
+	default:
+		// Values of other types are kept as they are given
+		flagSet.StringP(flag.Name, flag.Shorthand, flag.DefValue, "")
+	}
+	flagSet.Lookup(flag.Name).NoOptDefVal = flag.NoOptDefVal
+}
+
+// declareUnknownFlags declares the flags of args missing from flagSet as
+// flags taking no value, which accept one given with =
+func declareUnknownFlags(flagSet *pflag.FlagSet, args []string) {
+	declare := func(name, shorthand string) {
+		flagSet.StringP(name, shorthand, "", "")
+		flagSet.Lookup(name).NoOptDefVal = "true"
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			return
+		}
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, _, _ = strings.Cut(name, "=")
+			if name != "" && flagSet.Lookup(name) == nil {
+				declare(name, "")
+			}
+			continue
+		}
+		shorthands, ok := strings.CutPrefix(arg, "-")
+		if !ok {
+			continue
+		}
+		shorthands, _, _ = strings.Cut(shorthands, "=")
+		for _, shorthand := range shorthands {
+			// pflag only takes ASCII shorthands, and skips other unknown flags
+			if shorthand < 0x80 && flagSet.ShorthandLookup(string(shorthand)) == nil {
+				declare("-"+string(shorthand), string(shorthand))
+			}
+		}
+	}
+}
+
+// enabled reports whether a boolean flag is given and true
+func (c *commandLine) enabled(name string) bool {
+	enabled, err := c.flags.GetBool(name)
+	return err == nil && enabled
+}
+
+// value returns the value of a flag, or its default when it is not given
+func (c *commandLine) value(name string) string {
+	if flag := c.flags.Lookup(name); flag != nil {
+		return flag.Value.String()
+	}
+	return ""
+}
+
+// values returns every value of a repeatable flag
+func (c *commandLine) values(name string) []string {
+	values, _ := c.flags.GetStringArray(name)
+	return values
+}
+
+// count returns the value of a count flag
+func (c *commandLine) count(name string) int {
+	count, _ := c.flags.GetCount(name)
+	return count
+}
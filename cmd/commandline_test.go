@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	persistent := pflag.NewFlagSet("wpcli", pflag.ContinueOnError)
+	persistent.Bool("offline", false, "")
+	persistent.String("lang", "", "")
+	persistent.Duration("timeout", 0, "")
+	persistent.StringArray("plugin-dir", nil, "")
+	persistent.Count("verbose", "")
+
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		offline    bool
+		lang       string
+		pluginDirs []string
+		verbose    int
+	}{
+		{name: "no flags", args: []string{"list"}, positional: []string{"list"}},
+		{name: "boolean", args: []string{"--offline", "list"}, positional: []string{"list"}, offline: true},
+		{name: "explicit boolean", args: []string{"list", "--offline=false"}, positional: []string{"list"}},
+		{name: "value", args: []string{"--lang", "it", "greet"}, positional: []string{"greet"}, lang: "it"},
+		{name: "value with =", args: []string{"greet", "--lang=it"}, positional: []string{"greet"}, lang: "it"},
+		{name: "other types", args: []string{"--timeout", "5s", "run", "pkg"}, positional: []string{"run", "pkg"}},
+		{name: "repeated", args: []string{"--plugin-dir", "a", "--plugin-dir=b", "list"}, positional: []string{"list"}, pluginDirs: []string{"a", "b"}},
+		{name: "count", args: []string{"--verbose", "list", "--verbose"}, positional: []string{"list"}, verbose: 2},
+		{name: "explicit count", args: []string{"--verbose=3", "list"}, positional: []string{"list"}, verbose: 3},
+		// Flags of plugin commands take no value here
+		{name: "unknown flags", args: []string{"greet", "--formal", "Maria", "-xy", "--name=Ada"}, positional: []string{"greet", "Maria"}},
+		{name: "terminator", args: []string{"greet", "--", "--offline", "-x"}, positional: []string{"greet", "--offline", "-x"}},
+		{name: "invalid value", args: []string{"--verbose", "--offline=maybe", "--lang", "it"}, verbose: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commandLine := parseCommandLine(test.args, persistent)
+			if !slices.Equal(commandLine.positional, test.positional) {
+				t.Errorf("positional = %q, want %q", commandLine.positional, test.positional)
+			}
+			if got := commandLine.enabled("offline"); got != test.offline {
+				t.Errorf("offline = %v, want %v", got, test.offline)
+			}
+			if got := commandLine.value("lang"); got != test.lang {
+				t.Errorf("lang = %q, want %q", got, test.lang)
+			}
+			if got := commandLine.values("plugin-dir"); !slices.Equal(got, test.pluginDirs) {
+				t.Errorf("plugin-dir = %q, want %q", got, test.pluginDirs)
+			}
+			if got := commandLine.count("verbose"); got != test.verbose {
+				t.Errorf("verbose = %d, want %d", got, test.verbose)
+			}
+		})
+	}
+
+	// The flags are parsed into copies
+	parseCommandLine([]string{"--offline", "--plugin-dir", "a"}, persistent)
+	if persistent.Changed("offline") || persistent.Changed("plugin-dir") {
+		t.Error("parsing the command line set the original flags")
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/paths"
+	"github.com/ploffredi/wpcli/internal/plugins"
+)
+
+// Dependencies are the services the commands rely on. Fields left empty are
+// replaced with the defaults used by the wpcli binary, so tests only need to
+// set what they want to replace.
+type Dependencies struct {
+	// Paths resolves the cache and config directories
+	Paths func() (*paths.Paths, error)
+	// Settings loads the effective settings stored in the given directories
+	Settings func(dirs *paths.Paths) (*plugins.Settings, error)
+	// Repository creates the manager of the configured plugin repository
+	Repository func(dirs *paths.Paths, settings *plugins.Settings) *git.RepoManager
+	// Now returns the current time
+	Now func() time.Time
+	// Getenv looks up environment variables
+	Getenv func(key string) string
+	// Args are the command line arguments, without the program name
+	Args []string
+	// Stdin is the input of plugin commands
+	Stdin io.Reader
+	// Stdout and Stderr receive the command output
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// withDefaults returns a copy of the dependencies with every empty field set
+func (d Dependencies) withDefaults() Dependencies {
+	if d.Paths == nil {
+		d.Paths = paths.Resolve
+	}
+	if d.Settings == nil {
+		d.Settings = loadSettings
+	}
+	if d.Repository == nil {
+		d.Repository = newRepoManager
+	}
+	if d.Now == nil {
+		d.Now = time.Now
+	}
+	if d.Getenv == nil {
+		d.Getenv = os.Getenv
+	}
+	if d.Args == nil {
+		d.Args = os.Args[1:]
+	}
+	if d.Stdin == nil {
+		d.Stdin = os.Stdin
+	}
+	if d.Stdout == nil {
+		d.Stdout = os.Stdout
+	}
+	if d.Stderr == nil {
+		d.Stderr = os.Stderr
+	}
+	return d
+}
@@ -0,0 +1,434 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/version"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// documentedPlugin is a plugin of the Markdown documentation, with the
+// commands built from its selected version
+type documentedPlugin struct {
+	name        string
+	uuid        string
+	description i18n.Text
+	version     string
+	commands    []*cobra.Command
+	// file is the name of the page of the plugin
+	file string
+}
+
+func newDocsCommand(a *app) *cobra.Command {
+	docsCmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate documentation for wpcli and the plugin commands",
+		Long:  `Generate documentation for wpcli and the commands of the plugins, from the configurations they are built from`,
+	}
+
+	docsCmd.AddCommand(newDocsGenerateCommand(a), newDocsManCommand(a))
+	return docsCmd
+}
+
+func newDocsGenerateCommand(a *app) *cobra.Command {
+	var out, language string
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Write a Markdown page for each plugin",
+		Long: `Write a Markdown page for each plugin of the catalog, and of the plugin
+directories given, to the --out directory, with an index.md listing them. Each
+page documents the commands of the selected version of its plugin: their usage,
+description, arguments, flags and examples, in the language selected with
+--language, or with --lang by default.
+
+Pages are named after their plugin, and written the same way for the same
+catalog, so they can be committed. Pages of plugins no longer in the catalog
+are left in place.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if language == "" {
+				selected, err := a.language()
+				if err != nil {
+					return err
+				}
+				language = selected
+			} else {
+				dirs, err := a.deps.Paths()
+				if err != nil {
+					return err
+				}
+				settings, err := a.deps.Settings(dirs)
+				if err != nil {
+					return err
+				}
+				if err := checkLanguage(settings, language, "--language"); err != nil {
+					return err
+				}
+			}
+
+			documented, err := a.documentedPlugins(cmd.Root())
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return fmt.Errorf("failed to create the documentation directory: %w", err)
+			}
+
+			var index bytes.Buffer
+			writeDocsIndex(&index, documented, language)
+			if err := os.WriteFile(filepath.Join(out, "index.md"), index.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write the documentation index: %w", err)
+			}
+			for _, plugin := range documented {
+				var page bytes.Buffer
+				if err := a.writePluginPage(&page, plugin, language); err != nil {
+					return err
+				}
+				if err := os.WriteFile(filepath.Join(out, plugin.file), page.Bytes(), 0644); err != nil {
+					return fmt.Errorf("failed to write the documentation of %s: %w", plugin.name, err)
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Documented %d plugin(s) in %s\n", len(documented), out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "docs", "Directory to write the Markdown pages to")
+	cmd.Flags().StringVar(&language, "language", "", "Language of the pages, the one of --lang by default")
+	if err := cmd.MarkFlagDirname("out"); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the out flag completion: %v\n", err)
+	}
+	return cmd
+}
+
+func newDocsManCommand(a *app) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "man",
+		Short: "Write man pages for wpcli and the plugin commands",
+		Long: `Write a man page for each command of wpcli to the --out directory, including
+the commands of every plugin of the catalog and of the plugin directories
+given. The pages of plugin commands name the plugin and version they come from.
+
+--catalog reads the plugins from a registry directory, or its plugins.yml,
+instead of the repository, without network access, so that packages can build
+the pages from a known catalog. Set SOURCE_DATE_EPOCH to date the pages.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The plugin commands of a catalog given for the pages must load
+			if a.catalog != "" {
+				repoManager, err := a.openRepository()
+				if err != nil {
+					return err
+				}
+				if err := a.newConfigManager(repoManager.GetRepoPath()).Load(); err != nil {
+					return fmt.Errorf("failed to load the catalog: %w", err)
+				}
+			}
+
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return fmt.Errorf("failed to create the man page directory: %w", err)
+			}
+			rootCmd := cmd.Root()
+			rootCmd.DisableAutoGenTag = true
+			header := &doc.GenManHeader{
+				Section: manSection,
+				Source:  "wpcli " + version.Current(),
+				Manual:  "wpcli manual",
+			}
+			if err := doc.GenManTree(rootCmd, header, out); err != nil {
+				return fmt.Errorf("failed to write the man pages: %w", err)
+			}
+			if err := addManProvenance(rootCmd, out); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote the man pages to %s\n", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "man", "Directory to write the man pages to")
+	cmd.Flags().String("catalog", "", "Registry directory, or plugins.yml, to read the plugins from instead of the repository")
+	for _, name := range []string{"out", "catalog"} {
+		if err := cmd.MarkFlagDirname(name); err != nil {
+			fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the %s flag completion: %v\n", name, err)
+		}
+	}
+	return cmd
+}
+
+// manSection is the section of the man pages of wpcli
+const manSection = "1"
+
+// addManProvenance adds a NOTES section to the man pages of the plugin
+// commands below cmd, naming the plugin version they come from, and refers
+// to the page of wpcli info in their SEE ALSO section. The pages are the
+// ones doc.GenManTree writes to dir.
+func addManProvenance(cmd *cobra.Command, dir string) error {
+	for _, child := range cmd.Commands() {
+		if !child.IsAvailableCommand() || child.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		if err := addManProvenance(child, dir); err != nil {
+			return err
+		}
+	}
+	uuid := cmd.Annotations[plugins.PluginUUIDAnnotation]
+	if uuid == "" {
+		return nil
+	}
+
+	file := filepath.Join(dir, strings.ReplaceAll(cmd.CommandPath(), " ", "-")+"."+manSection)
+	page, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read the man page of %s: %w", cmd.CommandPath(), err)
+	}
+
+	name := cmd.Annotations[plugins.PluginNameAnnotation]
+	notes := fmt.Sprintf(".SH NOTES\nProvided by plugin %s version %s (UUID %s). Run \\fBwpcli info %s\\fP for details about the plugin.\n\n\n",
+		name, cmd.Annotations[plugins.PluginVersionAnnotation], uuid, name)
+	infoPage := fmt.Sprintf("\\fBwpcli-info(%s)\\fP", manSection)
+
+	// NOTES goes before SEE ALSO, whose single line lists the pages
+	seeAlso := []byte("\n.SH SEE ALSO\n")
+	if i := bytes.Index(page, seeAlso); i >= 0 {
+		start := i + len(seeAlso)
+		end := len(page)
+		if n := bytes.IndexByte(page[start:], '\n'); n >= 0 {
+			end = start + n
+		}
+		page = slices.Concat(page[:i+1], []byte(notes), page[i+1:end], []byte(", "+infoPage), page[end:])
+	} else {
+		page = slices.Concat(page, []byte("\n"+notes), seeAlso[1:], []byte(infoPage+"\n"))
+	}
+	if err := os.WriteFile(file, page, 0644); err != nil {
+		return fmt.Errorf("failed to write the man page of %s: %w", cmd.CommandPath(), err)
+	}
+	return nil
+}
+
+// documentedPlugins returns the plugins of the commands of the command tree,
+// sorted by name, each with its commands in the order of its configuration.
+// Commands reached in several ways, such as under the UUID of their plugin
+// or as the alias of their group, are documented where they are first found,
+// and hidden ones are left out.
+func (a *app) documentedPlugins(rootCmd *cobra.Command) ([]*documentedPlugin, error) {
+	byUUID := make(map[string]*documentedPlugin)
+	seen := make(map[string]bool)
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, child := range cmd.Commands() {
+			if child.Hidden {
+				continue
+			}
+			if uuid := child.Annotations[plugins.PluginUUIDAnnotation]; uuid != "" {
+				key := uuid + "/" + child.Annotations[plugins.PluginCommandAnnotation]
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				plugin, ok := byUUID[uuid]
+				if !ok {
+					plugin = &documentedPlugin{
+						name:    child.Annotations[plugins.PluginNameAnnotation],
+						uuid:    uuid,
+						version: child.Annotations[plugins.PluginVersionAnnotation],
+					}
+					byUUID[uuid] = plugin
+				}
+				plugin.commands = append(plugin.commands, child)
+			}
+			walk(child)
+		}
+	}
+	walk(rootCmd)
+
+	// The descriptions of catalog plugins come from the catalog, and the
+	// ones of plugin directories from their configuration
+	var catalog *plugins.ConfigManager
+	if repoManager, err := a.openRepository(); err == nil {
+		catalog = a.newConfigManager(repoManager.GetRepoPath())
+		if err := catalog.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load plugins configuration: %w", err)
+		}
+	}
+
+	documented := make([]*documentedPlugin, 0, len(byUUID))
+	names := make(map[string]int)
+	for _, plugin := range byUUID {
+		if catalog != nil {
+			if catalogPlugin, err := catalog.GetPluginByUUID(plugin.uuid); err == nil {
+				plugin.description = catalogPlugin.Description
+			}
+		}
+		if len(plugin.description) == 0 {
+			pluginConfig, _, err := plugins.CommandConfig(a.logger, plugin.commands[0])
+			if err != nil {
+				return nil, err
+			}
+			plugin.description = pluginConfig.Description
+		}
+		documented = append(documented, plugin)
+		names[plugin.name]++
+	}
+	sort.Slice(documented, func(i, j int) bool {
+		if documented[i].name != documented[j].name {
+			return documented[i].name < documented[j].name
+		}
+		return documented[i].uuid < documented[j].uuid
+	})
+
+	// Plugins sharing a name are told apart by UUID
+	for _, plugin := range documented {
+		plugin.file = plugin.name + ".md"
+		if names[plugin.name] > 1 {
+			plugin.file = plugin.name + "-" + plugin.uuid + ".md"
+		}
+	}
+	return documented, nil
+}
+
+// writeDocsIndex writes the page listing the documented plugins
+func writeDocsIndex(w io.Writer, documented []*documentedPlugin, language string) {
+	fmt.Fprint(w, "# Plugins\n\n")
+	if len(documented) == 0 {
+		fmt.Fprint(w, "No plugin commands are available.\n")
+		return
+	}
+	fmt.Fprint(w, "| Plugin | Version | Description |\n|---|---|---|\n")
+	for _, plugin := range documented {
+		fmt.Fprintf(w, "| [%s](%s) | %s | %s |\n", markdownCell(plugin.name), plugin.file, markdownCell(plugin.version), markdownCell(plugin.description.Get(language)))
+	}
+}
+
+// writePluginPage writes the page of a plugin, with a section per command
+func (a *app) writePluginPage(w io.Writer, plugin *documentedPlugin, language string) error {
+	fmt.Fprintf(w, "# %s\n\n", plugin.name)
+	if description := plugin.description.Get(language); description != "" {
+		fmt.Fprintf(w, "%s\n\n", description)
+	}
+	fmt.Fprintf(w, "- UUID: `%s`\n- Version: %s\n", plugin.uuid, plugin.version)
+
+	for _, cmd := range plugin.commands {
+		pluginConfig, cmdConfig, err := plugins.CommandConfig(a.logger, cmd)
+		if err != nil {
+			return fmt.Errorf("failed to document %s: %w", cmd.CommandPath(), err)
+		}
+
+		fmt.Fprintf(w, "\n## %s\n\n", cmd.CommandPath())
+		if description := cmdConfig.Description.Get(language); description != "" {
+			fmt.Fprintf(w, "%s\n\n", description)
+		}
+		if len(cmd.Aliases) > 0 {
+			fmt.Fprintf(w, "Aliases: %s\n\n", codeList(cmd.Aliases))
+		}
+		fmt.Fprintf(w, "### Usage\n\n```\n%s\n```\n", cmd.UseLine())
+
+		if len(cmdConfig.Args) > 0 {
+			fmt.Fprint(w, "\n### Arguments\n\n| Name | Type | Required | Description |\n|---|---|---|---|\n")
+			for _, arg := range cmdConfig.Args {
+				description := arg.Description.Get(language)
+				if len(arg.ValidValues) > 0 {
+					description = strings.TrimSpace(fmt.Sprintf("%s (valid values: %s)", description, strings.Join(arg.ValidValues, ", ")))
+				}
+				required := "no"
+				if arg.Required {
+					required = "yes"
+				}
+				fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", arg.Name, markdownCell(arg.Type), required, markdownCell(description))
+			}
+		}
+
+		// The plugin level flags apply to every command of the plugin
+		flagList := append(slices.Clone(pluginConfig.Flags), cmdConfig.Flags...)
+		flagList = slices.DeleteFunc(flagList, func(flag *flags.Flag) bool { return flag.Hidden })
+		if len(flagList) > 0 {
+			fmt.Fprint(w, "\n### Flags\n\n| Flag | Type | Default | Valid values | Description |\n|---|---|---|---|---|\n")
+			for _, flag := range flagList {
+				fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", flagName(flag), flag.Type, code(flag.Default), codeList(flag.ValidValues), markdownCell(flagDescription(flag, language)))
+			}
+		}
+
+		if len(cmdConfig.Examples) > 0 {
+			fmt.Fprint(w, "\n### Examples\n")
+			for _, example := range cmdConfig.Examples {
+				fmt.Fprintln(w)
+				if description := example.Description.Get(language); description != "" {
+					fmt.Fprintf(w, "%s:\n\n", description)
+				}
+				fmt.Fprintf(w, "```\n%s\n```\n", example.Command)
+			}
+		}
+	}
+	return nil
+}
+
+// flagName returns the command line name of a flag, with its shorthand
+func flagName(flag *flags.Flag) string {
+	name := "`--" + flag.CLIName() + "`"
+	if shorthand := flags.NormalizeShorthand(flag.Shorthand); shorthand != "" {
+		name = "`-" + shorthand + "`, " + name
+	}
+	return name
+}
+
+// flagDescription returns the description of a flag, noting whether it is
+// required, deprecated, or read from an environment variable
+func flagDescription(flag *flags.Flag, language string) string {
+	description := flag.GetDescription(language)
+	if flag.Required {
+		description += " (required)"
+	}
+	if flag.Env != "" {
+		description += fmt.Sprintf(" (or set `%s`)", flag.Env)
+	}
+	if flag.IsDeprecated() {
+		var notes []string
+		if flag.Deprecated != "" {
+			notes = append(notes, flag.Deprecated)
+		}
+		if flag.ReplacedBy != "" {
+			notes = append(notes, fmt.Sprintf("use `%s` instead", flag.ReplacedBy))
+		}
+		description += fmt.Sprintf(" (deprecated: %s)", strings.Join(notes, ", "))
+	}
+	return strings.TrimSpace(description)
+}
+
+// code returns a value as inline code, or nothing for an empty value
+func code(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + markdownCell(value) + "`"
+}
+
+// codeList returns values as a list of inline code
+func codeList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, code(value))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// markdownCell makes text fit in a cell of a Markdown table
+func markdownCell(text string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "|", `\|`)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/paths"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCommand(a *app) *cobra.Command {
+	var migratePaths bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the wpcli installation",
+		Long: `Check the wpcli installation and report where its data is stored, and
+which plugins fail to load and why.
+
+Use --migrate-paths to move data from the legacy ~/.wpcli directory to the
+platform cache and config directories.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if migratePaths {
+				moved, err := paths.Migrate()
+				for _, move := range moved {
+					fmt.Fprintf(out, "Moved %s\n", move)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to migrate paths: %w", err)
+				}
+				if len(moved) == 0 {
+					fmt.Fprintln(out, "Nothing to migrate")
+				}
+				return nil
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "Cache directory: %s\n", dirs.CacheDir)
+			fmt.Fprintf(out, "Config directory: %s\n", dirs.ConfigDir)
+			if dirs.Legacy {
+				fmt.Fprintln(out, "Using the legacy ~/.wpcli directory; run 'wpcli doctor --migrate-paths' to move to the platform directories")
+			}
+
+			return a.checkPlugins(cmd, out)
+		},
+	}
+
+	cmd.Flags().BoolVar(&migratePaths, "migrate-paths", false, "Move data from ~/.wpcli to the platform cache and config directories")
+	return cmd
+}
+
+// checkPlugins builds the commands of every plugin, bypassing the command
+// cache, and lists the command name collisions and the plugins and commands
+// that fail to load with their errors
+func (a *app) checkPlugins(cmd *cobra.Command, out io.Writer) error {
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return err
+	}
+	language, err := a.language()
+	if err != nil {
+		return err
+	}
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return err
+	}
+	if strict, _ := cmd.Flags().GetBool("strict"); strict {
+		strictSettings := *settings
+		strictSettings.StrictConfig = true
+		settings = &strictSettings
+	}
+
+	configPath := filepath.Join(repoManager.GetRepoPath(), "plugins.yml")
+	_, failures, err := plugins.GetPluginCommands(a.logger, configPath, nil, a.reservedNames(cmd.Root()), language, settings)
+	if err != nil {
+		return fmt.Errorf("failed to load plugin commands: %w", err)
+	}
+
+	if len(a.collisions) > 0 {
+		fmt.Fprintf(out, "%d command name collision(s):\n", len(a.collisions))
+		for _, collision := range a.collisions {
+			fmt.Fprintf(out, "  %s\n", collision)
+		}
+	}
+
+	if len(failures) == 0 {
+		fmt.Fprintln(out, "All plugins loaded")
+		return nil
+	}
+	fmt.Fprintf(out, "%d plugin(s) or command(s) failed to load:\n", len(failures))
+	for _, failure := range failures {
+		name := failure.Plugin
+		if failure.Version != "" {
+			name += " " + failure.Version
+		}
+		if failure.Command != "" {
+			name += ", command " + failure.Command
+		}
+		fmt.Fprintf(out, "  %s (%s)\n", name, failure.UUID)
+		for _, line := range strings.Split(failure.Error(), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// addUpdateHints adds a hint at the bottom of the help of plugin commands
+// whose installed version is older than the latest version in the catalog.
+// Only the local state and the cached catalog are used, so rendering help
+// never contacts the network.
+func (a *app) addUpdateHints(commands []*cobra.Command) error {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return err
+	}
+
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return err
+	}
+	if settings.DisableUpdateHints {
+		return nil
+	}
+
+	st, err := state.Load(state.Path(dirs.ConfigDir))
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range commands {
+		addUpdateHint(cmd, st.Installed)
+	}
+
+	return nil
+}
+
+func addUpdateHint(cmd *cobra.Command, installed map[string]string) {
+	for _, child := range cmd.Commands() {
+		addUpdateHint(child, installed)
+	}
+
+	uuid := cmd.Annotations[plugins.PluginUUIDAnnotation]
+	current, ok := installed[uuid]
+	if uuid == "" || !ok {
+		return
+	}
+
+	latest := cmd.Annotations[plugins.PluginLatestVersionAnnotation]
+	if plugins.CompareVersions(latest, current) <= 0 {
+		return
+	}
+
+	hint := fmt.Sprintf("newer version %s available — run 'wpcli upgrade %s'", latest, cmd.Annotations[plugins.PluginNameAnnotation])
+	help := cmd.HelpFunc()
+	cmd.SetHelpFunc(func(c *cobra.Command, args []string) {
+		help(c, args)
+		// Keep the help of scripts and pipes free of hints
+		if out := c.OutOrStdout(); isTerminal(out) {
+			fmt.Fprintf(out, "\n%s\n", hint)
+		}
+	})
+}
+
+// isTerminal reports whether w is an interactive terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -2,66 +2,305 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/ploffredi/wpcli/internal/flags"
 	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/i18n"
 	"github.com/ploffredi/wpcli/internal/plugins"
 	"github.com/spf13/cobra"
 )
 
-var infoCmd = &cobra.Command{
-	Use:   "info [plugin-name]",
-	Short: "Get detailed information about a specific plugin",
-	Long:  `Get detailed information about a specific plugin from the wpstore repository`,
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		pluginName := args[0]
-
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
+// pluginDetails is a plugin in the json and yaml output of info
+type pluginDetails struct {
+	Name          string                 `json:"name" yaml:"name"`
+	UUID          string                 `json:"uuid" yaml:"uuid"`
+	Description   i18n.Text              `json:"description" yaml:"description"`
+	Subcommand    string                 `json:"subcommand,omitempty" yaml:"subcommand,omitempty"`
+	Versions      []versionDetails       `json:"versions" yaml:"versions"`
+	MemoryLimit   string                 `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	RequiresWpcli string                 `json:"requires_wpcli,omitempty" yaml:"requires_wpcli,omitempty"`
+	Policy        plugins.Policy         `json:"policy" yaml:"policy"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	At            *snapshotDetails       `json:"at,omitempty" yaml:"at,omitempty"`
+}
 
-		basePath := filepath.Join(homeDir, ".wpcli")
-		if err := os.MkdirAll(basePath, 0755); err != nil {
-			return fmt.Errorf("failed to create base directory: %w", err)
-		}
+// versionDetails is a version of a plugin in the output of info
+type versionDetails struct {
+	Version  string `json:"version" yaml:"version"`
+	Conf     string `json:"conf" yaml:"conf"`
+	Wasm     string `json:"wasm,omitempty" yaml:"wasm,omitempty"`
+	SHA256   string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	Selected bool   `json:"selected" yaml:"selected"`
+}
 
-		repoManager := git.NewRepoManager(basePath)
-		if err := repoManager.Clone(); err != nil {
-			return fmt.Errorf("failed to clone repository: %w", err)
-		}
+func newInfoCommand(a *app) *cobra.Command {
+	var uuid, output string
 
-		if err := repoManager.Pull(); err != nil {
-			return fmt.Errorf("failed to pull repository: %w", err)
-		}
+	cmd := &cobra.Command{
+		Use:   "info [plugin-name]",
+		Short: "Get detailed information about a specific plugin",
+		Long: `Get detailed information about a specific plugin from the wpstore repository.
 
-		configManager := plugins.NewConfigManager(repoManager.GetRepoPath())
-		if err := configManager.Load(); err != nil {
-			return fmt.Errorf("failed to load plugins configuration: %w", err)
-		}
+The plugin can be given by name or by UUID. When several plugins share a name,
+use --uuid or pass the UUID as the argument to choose one.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if uuid != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		Annotations: map[string]string{
+			historicalAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if output != "text" && output != "json" && output != "yaml" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json, yaml", output)
+			}
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			configManager, snapshot, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+
+			var plugin *plugins.Plugin
+			if uuid != "" {
+				plugin, err = configManager.GetPluginByUUID(uuid)
+			} else {
+				plugin, err = configManager.GetPlugin(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get plugin information: %w", err)
+			}
+
+			// The version plugin commands are built from, which differs from
+			// the latest one when pinned with plugin_versions
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			selected, err := settings.SelectVersion(*plugin)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+			}
+
+			// The configuration of the selected version tells how its
+			// modules run
+			var pluginConfig *plugins.Plugin
+			if selected.Conf != "" {
+				pluginConfig, err = a.readPluginConfig(repoManager, snapshot, *plugin, selected)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+				}
+			}
+			memoryLimit := plugins.EffectiveMemoryLimit(*plugin, pluginConfig, settings)
+			requiresWpcli := plugins.EffectiveRequiresWpcli(*plugin, pluginConfig)
+			policy := settings.Policy(*plugin)
+
+			// The metadata is written as the catalog has it
+			if output != "text" {
+				details := pluginDetails{
+					Name:          plugin.Name,
+					UUID:          plugin.UUID,
+					Description:   plugin.Description,
+					Subcommand:    plugin.Subcommand,
+					MemoryLimit:   memoryLimit,
+					RequiresWpcli: requiresWpcli,
+					Policy:        policy,
+					Metadata:      plugin.Metadata,
+					At:            a.historical(snapshot),
+				}
+				for _, version := range plugin.Versions {
+					details.Versions = append(details.Versions, versionDetails{
+						Version:  version.Version,
+						Conf:     version.Conf,
+						Wasm:     version.Wasm,
+						SHA256:   version.SHA256,
+						Selected: version.Version == selected.Version,
+					})
+				}
+				if output == "json" {
+					return writeJSON(out, details)
+				}
+				return writeYAML(out, details)
+			}
+
+			if snapshot != nil {
+				fmt.Fprintln(out, snapshot.Label())
+				fmt.Fprintln(out)
+			}
+
+			fmt.Fprintf(out, "Plugin Information for: %s\n", plugin.Name)
+			fmt.Fprintln(out, "-----------------")
+			fmt.Fprintln(out, "Description:")
+			// The English text comes first, followed by the translations
+			if plugin.Description.Has(i18n.DefaultLanguage) {
+				fmt.Fprintf(out, "  %s: %s\n", i18n.LanguageName(i18n.DefaultLanguage), plugin.Description[i18n.DefaultLanguage])
+			}
+			for _, lang := range plugin.Description.Languages() {
+				if lang != i18n.DefaultLanguage {
+					fmt.Fprintf(out, "  %s: %s\n", i18n.LanguageName(lang), plugin.Description[lang])
+				}
+			}
+
+			fmt.Fprintf(out, "UUID: %s\n", plugin.UUID)
+			fmt.Fprintln(out, "\nVersions:")
+			for _, version := range plugin.Versions {
+				if version.Version == selected.Version {
+					fmt.Fprintf(out, "  Version: %s (selected)\n", version.Version)
+				} else {
+					fmt.Fprintf(out, "  Version: %s\n", version.Version)
+				}
+				fmt.Fprintf(out, "    Config: %s\n", version.Conf)
+			}
+
+			if limit, err := plugins.ParseMemoryLimit(memoryLimit); err != nil {
+				fmt.Fprintf(out, "\nMemory limit: %s (%v)\n", memoryLimit, err)
+			} else if limit == 0 {
+				fmt.Fprintln(out, "\nMemory limit: none")
+			} else {
+				fmt.Fprintf(out, "\nMemory limit: %s\n", flags.FormatBytes(limit))
+			}
 
-		plugin, err := configManager.GetPluginByName(pluginName)
-		if err != nil {
-			return fmt.Errorf("failed to get plugin information: %w", err)
+			// Whether this wpcli can load the plugin commands
+			if requiresWpcli != "" {
+				if err := plugins.CheckRequiresWpcli(a.logger, *plugin, pluginConfig); err != nil {
+					fmt.Fprintf(out, "Requires wpcli: %s (%v)\n", requiresWpcli, err)
+				} else {
+					fmt.Fprintf(out, "Requires wpcli: %s\n", requiresWpcli)
+				}
+			}
+
+			// How the plugin is installed and run
+			if policy.Source != "" {
+				fmt.Fprintf(out, "Trust: %s (%s)\n", policy.Trust, policy.Source)
+			} else {
+				fmt.Fprintf(out, "Trust: %s\n", policy.Trust)
+			}
+			fmt.Fprintf(out, "  Policy: %s\n", policy.Describe())
+
+			// The environment variables the commands pass to the module
+			if pluginConfig != nil {
+				printEnv(out, *plugin, pluginConfig.Commands)
+			}
+
+			if len(plugin.Metadata) > 0 {
+				fmt.Fprintln(out, "\nMetadata:")
+				printMetadata(out, plugin.Metadata, "  ")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&uuid, "uuid", "", "Select the plugin by UUID")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json, yaml)")
+	return cmd
+}
+
+// readPluginConfig reads the configuration of a plugin version from the
+// working tree, or from the snapshot selected with --at
+func (a *app) readPluginConfig(repoManager *git.RepoManager, snapshot *git.Snapshot, plugin plugins.Plugin, version plugins.Version) (*plugins.Plugin, error) {
+	var file string
+	var data []byte
+	var err error
+	if snapshot != nil {
+		file = path.Join(plugin.UUID, version.Version, version.Conf)
+		data, err = snapshot.ReadFile(file)
+	} else {
+		file = filepath.Join(repoManager.GetRepoPath(), plugin.UUID, version.Version, version.Conf)
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config of %s %s: %w", plugin.Name, version.Version, err)
+	}
+	return plugins.ParsePluginConfig(a.logger, file, data)
+}
+
+// maxMetadataWidth is the length metadata values are truncated to in the
+// text output of info
+const maxMetadataWidth = 60
+
+// printMetadata lists the metadata of a plugin with sorted keys, nested maps
+// indented below their key. Long and multiline values are truncated.
+func printMetadata(out io.Writer, metadata map[string]interface{}, indent string) {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if nested, ok := metadata[key].(map[string]interface{}); ok {
+			fmt.Fprintf(out, "%s%s:\n", indent, key)
+			printMetadata(out, nested, indent+"  ")
+			continue
 		}
+		fmt.Fprintf(out, "%s%s: %s\n", indent, key, formatMetadataValue(metadata[key]))
+	}
+}
 
-		fmt.Printf("Plugin Information for: %s\n", plugin.Name)
-		fmt.Println("-----------------")
-		fmt.Println("Description:")
-		fmt.Printf("  English: %s\n", plugin.Description)
-		fmt.Printf("UUID: %s\n", plugin.UUID)
-		fmt.Println("\nVersions:")
-		for _, version := range plugin.Versions {
-			fmt.Printf("  Version: %s\n", version.Version)
-			fmt.Printf("    Config: %s\n", version.Conf)
+// formatMetadataValue returns a metadata value on a line of at most
+// maxMetadataWidth characters, with lists separated by commas
+func formatMetadataValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if items, ok := value.([]interface{}); ok {
+		values := make([]string, len(items))
+		for i, item := range items {
+			values[i] = fmt.Sprint(item)
 		}
+		text = strings.Join(values, ", ")
+	}
 
-		return nil
-	},
+	line, _, multiline := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > maxMetadataWidth {
+		return string(runes[:maxMetadataWidth-3]) + "..."
+	}
+	if multiline {
+		return line + " ..."
+	}
+	return line
 }
 
-func init() {
-	rootCmd.AddCommand(infoCmd)
+// printEnv lists the environment variables requested by each command
+func printEnv(out io.Writer, plugin plugins.Plugin, commands []plugins.PluginCommandConfig) {
+	header := false
+	for _, command := range commands {
+		if len(command.Env) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(out, "\nEnvironment:")
+			header = true
+		}
+
+		name := command.Name
+		if plugin.Subcommand != "" {
+			name = plugin.Subcommand + " " + command.Name
+		}
+		variables := make([]string, len(command.Env))
+		for i, variable := range command.Env {
+			variables[i] = variable.Name
+			if variable.Required {
+				variables[i] += " (required)"
+			}
+		}
+		fmt.Fprintf(out, "  %s: %s\n", name, strings.Join(variables, ", "))
+	}
 }
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+func newInstallCommand(a *app) *cobra.Command {
+	var version string
+
+	cmd := &cobra.Command{
+		Use:   "install <plugin>",
+		Short: "Install the module of a plugin",
+		Long: `Install the WebAssembly module of a plugin, given by name or UUID.
+
+Modules the catalog gives by URL are downloaded, resuming an interrupted
+download, and the others are copied from the repository. The module is
+verified against its sha256 checksum before it is installed. The version is
+the latest one, or the one pinned with plugin_versions, unless --version is
+given. Installing an installed version does nothing.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			configManager, _, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+			plugin, err := configManager.GetPlugin(args[0])
+			if err != nil {
+				return err
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+
+			selected := settings.PluginVersions
+			if version != "" {
+				selected = map[string]string{plugin.UUID: version}
+			}
+			pluginVersion, err := plugins.SelectVersion(*plugin, selected)
+			if err != nil {
+				return err
+			}
+
+			st, err := state.Load(state.Path(dirs.ConfigDir))
+			if err != nil {
+				return err
+			}
+
+			alreadyInstalled, err := a.installModule(cmd, repoManager.GetRepoPath(), settings, st, *plugin, pluginVersion)
+			if err != nil {
+				return err
+			}
+			if alreadyInstalled {
+				fmt.Fprintf(out, "%s %s is already installed\n", plugin.Name, pluginVersion.Version)
+				return nil
+			}
+
+			st.AddInstalled(plugin.UUID, pluginVersion.Version)
+			if err := st.Save(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "Installed %s %s to %s\n", plugin.Name, pluginVersion.Version, plugins.InstallPath(settings.InstallDir, *plugin, pluginVersion))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Version to install instead of the latest one")
+	return cmd
+}
+
+// installModule installs the module of a plugin version unless it is
+// installed already, reporting whether it was. The policy of the plugin may
+// ask for confirmation first. The progress of downloads is shown on
+// terminals.
+func (a *app) installModule(cmd *cobra.Command, repoPath string, settings *plugins.Settings, st *state.State, plugin plugins.Plugin, version plugins.Version) (bool, error) {
+	if st.IsInstalled(plugin.UUID, version.Version) && plugins.ModuleInstalled(settings.InstallDir, plugin, version) {
+		return true, nil
+	}
+	policy := settings.Policy(plugin)
+	if err := policy.ConfirmInstallation(cmd, plugin, version); err != nil {
+		return false, err
+	}
+
+	var progress io.Writer
+	if isTerminal(cmd.ErrOrStderr()) {
+		progress = cmd.ErrOrStderr()
+	}
+	_, err := plugins.Install(cmd.Context(), a.logger, repoPath, settings.InstallDir, plugin, version, settings.ChecksumRequirement(policy), progress)
+	return false, err
+}
@@ -2,63 +2,146 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"slices"
 
-	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/i18n"
 	"github.com/ploffredi/wpcli/internal/plugins"
 	"github.com/spf13/cobra"
 )
 
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all available plugins",
-	Long:  `List all available plugins from the wpstore repository`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
-
-		basePath := filepath.Join(homeDir, ".wpcli")
-		if err := os.MkdirAll(basePath, 0755); err != nil {
-			return fmt.Errorf("failed to create base directory: %w", err)
-		}
-
-		repoManager := git.NewRepoManager(basePath)
-		if err := repoManager.Clone(); err != nil {
-			return fmt.Errorf("failed to clone repository: %w", err)
-		}
-
-		if err := repoManager.Pull(); err != nil {
-			return fmt.Errorf("failed to pull repository: %w", err)
-		}
-
-		configManager := plugins.NewConfigManager(repoManager.GetRepoPath())
-		if err := configManager.Load(); err != nil {
-			return fmt.Errorf("failed to load plugins configuration: %w", err)
-		}
-
-		plugins := configManager.GetPlugins()
-		if len(plugins) == 0 {
-			fmt.Println("No plugins found")
-			return nil
-		}
-
-		fmt.Println("Available plugins:")
-		fmt.Println("-----------------")
-		for _, plugin := range plugins {
-			fmt.Printf("Name: %s\n", plugin.Name)
-			fmt.Printf("Description: %s\n", plugin.Description)
-			fmt.Printf("Latest Version: %s\n", plugin.Versions[0].Version)
-			fmt.Printf("UUID: %s\n", plugin.UUID)
-			fmt.Println("-----------------")
-		}
-
-		return nil
-	},
+// listedPlugin is a plugin in the json and yaml output of list
+type listedPlugin struct {
+	Name             string                 `json:"name" yaml:"name"`
+	UUID             string                 `json:"uuid" yaml:"uuid"`
+	Description      i18n.Text              `json:"description" yaml:"description"`
+	LatestVersion    string                 `json:"latest_version" yaml:"latest_version"`
+	InstalledVersion string                 `json:"installed_version,omitempty" yaml:"installed_version,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	At               *snapshotDetails       `json:"at,omitempty" yaml:"at,omitempty"`
 }
 
-func init() {
-	rootCmd.AddCommand(listCmd)
+func newListCommand(a *app) *cobra.Command {
+	var onlyInstalled bool
+	var filters []string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all available plugins",
+		Long:  `List all available plugins from the wpstore repository`,
+		Annotations: map[string]string{
+			historicalAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if output != "text" && output != "json" && output != "yaml" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json, yaml", output)
+			}
+			var metadataFilters []plugins.MetadataFilter
+			for _, filter := range filters {
+				metadataFilter, err := plugins.ParseMetadataFilter(filter)
+				if err != nil {
+					return err
+				}
+				metadataFilters = append(metadataFilters, metadataFilter)
+			}
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			configManager, snapshot, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+
+			if snapshot != nil && output == "text" {
+				fmt.Fprintln(out, snapshot.Label())
+				fmt.Fprintln(out)
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+
+			var listed []plugins.Plugin
+			for _, plugin := range configManager.GetPlugins() {
+				if _, ok := settings.Installed[plugin.UUID]; !ok && (onlyInstalled || settings.OnlyInstalled) {
+					continue
+				}
+				// Every filter has to match
+				if !slices.ContainsFunc(metadataFilters, func(filter plugins.MetadataFilter) bool { return !filter.Match(plugin) }) {
+					listed = append(listed, plugin)
+				}
+			}
+
+			// The metadata is written as the catalog has it
+			if output != "text" {
+				results := []listedPlugin{}
+				for _, plugin := range listed {
+					results = append(results, listedPlugin{
+						Name:             plugin.Name,
+						UUID:             plugin.UUID,
+						Description:      plugin.Description,
+						LatestVersion:    plugin.Versions[0].Version,
+						InstalledVersion: settings.Installed[plugin.UUID],
+						Metadata:         plugin.Metadata,
+						At:               a.historical(snapshot),
+					})
+				}
+				if output == "json" {
+					return writeJSON(out, results)
+				}
+				return writeYAML(out, results)
+			}
+
+			if len(listed) == 0 {
+				fmt.Fprintln(out, "No plugins found")
+				return nil
+			}
+
+			language, err := a.language()
+			if err != nil {
+				return err
+			}
+
+			duplicates := configManager.DuplicateNames()
+			for _, plugin := range listed {
+				if candidates := duplicates[plugin.Name]; len(candidates) > 0 && candidates[0].UUID == plugin.UUID {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: plugin name %s is used by %d plugins with different UUIDs\n", plugin.Name, len(candidates))
+				}
+			}
+
+			fmt.Fprintln(out, "Available plugins:")
+			fmt.Fprintln(out, "-----------------")
+			for _, plugin := range listed {
+				if _, duplicate := duplicates[plugin.Name]; duplicate {
+					fmt.Fprintf(out, "Name: %s (ambiguous name, use UUID %s)\n", plugin.Name, plugin.UUID)
+				} else {
+					fmt.Fprintf(out, "Name: %s\n", plugin.Name)
+				}
+				fmt.Fprintf(out, "Description: %s\n", plugin.GetDescription(language))
+				fmt.Fprintf(out, "Latest Version: %s\n", plugin.Versions[0].Version)
+				if installed, ok := settings.Installed[plugin.UUID]; ok {
+					fmt.Fprintf(out, "Installed Version: %s\n", installed)
+				}
+				fmt.Fprintf(out, "UUID: %s\n", plugin.UUID)
+				fmt.Fprintln(out, "-----------------")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&onlyInstalled, "only-installed", false, "List installed plugins only (or set only_installed)")
+	cmd.Flags().StringArrayVar(&filters, "filter", nil, "List the plugins with a metadata value, such as metadata.category=database; can be repeated")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json, yaml)")
+	return cmd
 }
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// configureLogging creates the logger of the app, writing to stderr at the level selected with
+// --verbose: warnings by default, info when given once and debug when given
+// more than once. Without --verbose, the log_level setting selects it.
+func (a *app) configureLogging() {
+	level := slog.LevelWarn
+	switch verbosity := a.commandLine.count("verbose"); {
+	case verbosity == 1:
+		level = slog.LevelInfo
+	case verbosity > 1:
+		level = slog.LevelDebug
+	default:
+		level = a.settingsLogLevel(level)
+	}
+
+	handler := slog.NewTextHandler(a.deps.Stderr, &slog.HandlerOptions{
+		Level: level,
+		// Timestamps only add noise to the output of a command line tool
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+	a.logger = slog.New(handler)
+}
+
+// settingsLogLevel returns the level of the log_level setting, or def when
+// it is not set. Settings that fail to load are reported by the command
+// run, so they are not here.
+func (a *app) settingsLogLevel(def slog.Level) slog.Level {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return def
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil || settings.LogLevel == "" {
+		return def
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(settings.LogLevel)); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: invalid log_level %q, valid values are debug, info, warn and error\n", settings.LogLevel)
+		return def
+	}
+	return level
+}
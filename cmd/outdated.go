@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// outdatedPlugin is an installed plugin with a newer version in the catalog
+type outdatedPlugin struct {
+	Plugin    string `json:"plugin"`
+	UUID      string `json:"uuid"`
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	// Changelog is the first line of the changelog of the latest version
+	Changelog string `json:"changelog,omitempty"`
+}
+
+func newOutdatedCommand(a *app) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "List installed plugins with a newer version",
+		Long: `List the installed plugins whose latest version in the catalog is newer than
+the installed one, with the first line of its changelog when the plugin
+metadata has one. Run 'wpcli upgrade' to install the latest versions.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json", output)
+			}
+
+			outdated, err := a.outdatedPlugins()
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				return writeJSON(out, outdated)
+			}
+			if len(outdated) == 0 {
+				fmt.Fprintln(out, "All installed plugins are up to date")
+				return nil
+			}
+
+			table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(table, "PLUGIN\tINSTALLED\tLATEST\tCHANGES")
+			for _, plugin := range outdated {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", plugin.Plugin, plugin.Installed, plugin.Latest, plugin.Changelog)
+			}
+			return table.Flush()
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json)")
+	return cmd
+}
+
+// outdatedPlugins returns the installed plugins of the catalog whose latest
+// version is newer than the installed one
+func (a *app) outdatedPlugins() ([]outdatedPlugin, error) {
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return nil, err
+	}
+	configManager, _, err := a.loadCatalog(repoManager)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := []outdatedPlugin{}
+	for _, plugin := range configManager.GetPlugins() {
+		installed, ok := settings.Installed[plugin.UUID]
+		if !ok {
+			continue
+		}
+		latest := plugin.Versions[0].Version
+		if plugins.CompareVersions(latest, installed) <= 0 {
+			continue
+		}
+		outdated = append(outdated, outdatedPlugin{
+			Plugin:    plugin.Name,
+			UUID:      plugin.UUID,
+			Installed: installed,
+			Latest:    latest,
+			Changelog: plugin.ChangelogLine(latest),
+		})
+	}
+	return outdated, nil
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(out io.Writer, v interface{}) error {
+	// Values such as version constraints are written as they are, without
+	// escaping < > and &
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return nil
+}
+
+// writeYAML writes v as YAML
+func writeYAML(out io.Writer, v interface{}) error {
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return encoder.Close()
+}
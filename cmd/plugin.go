@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/consent"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/scaffold"
+	"github.com/spf13/cobra"
+)
+
+func newPluginCommand(a *app) *cobra.Command {
+	pluginCmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Create and publish plugins, and review their permissions",
+		Long: `Tools for plugin authors, from a new plugin to its release in a registry, and
+for users, to review the permissions granted to plugins`,
+	}
+
+	pluginCmd.AddCommand(newPluginInitCommand(a), newPluginPackCommand(a), newPluginPermissionsCommand(a))
+	return pluginCmd
+}
+
+func newPluginInitCommand(a *app) *cobra.Command {
+	var template, dir string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init <name>",
+		Short: "Generate the files of a new plugin",
+		Long: `Generate a directory with the configuration of a new plugin, with an example
+command, argument and flags described in every supported language, and the
+entry to add to plugins.yml to publish it. With --template, the source of a
+module for the plugin is generated too, built with TinyGo or Rust.
+
+The directory is named after the plugin unless --dir is given. Existing files
+are only overwritten with --force. Try the plugin with --plugin-dir.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+
+			// The default language comes first
+			languages := []string{i18n.DefaultLanguage}
+			if settings.DefaultLanguage != "" {
+				languages = []string{settings.DefaultLanguage}
+			}
+			for _, language := range settings.SupportedLanguages {
+				if !slices.Contains(languages, language) {
+					languages = append(languages, language)
+				}
+			}
+
+			uuid, err := scaffold.NewUUID()
+			if err != nil {
+				return err
+			}
+			plugin := scaffold.Plugin{
+				Name:      args[0],
+				UUID:      uuid,
+				Version:   scaffold.InitialVersion,
+				Languages: languages,
+				Template:  template,
+			}
+			if dir == "" {
+				dir = plugin.Name
+			}
+
+			written, err := scaffold.Generate(dir, plugin, force)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Created plugin %s in %s:\n", plugin.Name, dir)
+			for _, path := range written {
+				fmt.Fprintf(out, "  %s\n", path)
+			}
+			fmt.Fprintf(out, "\nTry it with: wpcli --plugin-dir %s %s world\n", dir, plugin.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&template, "template", scaffold.TemplateNone, "Module source to generate (valid values: tinygo, rust, none)")
+	cmd.Flags().StringVar(&dir, "dir", "", "Directory to generate the plugin in, named after the plugin by default")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files")
+	if err := cmd.RegisterFlagCompletionFunc("template", cobra.FixedCompletions(scaffold.Templates, cobra.ShellCompDirectiveNoFileComp)); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the template flag completion: %v\n", err)
+	}
+	return cmd
+}
+
+func newPluginPackCommand(a *app) *cobra.Command {
+	var version, outputDir, catalog string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "pack <dir>",
+		Short: "Pack a release of a plugin with its checksum",
+		Long: `Pack the configuration and built module of the plugin in a plugin directory,
+like the ones --plugin-dir loads, into a <name>-<version>.tar.gz archive, and
+print the versions entry of the release for plugins.yml, with the sha256
+checksum of the module.
+
+The version is the one given with --version, or else the version of the
+plugin configuration. It must be above the versions of the plugin in the
+catalog, so --version is required when the version of the configuration was
+not bumped. With --update-catalog, the entry is added to the versions of the
+plugin in the given plugins.yml instead of printed, and the version is checked
+against that catalog instead of the one of the repository. An existing
+archive, a version already in the catalog and one below its latest version
+are only allowed with --force.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			strict, _ := cmd.Flags().GetBool("strict")
+
+			release, err := plugins.NewRelease(a.logger, args[0], version, a.reservedNames(cmd.Root()), strict || settings.StrictConfig)
+			if err != nil {
+				return err
+			}
+
+			archive := filepath.Join(outputDir, release.ArchiveName())
+			if _, err := os.Stat(archive); err == nil && !force {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", archive)
+			}
+
+			// The catalog is checked before anything is written
+			var updated []byte
+			if catalog == "" && !force {
+				if err := a.checkReleaseVersion(release); err != nil {
+					return err
+				}
+			}
+			if catalog != "" {
+				data, err := os.ReadFile(catalog)
+				if err != nil {
+					return fmt.Errorf("failed to read catalog: %w", err)
+				}
+				if updated, err = release.AddToCatalog(catalog, data, force); err != nil {
+					return err
+				}
+			}
+
+			if err := release.WriteArchive(archive); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Packed %s %s into %s\n", release.Plugin.Name, release.Version.Version, archive)
+
+			if catalog == "" {
+				fmt.Fprintf(out, "\nAdd this entry to the versions of %s in plugins.yml:\n\n", release.Plugin.Name)
+				fmt.Fprint(out, release.CatalogEntry(""))
+				return nil
+			}
+			if err := os.WriteFile(catalog, updated, 0644); err != nil {
+				return fmt.Errorf("failed to write catalog: %w", err)
+			}
+			fmt.Fprintf(out, "Added version %s of %s to %s\n", release.Version.Version, release.Plugin.Name, catalog)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Version of the release, instead of the version of the plugin configuration; required when that version was not bumped")
+	cmd.Flags().StringVar(&outputDir, "output-dir", ".", "Directory to write the archive to")
+	cmd.Flags().StringVar(&catalog, "update-catalog", "", "Add the release to the versions of the plugin in this plugins.yml")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing archive or catalog version, and allow versions that were not bumped")
+	return cmd
+}
+
+// checkReleaseVersion checks the version of a release against the versions
+// of its plugin in the catalog of the repository, found by UUID or else by
+// name as with --update-catalog, if the plugin is there
+func (a *app) checkReleaseVersion(release *plugins.Release) error {
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return err
+	}
+	configManager, _, err := a.loadCatalog(repoManager)
+	if err != nil {
+		return err
+	}
+	plugin, err := configManager.GetPluginByUUID(release.Plugin.UUID)
+	if err != nil {
+		if plugin, err = configManager.GetPluginByName(release.Plugin.Name); err != nil {
+			return nil
+		}
+	}
+	return release.CheckVersion(plugin.Versions, false)
+}
+
+func newPluginPermissionsCommand(a *app) *cobra.Command {
+	var version string
+	var revoke bool
+
+	cmd := &cobra.Command{
+		Use:   "permissions <plugin-name>",
+		Short: "Review or revoke the permissions granted to a plugin",
+		Long: `Show the environment variables, host paths and network hosts a plugin version
+requests, and whether they were granted. The version is the one plugin
+commands run, unless --version is given.
+
+With --revoke, the permissions granted to every version of the plugin, or to
+the given --version, are revoked, and are asked for again on the next run.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+			configManager, snapshot, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+			plugin, err := configManager.GetPlugin(args[0])
+			if err != nil {
+				return err
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			grants, err := consent.Load(settings.ConsentPath)
+			if err != nil {
+				return err
+			}
+
+			if revoke {
+				revoked := grants.Revoke(plugin.UUID, version)
+				if len(revoked) == 0 {
+					fmt.Fprintf(out, "No permissions are granted to %s\n", plugin.Name)
+					return nil
+				}
+				if err := grants.Save(); err != nil {
+					return err
+				}
+				for _, grant := range revoked {
+					fmt.Fprintf(out, "Revoked the permissions granted to %s %s\n", plugin.Name, grant.Version)
+				}
+				return nil
+			}
+
+			selected, err := settings.SelectVersion(*plugin)
+			if version != "" {
+				selected, err = plugins.SelectVersion(*plugin, map[string]string{plugin.UUID: version})
+			}
+			if err != nil {
+				return err
+			}
+			pluginConfig, err := a.readPluginConfig(repoManager, snapshot, *plugin, selected)
+			if err != nil {
+				return err
+			}
+
+			requested := plugins.RequestedPermissions(pluginConfig)
+			if requested.IsEmpty() {
+				fmt.Fprintf(out, "Plugin %s %s requests no permissions\n", plugin.Name, selected.Version)
+			} else {
+				fmt.Fprintf(out, "Plugin %s %s requests:\n", plugin.Name, selected.Version)
+				requested.Describe(out, "  ")
+				grant, ok := grants.Lookup(plugin.UUID, selected.Version)
+				switch {
+				case !ok:
+					fmt.Fprintln(out, "Granted: no")
+				case grant.Hash != requested.Hash():
+					fmt.Fprintf(out, "Granted: no, the permissions changed since they were granted on %s\n", grant.GrantedAt.Format(time.DateTime))
+				default:
+					fmt.Fprintf(out, "Granted: yes, on %s\n", grant.GrantedAt.Format(time.DateTime))
+				}
+			}
+
+			var others []string
+			for _, grant := range grants.PluginGrants(plugin.UUID) {
+				if grant.Version != selected.Version {
+					others = append(others, grant.Version)
+				}
+			}
+			if len(others) > 0 {
+				fmt.Fprintf(out, "Also granted to versions: %s\n", strings.Join(others, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Version of the plugin, instead of the one its commands run")
+	cmd.Flags().BoolVar(&revoke, "revoke", false, "Revoke the permissions granted to the plugin")
+	return cmd
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// repoStatus is the information reported by repo status
+type repoStatus struct {
+	Path       string     `json:"path"`
+	Source     string     `json:"source"`
+	Commit     string     `json:"commit"`
+	Branch     string     `json:"branch"`
+	CommitTime time.Time  `json:"commit_time"`
+	Plugins    int        `json:"plugins"`
+	LastPull   *time.Time `json:"last_pull"`
+}
+
+func newRepoCommand(a *app) *cobra.Command {
+	repoCmd := &cobra.Command{
+		Use:   "repo",
+		Short: "Inspect the local plugin repository",
+		Long:  `Inspect the local clone of the wpstore repository`,
+	}
+
+	repoCmd.AddCommand(newRepoStatusCommand(a))
+	return repoCmd
+}
+
+func newRepoStatusCommand(a *app) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the current revision of the plugin catalog",
+		Long: `Show the revision of the plugin catalog the CLI is using: the source it was
+pulled from, the current commit, branch, commit time, number of plugins and
+when the repository was last pulled.
+This command never contacts the network.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			now := a.deps.Now()
+
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json", output)
+			}
+
+			repoManager, err := a.openLocalRepository()
+			if err != nil {
+				return err
+			}
+
+			head, err := repoManager.Head()
+			if err != nil {
+				return err
+			}
+
+			configManager := a.newConfigManager(repoManager.GetRepoPath())
+			if err := configManager.Load(); err != nil {
+				return fmt.Errorf("failed to load plugins configuration: %w", err)
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+
+			st, err := state.Load(state.Path(dirs.ConfigDir))
+			if err != nil {
+				return err
+			}
+
+			status := repoStatus{
+				Path:       repoManager.GetRepoPath(),
+				Source:     st.Source,
+				Commit:     head.Hash,
+				Branch:     head.Branch,
+				CommitTime: head.CommitTime,
+				Plugins:    len(configManager.GetPlugins()),
+			}
+			if !st.LastPull.IsZero() {
+				status.LastPull = &st.LastPull
+			}
+
+			if output == "json" {
+				data, err := json.MarshalIndent(status, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to encode status: %w", err)
+				}
+				fmt.Fprintln(out, string(data))
+				return nil
+			}
+
+			fmt.Fprintf(out, "Repository: %s\n", status.Path)
+			if status.Source != "" {
+				fmt.Fprintf(out, "Source: %s\n", status.Source)
+			}
+			fmt.Fprintf(out, "Commit: %s\n", status.Commit)
+			fmt.Fprintf(out, "Branch: %s\n", status.Branch)
+			fmt.Fprintf(out, "Commit time: %s (%s)\n", status.CommitTime.Format(time.RFC3339), formatAge(now.Sub(status.CommitTime)))
+			fmt.Fprintf(out, "Plugins: %d\n", status.Plugins)
+			if status.LastPull != nil {
+				fmt.Fprintf(out, "Last pull: %s\n", formatAge(now.Sub(*status.LastPull)))
+			} else {
+				fmt.Fprintln(out, "Last pull: never")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json)")
+	return cmd
+}
+
+// formatAge renders a duration as a coarse "N units ago" string
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+	}
+}
@@ -2,99 +2,880 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ploffredi/wpcli/internal/flags"
 	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/ploffredi/wpcli/internal/paths"
 	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/runtime"
+	"github.com/ploffredi/wpcli/internal/state"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-var rootCmd = &cobra.Command{
-	Use:   "wpcli",
-	Short: "WPStore CLI - A command line interface for managing WebAssembly plugins",
-	Long: `WPStore CLI is a command line interface for managing WebAssembly plugins.
+// historicalAnnotation marks builtin commands that can render a historical registry snapshot
+const historicalAnnotation = "wpcli/historical"
+
+// app holds the dependencies and the state shared by the commands of one root command
+type app struct {
+	deps Dependencies
+	// commandLine is the command line parsed ahead of cobra, see
+	// parseCommandLine
+	commandLine *commandLine
+	// logger receives the log output of the commands, see configureLogging
+	logger *slog.Logger
+
+	// atRevision holds the commit or date requested with the --at flag
+	atRevision string
+	// offline is set with --offline and disables all network access
+	offline bool
+
+	// repository is the repository opened by openRepository. The
+	// repository is pulled at most once per run, and repositoryErr tells
+	// why it could not be.
+	repository    *git.RepoManager
+	repositoryErr error
+	// syncErr tells why the repository could not be synced when
+	// openRepository fell back to the existing clone
+	syncErr error
+	// lastUpdate describes what the pull made by openRepository changed
+	lastUpdate *git.UpdateResult
+
+	// registered holds the root level commands and groups registered
+	// from the plugin catalog, including stubs
+	registered []*cobra.Command
+	// loaded holds the registered commands built from plugin configurations
+	loaded []*cobra.Command
+	// pluginVersionErr explains why the version given with --plugin-version
+	// cannot be used, reported when the command runs
+	pluginVersionErr error
+	// languageErr explains why the language selected with --lang or
+	// WPCLI_LANG is not supported, reported when the command runs
+	languageErr error
+	// pluginDirErr explains why a plugin directory given with --plugin-dir
+	// or WPCLI_PLUGIN_DIRS cannot be loaded, reported when the command runs
+	pluginDirErr error
+	// collisions holds the root level names plugins of the catalog cannot
+	// take because wpcli or another plugin uses them
+	collisions []plugins.Collision
+	// catalog is the registry directory given with the --catalog flag of
+	// docs man, read in place of the repository
+	catalog string
+	// runErr explains why the commands of the plugin given to run cannot
+	// be built, reported when run runs
+	runErr error
+}
+
+// NewRootCommand builds the wpcli root command with its builtin commands and
+// the commands of the plugin catalog
+func NewRootCommand(deps Dependencies) *cobra.Command {
+	a := &app{deps: deps.withDefaults()}
+
+	rootCmd := &cobra.Command{
+		Use:   "wpcli",
+		Short: "WPStore CLI - A command line interface for managing WebAssembly plugins",
+		Long: `WPStore CLI is a command line interface for managing WebAssembly plugins.
 It provides functionality to interact with the wpstore git repository and manage plugins.yml.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// If no arguments are provided, show help
-		if len(args) == 0 {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Plugin commands must never execute from a historical snapshot
+			if a.atRevision != "" && cmd.Annotations[historicalAnnotation] != "true" {
+				return fmt.Errorf("%s cannot run against a historical registry snapshot (--at)", cmd.CommandPath())
+			}
+			if a.pluginVersionErr != nil {
+				return a.pluginVersionErr
+			}
+			// Completions still work, in English
+			if a.languageErr != nil && !isCompletionRequest(a.deps.Args) {
+				return a.languageErr
+			}
+			if a.pluginDirErr != nil {
+				return a.pluginDirErr
+			}
+
+			// Plugin modules are compiled once, unless --no-cache is given
+			if noCache, _ := cmd.Flags().GetBool("no-cache"); cmd.Annotations[plugins.PluginUUIDAnnotation] != "" && !noCache {
+				dirs, err := a.deps.Paths()
+				if err != nil {
+					return err
+				}
+				cmd.SetContext(runtime.WithCacheDir(cmd.Context(), dirs.CompiledShardDir(runtime.Version())))
+			}
+			return nil
+		},
+		// Unknown commands are reported together with suggestions, which
+		// match the aliases of the commands too, and running wpcli without a
+		// command shows the help
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return unknownCommandError(cmd, args[0])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
-		}
-		// If an invalid command is provided, show error
-		return fmt.Errorf("unknown command %q for %q\nRun '%s --help' for usage", args[0], cmd.CommandPath(), cmd.CommandPath())
-	},
-}
+		},
+		ValidArgsFunction: a.completeRootCommands,
+	}
+
+	rootCmd.SetArgs(a.deps.Args)
+	rootCmd.SetIn(a.deps.Stdin)
+	rootCmd.SetOut(a.deps.Stdout)
+	rootCmd.SetErr(a.deps.Stderr)
+
+	runCmd := newRunCommand(a)
+	rootCmd.AddCommand(
+		newListCommand(a),
+		newInfoCommand(a),
+		newDoctorCommand(a),
+		newRepoCommand(a),
+		newCacheCommand(a),
+		newUpdateCommand(a),
+		newVerifyCommand(a),
+		newValidateCommand(a),
+		newInstallCommand(a),
+		newUninstallCommand(a),
+		newOutdatedCommand(a),
+		newUpgradeCommand(a),
+		newSearchCommand(a),
+		newPluginCommand(a),
+		newSchemaCommand(a),
+		newDocsCommand(a),
+		runCmd,
+	)
+
+	rootCmd.PersistentFlags().BoolVar(&a.offline, "offline", false, "Use the local repository without contacting the network (or set WPCLI_OFFLINE)")
+	rootCmd.PersistentFlags().StringVar(&a.atRevision, "at", "", "Show the registry as it was at a commit or date (list and info only)")
+	rootCmd.PersistentFlags().Bool("show-hidden-flags", false, "Include hidden plugin flags in help output, for troubleshooting")
+	rootCmd.PersistentFlags().String("lang", "", "Language of plugin help and messages (or set WPCLI_LANG)")
+	if err := rootCmd.RegisterFlagCompletionFunc("lang", a.completeLanguages); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the lang flag completion: %v\n", err)
+	}
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Halt plugin modules running longer, 0 for no limit (or set plugin_timeout)")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Parse and compile plugins without the command and compiled module caches, for debugging")
+	rootCmd.PersistentFlags().Bool("allow-mount", false, "Grant plugin modules write access to their mounts without asking")
+	if err := rootCmd.PersistentFlags().MarkDeprecated("allow-mount", "write access is granted with the permissions of the plugin, use --yes"); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to deprecate the allow-mount flag: %v\n", err)
+	}
+	rootCmd.PersistentFlags().Bool("strict", false, "Reject unknown fields in plugins.yml and plugin configurations instead of warning (or set strict_config)")
+	rootCmd.PersistentFlags().Bool("yes", false, "Answer yes to all confirmation prompts, granting the permissions plugins request")
+	rootCmd.PersistentFlags().String("result-format", plugins.ResultFormatText, "Format of the results plugin modules report (valid values: text, json)")
+	if err := rootCmd.RegisterFlagCompletionFunc("result-format", cobra.FixedCompletions([]string{plugins.ResultFormatText, plugins.ResultFormatJSON}, cobra.ShellCompDirectiveNoFileComp)); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the result-format flag completion: %v\n", err)
+	}
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Print the invocation of a plugin command as JSON instead of running it")
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Fail on missing required plugin flags and plugin permissions not granted yet instead of asking")
+	rootCmd.PersistentFlags().String("plugin-version", "", "Run the given version of the plugin instead of the latest (or pin it with plugin_versions)")
+	rootCmd.PersistentFlags().StringArray("plugin-dir", nil, "Load a plugin under development from a directory with its configuration and module, can be repeated (or set WPCLI_PLUGIN_DIRS)")
+	if err := rootCmd.MarkPersistentFlagDirname("plugin-dir"); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the plugin-dir flag completion: %v\n", err)
+	}
+
+	// The same handler as plugin count flags, so --verbose can be repeated
+	verbose := &flags.Flag{Name: "verbose", Type: flags.TypeCount, Description: i18n.Text{i18n.DefaultLanguage: "Show more log output, repeat for debug output"}, Persistent: true}
+	if err := (&flags.CountFlagHandler{}).AddFlag(rootCmd, verbose); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to add the verbose flag: %v\n", err)
+	}
+	a.commandLine = parseCommandLine(a.deps.Args, rootCmd.PersistentFlags())
+	a.configureLogging()
+	a.catalog = a.catalogFlag(rootCmd)
 
-func init() {
 	// Load plugin commands
-	if err := loadPluginCommands(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to load plugin commands: %v\n", err)
+	if err := a.loadPluginCommands(rootCmd); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to load plugin commands: %v\n", err)
+	}
+	a.pluginDirErr = a.loadDevPlugins(rootCmd)
+	a.loadRunCommands(rootCmd, runCmd)
+
+	// Cobra prints the deprecation warnings of pflag to the output, where they
+	// would end up in --dry-run JSON and other results
+	warnOnStderr(rootCmd, a.deps.Stderr)
+
+	if isCompletionRequest(a.deps.Args) {
+		useCompletionDescriptions(a.registered)
+	}
+
+	if a.commandLine.enabled("show-hidden-flags") {
+		for _, cmd := range a.registered {
+			flags.ShowHidden(cmd)
+		}
 	}
 
 	// Set up command handling
-	cobra.EnableCommandSorting = false
 	rootCmd.SilenceErrors = true
 	rootCmd.SilenceUsage = true
 
-	// Configure cobra to handle invalid subcommands
-	cobra.OnInitialize(func() {
-		cobra.EnablePrefixMatching = false
-		cobra.EnableCommandSorting = false
-	})
+	return rootCmd
+}
+
+// warnOnStderr makes pflag write the warnings of cmd and its subcommands to w
+func warnOnStderr(cmd *cobra.Command, w io.Writer) {
+	cmd.Flags().SetOutput(w)
+	for _, child := range cmd.Commands() {
+		warnOnStderr(child, w)
+	}
+}
+
+// completeRootCommands completes the aliases of the commands registered from the
+// plugin catalog. Cobra already completes the command names themselves.
+func (a *app) completeRootCommands(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []cobra.Completion
+	for _, registered := range a.registered {
+		for _, alias := range registered.Aliases {
+			if strings.HasPrefix(alias, toComplete) {
+				completions = append(completions, cobra.CompletionWithDesc(alias, registered.Short))
+			}
+		}
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// isCompletionRequest reports whether the command line asks for shell completions
+func isCompletionRequest(args []string) bool {
+	return len(args) > 0 && (args[0] == cobra.ShellCompRequestCmd || args[0] == cobra.ShellCompNoDescRequestCmd)
+}
+
+// useCompletionDescriptions replaces the short description of plugin commands
+// with the one naming their origin, since cobra completes subcommands with
+// their short description
+func useCompletionDescriptions(commands []*cobra.Command) {
+	for _, cmd := range commands {
+		if description, ok := cmd.Annotations[plugins.CompletionAnnotation]; ok {
+			cmd.Short = description
+		}
+		useCompletionDescriptions(cmd.Commands())
+	}
 }
 
-func loadPluginCommands() error {
-	homeDir, err := os.UserHomeDir()
+// openRepository clones or updates the local copy of the wpstore repository.
+// In offline mode, or when the repository cannot be synced, the existing
+// clone is used as is.
+func (a *app) openRepository() (*git.RepoManager, error) {
+	if a.repository != nil {
+		return a.repository, nil
+	}
+	if a.repositoryErr != nil {
+		return nil, a.repositoryErr
+	}
+	if a.catalog != "" {
+		return a.openCatalog()
+	}
+
+	if a.isOffline() {
+		return a.openLocalRepository()
+	}
+
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dirs.Ensure(); err != nil {
+		return nil, err
+	}
+
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := a.retryPolicy(settings)
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
 
-	basePath := filepath.Join(homeDir, ".wpcli")
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return fmt.Errorf("failed to create base directory: %w", err)
+	st, err := state.Load(state.Path(dirs.ConfigDir))
+	if err != nil {
+		return nil, err
 	}
 
-	repoManager := git.NewRepoManager(basePath)
+	repoManager := a.deps.Repository(dirs, settings)
+	repoManager.SetProgress(a.deps.Stderr)
+	repoManager.SetRetryPolicy(policy)
+	repoManager.SetRef(settings.RepositoryRef)
+	repoManager.SetMirrors(settings.MirrorRepositories)
+	repoManager.PreferSource(st.Source)
 	if err := repoManager.Clone(); err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+		return a.openUnsyncedRepository(fmt.Errorf("failed to clone repository: %w", err))
 	}
 
-	if err := repoManager.Pull(); err != nil {
-		return fmt.Errorf("failed to pull repository: %w", err)
+	update, err := repoManager.Pull()
+	if err != nil {
+		return a.openUnsyncedRepository(fmt.Errorf("failed to pull repository: %w", err))
 	}
 
-	// Load plugin commands
-	pluginCommands, err := plugins.GetPluginCommands(filepath.Join(repoManager.GetRepoPath(), "plugins.yml"))
+	a.repository = repoManager
+	a.lastUpdate = update
+	if repoManager.IsLocal() {
+		return repoManager, nil
+	}
+
+	if update.BranchChanged() {
+		if settings.RepositoryRef != "" {
+			a.logger.Info("switched the registry branch to repository_ref", "from", update.OldBranch, "to", update.NewBranch)
+		} else {
+			a.logger.Info("the registry default branch changed, now following it", "from", update.OldBranch, "to", update.NewBranch)
+		}
+	}
+
+	if settings.AutoPrune {
+		if pruned, err := a.pruneOrphans(repoManager, false); err != nil {
+			fmt.Fprintf(a.deps.Stderr, "Warning: failed to prune plugin directories: %v\n", err)
+		} else if len(pruned) > 0 {
+			fmt.Fprintf(a.deps.Stderr, "Pruned %d plugin directories no longer in the catalog\n", len(pruned))
+		}
+		if pruned, err := a.pruneCompiledShards(false); err != nil {
+			fmt.Fprintf(a.deps.Stderr, "Warning: failed to prune compiled modules: %v\n", err)
+		} else if len(pruned) > 0 {
+			fmt.Fprintf(a.deps.Stderr, "Pruned the compiled modules of %d other runtime versions\n", len(pruned))
+		}
+	}
+
+	st.LastPull = a.deps.Now()
+	st.Source = repoManager.Source()
+	if err := st.Save(); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to record pull: %v\n", err)
+	}
+
+	return repoManager, nil
+}
+
+// openUnsyncedRepository falls back to the existing clone when the
+// repository cannot be synced, so commands keep working with the catalog
+// last pulled. Without a clone, syncErr is returned.
+func (a *app) openUnsyncedRepository(syncErr error) (*git.RepoManager, error) {
+	repoManager, err := a.openLocalRepository()
+	if err != nil {
+		a.repositoryErr = syncErr
+		return nil, syncErr
+	}
+	a.logger.Warn("using the local copy of the registry, which cannot be synced", "error", syncErr)
+	a.repository = repoManager
+	a.syncErr = syncErr
+	return repoManager, nil
+}
+
+// openLocalRepository opens the existing clone without any network access
+func (a *app) openLocalRepository() (*git.RepoManager, error) {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	repoManager := a.deps.Repository(dirs, settings)
+	repoManager.SetProgress(a.deps.Stderr)
+	if err := repoManager.Open(); err != nil {
+		return nil, err
+	}
+
+	return repoManager, nil
+}
+
+// openCatalog opens the registry given with --catalog, a directory or its
+// plugins.yml, in place and without network access
+func (a *app) openCatalog() (*git.RepoManager, error) {
+	path, err := filepath.Abs(a.catalog)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+
+	repoManager := git.NewRepoManager("", path)
+	if err := repoManager.Open(); err != nil {
+		a.repositoryErr = fmt.Errorf("failed to open the catalog: %w", err)
+		return nil, a.repositoryErr
+	}
+	a.repository = repoManager
+	return repoManager, nil
+}
+
+// catalogFlag returns the registry given with the --catalog flag of docs
+// man, if the command line runs it
+func (a *app) catalogFlag(rootCmd *cobra.Command) string {
+	positional := a.commandLine.positional
+	if len(positional) < 2 || positional[0] != "docs" || positional[1] != "man" {
+		return ""
+	}
+	manCmd, _, err := rootCmd.Find([]string{"docs", "man"})
+	if err != nil {
+		return ""
+	}
+	return parseCommandLine(a.deps.Args, rootCmd.PersistentFlags(), manCmd.LocalFlags()).value("catalog")
+}
+
+// isOffline reports whether offline mode was requested
+func (a *app) isOffline() bool {
+	if a.offline || a.commandLine.enabled("offline") {
+		return true
+	}
+
+	if value := a.deps.Getenv("WPCLI_OFFLINE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		return err != nil || enabled
+	}
+
+	return false
+}
+
+// language returns the language of plugin help and messages, selected with
+// --lang, then WPCLI_LANG, then the default_language setting. A language missing from the supported_languages setting is reported when the
+// command runs, and English is used to build the commands meanwhile.
+func (a *app) language() (string, error) {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return "", err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return "", err
+	}
+
+	// Texts without the selected language fall back to the default one
+	i18n.SetFallbackLanguage(settings.DefaultLanguage)
+
+	language, source := i18n.DefaultLanguage, ""
+	if value := a.commandLine.value("lang"); value != "" {
+		language, source = value, "--lang"
+	} else if value := a.deps.Getenv("WPCLI_LANG"); value != "" {
+		language, source = value, "WPCLI_LANG"
+	} else if settings.DefaultLanguage != "" {
+		language, source = settings.DefaultLanguage, "default_language"
+	}
+
+	if source != "" {
+		if err := checkLanguage(settings, language, source); err != nil {
+			a.languageErr = err
+			return i18n.DefaultLanguage, nil
+		}
+	}
+	return language, nil
+}
+
+// checkLanguage rejects a language missing from the supported_languages
+// setting, naming where it comes from
+func checkLanguage(settings *plugins.Settings, language, source string) error {
+	if len(settings.SupportedLanguages) > 0 && !slices.Contains(settings.SupportedLanguages, language) {
+		return fmt.Errorf("unsupported language %s (from %s), supported languages are: %s", language, source, strings.Join(settings.SupportedLanguages, ", "))
+	}
+	return nil
+}
+
+// completeLanguages completes --lang with the supported_languages setting
+func (a *app) completeLanguages(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []cobra.Completion
+	for _, language := range settings.SupportedLanguages {
+		if strings.HasPrefix(language, toComplete) {
+			completions = append(completions, cobra.CompletionWithDesc(language, i18n.LanguageName(language)))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// snapshotDetails marks the json and yaml output read from the snapshot
+// selected with --at
+type snapshotDetails struct {
+	Ref    string    `json:"ref" yaml:"ref"`
+	Commit string    `json:"commit" yaml:"commit"`
+	Time   time.Time `json:"time" yaml:"time"`
+}
+
+// historical returns the details of the snapshot selected with --at, or nil
+// for the current catalog
+func (a *app) historical(snapshot *git.Snapshot) *snapshotDetails {
+	if snapshot == nil {
+		return nil
+	}
+	return &snapshotDetails{Ref: a.atRevision, Commit: snapshot.Hash(), Time: snapshot.Time()}
+}
+
+// newConfigManager returns the manager of the plugins.yml of a repository,
+// warning about it through the logger of the app
+func (a *app) newConfigManager(repoPath string) *plugins.ConfigManager {
+	configManager := plugins.NewConfigManager(repoPath)
+	configManager.SetLogger(a.logger)
+	return configManager
+}
+
+// loadCatalog loads plugins.yml from the working tree, or from the commit
+// selected with --at. The returned snapshot is nil for the current catalog.
+func (a *app) loadCatalog(repoManager *git.RepoManager) (*plugins.ConfigManager, *git.Snapshot, error) {
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return nil, nil, err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configManager := a.newConfigManager(repoManager.GetRepoPath())
+	configManager.SetStrict(a.commandLine.enabled("strict") || settings.StrictConfig)
+	if a.atRevision == "" {
+		if err := configManager.Load(); err != nil {
+			return nil, nil, fmt.Errorf("failed to load plugins configuration: %w", err)
+		}
+		return configManager, nil, nil
+	}
+
+	snapshot, err := repoManager.SnapshotAt(a.atRevision)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve --at %s: %w", a.atRevision, err)
+	}
+
+	data, err := snapshot.ReadFile("plugins.yml")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := configManager.Parse(data); err != nil {
+		return nil, nil, fmt.Errorf("failed to load plugins configuration: %w", err)
+	}
+
+	return configManager, snapshot, nil
+}
+
+func (a *app) loadPluginCommands(rootCmd *cobra.Command) error {
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return err
+	}
+
+	reserved := a.reservedNames(rootCmd)
+
+	language, err := a.language()
+	if err != nil {
+		return err
+	}
+
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return err
+	}
+
+	if err := setDefaultTimeout(rootCmd, settings); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: %v\n", err)
+	}
+	if a.commandLine.enabled("strict") {
+		strict := *settings
+		strict.StrictConfig = true
+		settings = &strict
+	}
+
+	// Plugin commands start as stubs built from plugins.yml, and only the
+	// plugins of the command being run, or asked help or completions for,
+	// are read and built
+	configPath := filepath.Join(repoManager.GetRepoPath(), "plugins.yml")
+	cache := a.commandCache(repoManager, dirs, settings)
+	stubs, collisions, err := plugins.GetCommandStubs(a.logger, configPath, cache, reserved, language, settings)
 	if err != nil {
 		return fmt.Errorf("failed to load plugin commands: %w", err)
 	}
+	for _, stub := range stubs {
+		rootCmd.AddCommand(stub)
+		a.registered = append(a.registered, stub)
+	}
+	a.collisions = collisions
+	for _, collision := range collisions {
+		fmt.Fprintf(a.deps.Stderr, "Warning: %s\n", collision)
+	}
 
-	// Create a map of existing command names to avoid duplicates
-	existingCommands := make(map[string]bool)
+	// The aliases of single command groups are root level commands, which
+	// takes building the commands of every plugin, and so does describing
+	// them all
+	var uuids []string
+	var skipped []plugins.LoadError
+	buildAll := settings.CollapseSingleCommandGroups || a.describesPlugins()
+	if !buildAll {
+		uuids = a.invokedPlugins(rootCmd)
+	}
+	if len(uuids) > 0 || buildAll {
+		if skipped, err = a.addPluginCommands(rootCmd, configPath, cache, reserved, language, settings, uuids); err != nil {
+			return err
+		}
+	}
+
+	// The plugin --plugin-version applies to is the one of the command being
+	// run, which is only known once the commands are registered. Run
+	// applies it to the plugin it runs.
+	_, running := a.runArgs()
+	if requested := a.commandLine.value("plugin-version"); requested != "" && !running {
+		versions, err := a.selectPluginVersion(rootCmd, requested, settings.PluginVersions)
+		if err != nil {
+			a.pluginVersionErr = err
+		} else {
+			for _, cmd := range a.loaded {
+				a.unregister(rootCmd, cmd)
+			}
+			selected := *settings
+			selected.PluginVersions = versions
+			if skipped, err = a.addPluginCommands(rootCmd, configPath, cache, reserved, language, &selected, uuids); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The other plugin commands remain usable. Parse errors span several
+	// lines, which doctor shows in full.
+	for _, err := range skipped {
+		message, _, _ := strings.Cut(err.Error(), "\n")
+		if err.Command == "" {
+			fmt.Fprintf(a.deps.Stderr, "Warning: skipping plugin: %s\n", message)
+		} else {
+			fmt.Fprintf(a.deps.Stderr, "Warning: skipping plugin command: %s\n", message)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintln(a.deps.Stderr, "Run 'wpcli doctor' for details on the plugins that failed to load")
+	}
+
+	if err := a.addUpdateHints(a.registered); err != nil {
+		fmt.Fprintf(a.deps.Stderr, "Warning: failed to check for plugin updates: %v\n", err)
+	}
+
+	return nil
+}
+
+// loadDevPlugins registers the commands of the plugins in the directories of
+// WPCLI_PLUGIN_DIRS and then of --plugin-dir, after the catalog ones. They
+// replace the root level plugin commands of the same name, including those
+// of earlier directories. It returns why a directory cannot be loaded.
+func (a *app) loadDevPlugins(rootCmd *cobra.Command) error {
+	pluginDirs := filepath.SplitList(a.deps.Getenv("WPCLI_PLUGIN_DIRS"))
+	pluginDirs = append(pluginDirs, a.commandLine.values("plugin-dir")...)
+	if len(pluginDirs) == 0 {
+		return nil
+	}
+
+	reserved := a.reservedNames(rootCmd)
+	language, err := a.language()
+	if err != nil {
+		return err
+	}
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return err
+	}
+	if a.commandLine.enabled("strict") {
+		strict := *settings
+		strict.StrictConfig = true
+		settings = &strict
+	}
+
+	for _, dir := range pluginDirs {
+		commands, err := plugins.GetDevPluginCommands(a.logger, dir, reserved, language, settings)
+		if err != nil {
+			return fmt.Errorf("failed to load the plugin in %s: %w", dir, err)
+		}
+		for _, cmd := range commands {
+			for _, registered := range slices.Clone(a.registered) {
+				if registered.Name() == cmd.Name() {
+					a.unregister(rootCmd, registered)
+				}
+			}
+			rootCmd.AddCommand(cmd)
+			a.registered = append(a.registered, cmd)
+		}
+	}
+	return nil
+}
+
+// reservedNames returns the names wpcli already uses, which plugin commands
+// cannot take: those of the builtin commands and of the root flags
+func (a *app) reservedNames(rootCmd *cobra.Command) plugins.Reserved {
+	reserved := plugins.Reserved{
+		// The help flag cobra adds to every command
+		Flags:      []string{"help"},
+		Shorthands: []string{"h"},
+	}
 	for _, cmd := range rootCmd.Commands() {
-		existingCommands[strings.Fields(cmd.Use)[0]] = true
+		if !slices.Contains(a.registered, cmd) {
+			reserved.Commands = append(reserved.Commands, cmd.Name())
+		}
+	}
+	rootCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		// Plugins can declare their own --dry-run, which their commands
+		// receive instead of the one of wpcli
+		if flag.Name == "dry-run" {
+			return
+		}
+		reserved.Flags = append(reserved.Flags, flag.Name)
+		if flag.Shorthand != "" {
+			reserved.Shorthands = append(reserved.Shorthands, flag.Shorthand)
+		}
+	})
+	return reserved
+}
+
+// commandCache opens the cache of the plugin commands parsed from the
+// repository HEAD, removing the caches of other commits. There is none with
+// --no-cache, with --strict, which parses every file, or when the repository
+// is not a git repository.
+func (a *app) commandCache(repoManager *git.RepoManager, dirs *paths.Paths, settings *plugins.Settings) *plugins.CommandCache {
+	if a.commandLine.enabled("no-cache") || settings.StrictConfig {
+		return nil
+	}
+	commit, err := repoManager.HeadCommit()
+	if err != nil {
+		a.logger.Debug("not caching the plugin commands", "error", err)
+		return nil
+	}
+
+	path := dirs.CommandCacheFile(commit)
+	stale, err := dirs.CommandCacheFiles()
+	if err != nil {
+		a.logger.Debug("failed to list the plugin command caches", "error", err)
+	}
+	for _, file := range stale {
+		if file != path {
+			os.Remove(file)
+		}
+	}
+	return plugins.OpenCommandCache(a.logger, path, repoManager.GetRepoPath(), commit)
+}
+
+// invokedPlugins returns the plugins of the stub command the command line
+// runs, asks help for or completes, if any
+func (a *app) invokedPlugins(rootCmd *cobra.Command) []string {
+	args := a.deps.Args
+	if isCompletionRequest(args) || (len(args) > 0 && args[0] == "help") {
+		args = args[1:]
 	}
 
-	// Add plugin commands to root command
+	target, _, err := rootCmd.Find(args)
+	if err != nil {
+		return nil
+	}
+	return plugins.StubPlugins(target)
+}
+
+// describingCommands are the commands describing the commands of every
+// plugin, which are all built when they run
+var describingCommands = [][]string{{"schema", "export"}, {"docs", "generate"}, {"docs", "man"}}
+
+// describesPlugins reports whether the command line runs one of the
+// describingCommands
+func (a *app) describesPlugins() bool {
+	if isCompletionRequest(a.deps.Args) {
+		return false
+	}
+	positional := a.commandLine.positional
+	return slices.ContainsFunc(describingCommands, func(path []string) bool {
+		return len(positional) >= len(path) && slices.Equal(positional[:len(path)], path)
+	})
+}
+
+// addPluginCommands registers the commands of the plugins with the given
+// UUIDs, or of all plugins when there is none, in place of their stubs. The
+// commands are built with the given settings and their version selection.
+// It returns why plugins or commands were skipped.
+func (a *app) addPluginCommands(rootCmd *cobra.Command, configPath string, cache *plugins.CommandCache, reserved plugins.Reserved, language string, settings *plugins.Settings, uuids []string) ([]plugins.LoadError, error) {
+	pluginCommands, skipped, err := plugins.GetPluginCommands(a.logger, configPath, cache, reserved, language, settings, uuids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin commands: %w", err)
+	}
+
+	for _, cmd := range slices.Clone(a.registered) {
+		stubbed := plugins.StubPlugins(cmd)
+		if stubbed != nil && (len(uuids) == 0 || slices.ContainsFunc(stubbed, func(uuid string) bool { return slices.Contains(uuids, uuid) })) {
+			a.unregister(rootCmd, cmd)
+		}
+	}
+
+	// Builtin commands take precedence, and so does the first plugin
+	// command of a name
+	existingCommands := make(map[string]bool)
+	for _, name := range reserved.Commands {
+		existingCommands[name] = true
+	}
+	for _, cmd := range a.registered {
+		existingCommands[cmd.Name()] = true
+	}
 	for _, cmd := range pluginCommands {
-		// Skip if command already exists
 		cmdName := strings.Fields(cmd.Use)[0]
 		if existingCommands[cmdName] {
 			continue
 		}
 		existingCommands[cmdName] = true
 		rootCmd.AddCommand(cmd)
+		a.registered = append(a.registered, cmd)
+		a.loaded = append(a.loaded, cmd)
 	}
 
-	return nil
+	return skipped, nil
+}
+
+// unregister removes a plugin command from the root command
+func (a *app) unregister(rootCmd *cobra.Command, cmd *cobra.Command) {
+	rootCmd.RemoveCommand(cmd)
+	a.registered = slices.DeleteFunc(a.registered, func(registered *cobra.Command) bool { return registered == cmd })
+	a.loaded = slices.DeleteFunc(a.loaded, func(loaded *cobra.Command) bool { return loaded == cmd })
 }
 
-func Execute() error {
-	if err := rootCmd.Execute(); err != nil {
-		// Print the error message and exit with code 1 for any error
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+// selectPluginVersion returns the version selection with the version given
+// with --plugin-version for the plugin of the command being run
+func (a *app) selectPluginVersion(rootCmd *cobra.Command, requested string, pinned map[string]string) (map[string]string, error) {
+	target, _, err := rootCmd.Find(a.deps.Args)
+	if err != nil || target.Annotations[plugins.PluginUUIDAnnotation] == "" {
+		return nil, fmt.Errorf("--plugin-version only applies to plugin commands")
 	}
-	return nil
+	uuid := target.Annotations[plugins.PluginUUIDAnnotation]
+
+	configManager := a.newConfigManager(a.repository.GetRepoPath())
+	if err := configManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load plugins configuration: %w", err)
+	}
+	plugin, err := configManager.GetPluginByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{uuid: requested}
+	if _, err := plugins.SelectVersion(*plugin, versions); err != nil {
+		return nil, err
+	}
+
+	// Pins of the other plugins still apply
+	for plugin, version := range pinned {
+		if _, ok := versions[plugin]; !ok {
+			versions[plugin] = version
+		}
+	}
+	return versions, nil
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ploffredi/wpcli/internal/paths"
+	"github.com/spf13/cobra"
+)
+
+// testRoot is a root command built over its own registry and home directory
+type testRoot struct {
+	cmd    *cobra.Command
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+// newTestRoot builds a root command for the given arguments over a registry
+// with a single plugin, named after name, providing the command name
+func newTestRoot(t *testing.T, name string, args ...string) *testRoot {
+	t.Helper()
+	dir := t.TempDir()
+
+	registry := filepath.Join(dir, "registry")
+	writeTestFile(t, filepath.Join(registry, "plugins.yml"), `plugins:
+  - name: `+name+`-plugin
+    description: The `+name+` plugin
+    uuid: `+name+`-uuid
+    versions:
+      - version: 1.0.0
+        conf: `+name+`.yml
+`)
+	writeTestFile(t, filepath.Join(registry, name+"-uuid", "1.0.0", name+".yml"), `commands:
+  - name: `+name+`
+    description: Run `+name+`
+    usage: wpcli `+name+`
+`)
+	writeTestFile(t, filepath.Join(dir, "config", "config.yml"), "settings:\n  default_repository: "+registry+"\n")
+
+	root := &testRoot{stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}}
+	root.cmd = NewRootCommand(Dependencies{
+		Paths: func() (*paths.Paths, error) {
+			return &paths.Paths{CacheDir: filepath.Join(dir, "cache"), ConfigDir: filepath.Join(dir, "config")}, nil
+		},
+		Getenv: func(string) string { return "" },
+		Args:   args,
+		Stdin:  strings.NewReader(""),
+		Stdout: root.stdout,
+		Stderr: root.stderr,
+	})
+	return root
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRootCommandsAreIndependent(t *testing.T) {
+	// Both roots are built before either runs, so building the second one
+	// must not change the first one. The alpha plugin has no module, so
+	// running its command only logs it at the debug level.
+	alpha := newTestRoot(t, "alpha", "--verbose", "--verbose", "alpha")
+	beta := newTestRoot(t, "beta", "list")
+
+	for _, root := range []*testRoot{alpha, beta} {
+		if err := root.cmd.Execute(); err != nil {
+			t.Fatalf("%v\nstderr: %s", err, root.stderr)
+		}
+	}
+
+	if !strings.Contains(beta.stdout.String(), "Name: beta-plugin") || strings.Contains(beta.stdout.String(), "alpha") {
+		t.Errorf("beta does not list its own registry only:\n%s", beta.stdout)
+	}
+
+	// Each root logs to its own stderr, at its own level
+	if !strings.Contains(alpha.stderr.String(), `msg="Executing: alpha --verbose=2" plugin=alpha-plugin`) {
+		t.Errorf("alpha did not log running its command:\n%s", alpha.stderr)
+	}
+	if beta.stderr.Len() != 0 {
+		t.Errorf("beta has log output of its own or of alpha:\n%s", beta.stderr)
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+func newRunCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <plugin> <command> [args...]",
+		Short: "Run a command of a plugin given by name or UUID",
+		Long: `Run a command of a plugin given by name or UUID, with its arguments and flags,
+as in 'wpcli run greet-plugin greet Maria --formal'. Only the configuration of
+that plugin is read, and its commands are found whatever names other plugins
+or wpcli use, or whatever group they are in.
+
+Scripts can rely on this form, which does not change with the plugins of the
+catalog.`,
+		// The commands of the plugin are added below this one before the
+		// command line is parsed, so arguments only get here without them
+		Args: func(cmd *cobra.Command, args []string) error {
+			if a.runErr != nil {
+				return a.runErr
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("requires a plugin name or UUID, and a command")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		ValidArgsFunction: a.completeRunPlugins,
+	}
+}
+
+// completeRunPlugins completes the names of the plugins of the catalog
+func (a *app) completeRunPlugins(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	configManager := a.newConfigManager(repoManager.GetRepoPath())
+	if err := configManager.Load(); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// An unsupported language is reported when a command runs
+	language, _ := a.language()
+	var completions []cobra.Completion
+	for _, plugin := range configManager.GetPlugins() {
+		if strings.HasPrefix(plugin.Name, toComplete) {
+			completions = append(completions, cobra.CompletionWithDesc(plugin.Name, plugin.GetDescription(language)))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// runArgs returns the positional arguments of the command line after run,
+// and whether the command line invokes run at all
+func (a *app) runArgs() ([]string, bool) {
+	positional := a.commandLine.positional
+	if isCompletionRequest(a.deps.Args) {
+		positional = positional[1:]
+	}
+	if len(positional) == 0 || positional[0] != "run" {
+		return nil, false
+	}
+	return positional[1:], true
+}
+
+// loadRunCommands adds the commands of the plugin the command line runs with
+// run below it, in a group named as the plugin is given. Why they cannot be
+// added is reported when run runs.
+func (a *app) loadRunCommands(rootCmd, runCmd *cobra.Command) {
+	args, ok := a.runArgs()
+	if !ok || len(args) == 0 {
+		return
+	}
+	// The plugin name being completed is not complete yet
+	if isCompletionRequest(a.deps.Args) && len(args) == 1 {
+		return
+	}
+	a.runErr = a.addRunCommands(rootCmd, runCmd, args[0])
+}
+
+// addRunCommands builds the commands of a plugin given by name or UUID for
+// run, and adds them below it
+func (a *app) addRunCommands(rootCmd, runCmd *cobra.Command, name string) error {
+	repoManager, err := a.openRepository()
+	if err != nil {
+		return err
+	}
+
+	reserved := a.reservedNames(rootCmd)
+	language, err := a.language()
+	if err != nil {
+		return err
+	}
+	dirs, err := a.deps.Paths()
+	if err != nil {
+		return err
+	}
+	settings, err := a.deps.Settings(dirs)
+	if err != nil {
+		return err
+	}
+	if a.commandLine.enabled("strict") {
+		strict := *settings
+		strict.StrictConfig = true
+		settings = &strict
+	}
+
+	configManager := a.newConfigManager(repoManager.GetRepoPath())
+	configManager.SetStrict(settings.StrictConfig)
+	if err := configManager.Load(); err != nil {
+		return fmt.Errorf("failed to load plugins configuration: %w", err)
+	}
+	plugin, err := configManager.GetPlugin(name)
+	if err != nil {
+		var ambiguous *plugins.AmbiguousPluginError
+		if errors.As(err, &ambiguous) {
+			return err
+		}
+		return unknownPluginError(runCmd, name, configManager.GetPlugins())
+	}
+
+	// --plugin-version applies to the plugin run
+	if requested := a.commandLine.value("plugin-version"); requested != "" {
+		if _, err := plugins.SelectVersion(*plugin, map[string]string{plugin.UUID: requested}); err != nil {
+			return err
+		}
+		selected := *settings
+		selected.PluginVersions = maps.Clone(settings.PluginVersions)
+		if selected.PluginVersions == nil {
+			selected.PluginVersions = make(map[string]string)
+		}
+		selected.PluginVersions[plugin.UUID] = requested
+		settings = &selected
+	}
+
+	configPath := filepath.Join(repoManager.GetRepoPath(), "plugins.yml")
+	cache := a.commandCache(repoManager, dirs, settings)
+	commands, skipped, err := plugins.GetRunCommands(a.logger, configPath, cache, plugin.UUID, reserved, language, settings)
+	if err != nil {
+		return err
+	}
+	for _, err := range skipped {
+		message, _, _ := strings.Cut(err.Error(), "\n")
+		fmt.Fprintf(a.deps.Stderr, "Warning: skipping plugin command: %s\n", message)
+	}
+
+	group := &cobra.Command{
+		Use:   name,
+		Short: plugin.GetDescription(language),
+		Long:  fmt.Sprintf("Commands of plugin %s (%s)", plugin.Name, plugin.UUID),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return unknownCommandError(cmd, args[0])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	for _, cmd := range commands {
+		if a.commandLine.enabled("show-hidden-flags") {
+			flags.ShowHidden(cmd)
+		}
+		group.AddCommand(cmd)
+	}
+	runCmd.AddCommand(group)
+	return nil
+}
+
+// unknownPluginError reports a plugin missing from the catalog, with the
+// names of the plugins whose name is close to, or starts with, the given one
+func unknownPluginError(runCmd *cobra.Command, name string, catalog []plugins.Plugin) error {
+	message := fmt.Sprintf("unknown plugin %q for %q", name, runCmd.CommandPath())
+
+	var suggestions []string
+	for _, plugin := range catalog {
+		candidate := strings.ToLower(plugin.Name)
+		if levenshtein(strings.ToLower(name), candidate) <= 2 || strings.HasPrefix(candidate, strings.ToLower(name)) {
+			suggestions = append(suggestions, plugin.Name)
+		}
+	}
+	if len(suggestions) > 0 {
+		message += "\n\nDid you mean this?\n"
+		for _, suggestion := range suggestions {
+			message += fmt.Sprintf("\t%v\n", suggestion)
+		}
+	}
+	return fmt.Errorf("%s", message)
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/version"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// schemaVersion is the version of the output of schema export, raised when
+// a field is removed or changes meaning
+const schemaVersion = 1
+
+// commandSchema is the output of schema export
+type commandSchema struct {
+	SchemaVersion int            `json:"schema_version" yaml:"schema_version"`
+	WpcliVersion  string         `json:"wpcli_version" yaml:"wpcli_version"`
+	Commands      []commandEntry `json:"commands" yaml:"commands"`
+}
+
+// commandEntry is a command of the command tree, named by its full path
+type commandEntry struct {
+	Path        string       `json:"path" yaml:"path"`
+	Aliases     []string     `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Builtin     bool         `json:"builtin" yaml:"builtin"`
+	Hidden      bool         `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Description i18n.Text    `json:"description" yaml:"description"`
+	Args        []argEntry   `json:"args,omitempty" yaml:"args,omitempty"`
+	Flags       []flagEntry  `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Plugin      *pluginEntry `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+}
+
+// argEntry is a positional argument of a command
+type argEntry struct {
+	Name        string    `json:"name" yaml:"name"`
+	Type        string    `json:"type" yaml:"type"`
+	Required    bool      `json:"required" yaml:"required"`
+	Variadic    bool      `json:"variadic,omitempty" yaml:"variadic,omitempty"`
+	Description i18n.Text `json:"description,omitempty" yaml:"description,omitempty"`
+	ValidValues []string  `json:"valid_values,omitempty" yaml:"valid_values,omitempty"`
+}
+
+// flagEntry is a flag defined on a command. Persistent flags apply to the
+// commands below it too.
+type flagEntry struct {
+	Name        string    `json:"name" yaml:"name"`
+	Shorthand   string    `json:"shorthand,omitempty" yaml:"shorthand,omitempty"`
+	Type        string    `json:"type" yaml:"type"`
+	Description i18n.Text `json:"description" yaml:"description"`
+	Default     string    `json:"default,omitempty" yaml:"default,omitempty"`
+	ValidValues []string  `json:"valid_values,omitempty" yaml:"valid_values,omitempty"`
+	Env         string    `json:"env,omitempty" yaml:"env,omitempty"`
+	Required    bool      `json:"required,omitempty" yaml:"required,omitempty"`
+	Persistent  bool      `json:"persistent,omitempty" yaml:"persistent,omitempty"`
+	Hidden      bool      `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Deprecated  string    `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+}
+
+// pluginEntry is the plugin version a command comes from
+type pluginEntry struct {
+	Name    string `json:"name" yaml:"name"`
+	UUID    string `json:"uuid" yaml:"uuid"`
+	Version string `json:"version" yaml:"version"`
+}
+
+func newSchemaCommand(a *app) *cobra.Command {
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Describe the commands of wpcli for tools",
+		Long:  `Describe the commands wpcli and its plugins provide, for tools such as editors and documentation sites`,
+	}
+
+	schemaCmd.AddCommand(newSchemaExportCommand(a))
+	return schemaCmd
+}
+
+func newSchemaExportCommand(a *app) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the command tree with its arguments and flags",
+		Long: `Print every command wpcli runs, builtin or from a plugin, with its
+descriptions in each language, its arguments and flags, and the plugin version
+it comes from. The commands of every plugin of the catalog are built, with the
+selected versions, and so are those of the plugin directories given.
+
+The output has a schema_version, raised when a field is removed or changes
+meaning; new fields can appear without it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "json" && output != "yaml" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: json, yaml", output)
+			}
+
+			schema := commandSchema{
+				SchemaVersion: schemaVersion,
+				WpcliVersion:  version.Current(),
+			}
+			commands, err := a.schemaEntries(cmd.Root(), true)
+			if err != nil {
+				return err
+			}
+			schema.Commands = commands
+
+			if output == "json" {
+				return writeJSON(cmd.OutOrStdout(), schema)
+			}
+			return writeYAML(cmd.OutOrStdout(), schema)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "json", "Output format (valid values: json, yaml)")
+	return cmd
+}
+
+// schemaEntries describes a command and the commands below it. The commands
+// registered from plugins and their subcommands are not builtin; stubs of
+// plugins whose commands could not be built are left out.
+func (a *app) schemaEntries(cmd *cobra.Command, builtin bool) ([]commandEntry, error) {
+	if plugins.StubPlugins(cmd) != nil {
+		return nil, nil
+	}
+
+	entry := commandEntry{
+		Path:        cmd.CommandPath(),
+		Aliases:     cmd.Aliases,
+		Builtin:     builtin,
+		Hidden:      cmd.Hidden,
+		Description: i18n.Text{i18n.DefaultLanguage: cmd.Short},
+		Args:        usageArgs(cmd.Use),
+	}
+
+	// Plugin commands are described from their configuration, and plugin
+	// groups from the plugin level flags of the plugins in them
+	pluginConfig, cmdConfig, err := plugins.CommandConfig(a.logger, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", cmd.CommandPath(), err)
+	}
+	var pluginFlags []*flags.Flag
+	if !builtin && cmdConfig == nil {
+		if pluginFlags, err = a.groupPluginFlags(cmd); err != nil {
+			return nil, fmt.Errorf("failed to describe %s: %w", cmd.CommandPath(), err)
+		}
+	}
+	if cmdConfig != nil {
+		entry.Description = cmdConfig.Description
+		if len(cmdConfig.Args) > 0 {
+			entry.Args = nil
+			for _, arg := range cmdConfig.Args {
+				argType := arg.Type
+				if argType == "" {
+					argType = "string"
+				}
+				entry.Args = append(entry.Args, argEntry{
+					Name:        arg.Name,
+					Type:        argType,
+					Required:    arg.Required,
+					Description: arg.Description,
+					ValidValues: arg.ValidValues,
+				})
+			}
+		}
+		pluginFlags = append(slices.Clone(pluginConfig.Flags), cmdConfig.Flags...)
+		entry.Plugin = &pluginEntry{
+			Name:    cmd.Annotations[plugins.PluginNameAnnotation],
+			UUID:    cmd.Annotations[plugins.PluginUUIDAnnotation],
+			Version: cmd.Annotations[plugins.PluginVersionAnnotation],
+		}
+	}
+
+	cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		// Cobra adds the help flag to the commands it runs only
+		if flag.Name == "help" {
+			return
+		}
+		entry.Flags = append(entry.Flags, schemaFlag(cmd, flag, pluginFlags))
+	})
+
+	entries := []commandEntry{entry}
+	for _, child := range cmd.Commands() {
+		childBuiltin := builtin && !slices.Contains(a.registered, child)
+		childEntries, err := a.schemaEntries(child, childBuiltin)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, childEntries...)
+	}
+	return entries, nil
+}
+
+// groupPluginFlags returns the plugin level flags of the plugins whose
+// commands are in a group command, which the group defines
+func (a *app) groupPluginFlags(group *cobra.Command) ([]*flags.Flag, error) {
+	var pluginFlags []*flags.Flag
+	for _, child := range group.Commands() {
+		pluginConfig, _, err := plugins.CommandConfig(a.logger, child)
+		if err != nil {
+			return nil, err
+		}
+		if pluginConfig != nil {
+			pluginFlags = append(pluginFlags, pluginConfig.Flags...)
+		}
+	}
+	return pluginFlags, nil
+}
+
+// schemaFlag describes a flag of a command, from its plugin configuration
+// when pluginFlags declares it
+func schemaFlag(cmd *cobra.Command, flag *pflag.Flag, pluginFlags []*flags.Flag) flagEntry {
+	entry := flagEntry{
+		Name:        flag.Name,
+		Shorthand:   flag.Shorthand,
+		Type:        flag.Value.Type(),
+		Description: i18n.Text{i18n.DefaultLanguage: flag.Usage},
+		Default:     flag.DefValue,
+		Persistent:  cmd.PersistentFlags().Lookup(flag.Name) != nil,
+		Hidden:      flag.Hidden,
+		Deprecated:  flag.Deprecated,
+	}
+
+	index := slices.IndexFunc(pluginFlags, func(pluginFlag *flags.Flag) bool { return pluginFlag.CLIName() == flag.Name })
+	if index < 0 {
+		return entry
+	}
+	pluginFlag := pluginFlags[index]
+	entry.Type = string(pluginFlag.Type)
+	entry.Description = pluginFlag.Description
+	entry.Default = pluginFlag.Default
+	entry.ValidValues = pluginFlag.ValidValues
+	entry.Env = pluginFlag.Env
+	entry.Required = pluginFlag.Required
+	return entry
+}
+
+// usageArgs returns the arguments of a usage line such as
+// "info <plugin> [version] [args...]": required ones within angle brackets,
+// optional ones within square brackets, and variadic ones ending with an
+// ellipsis. Their type is unknown, so they are taken as strings.
+func usageArgs(use string) []argEntry {
+	fields := strings.Fields(use)
+	if len(fields) == 0 {
+		return nil
+	}
+	var args []argEntry
+	for _, field := range fields[1:] {
+		required := strings.HasPrefix(field, "<")
+		if !required && !strings.HasPrefix(field, "[") {
+			continue
+		}
+		variadic := strings.Contains(field, "...")
+		name := strings.Trim(strings.ReplaceAll(field, "...", ""), "<>[]")
+		if name == "flags" || name == "command" || name == "" {
+			continue
+		}
+		args = append(args, argEntry{Name: name, Type: "string", Required: required, Variadic: variadic})
+	}
+	return args
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+func newSearchCommand(a *app) *cobra.Command {
+	var fuzzy bool
+	var limit int
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search plugins by name, description and commands",
+		Long: `Search the plugins of the catalog whose name, description in any language,
+command names or command descriptions contain the query, ignoring case.
+Results are ranked, names first, and show the field that matched.
+
+With --fuzzy the characters of the query also match in order with other
+characters between them, ranked higher the closer they are.`,
+		Args: cobra.MinimumNArgs(1),
+		Annotations: map[string]string{
+			historicalAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json", output)
+			}
+			if limit < 0 {
+				return fmt.Errorf("invalid value for flag --limit: %d. It must not be negative", limit)
+			}
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+			configManager, snapshot, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+
+			// The commands of plugins the catalog does not list the commands
+			// of come from their configuration
+			catalog := configManager.GetPlugins()
+			commands := make(map[string][]plugins.PluginCommandConfig)
+			for _, plugin := range catalog {
+				if len(plugin.Commands) > 0 {
+					commands[plugin.UUID] = plugin.Commands
+					continue
+				}
+				version, err := settings.SelectVersion(plugin)
+				if err != nil {
+					version = plugin.Versions[0]
+				}
+				pluginConfig, err := a.readPluginConfig(repoManager, snapshot, plugin, version)
+				if err != nil {
+					a.logger.Debug("failed to read the commands of a plugin", "plugin", plugin.Name, "error", err)
+					continue
+				}
+				commands[plugin.UUID] = pluginConfig.Commands
+			}
+
+			matches := plugins.Search(catalog, commands, strings.Join(args, " "), fuzzy)
+			if limit > 0 && len(matches) > limit {
+				matches = matches[:limit]
+			}
+
+			if output == "json" {
+				if matches == nil {
+					matches = []plugins.SearchMatch{}
+				}
+				return writeJSON(out, matches)
+			}
+			if snapshot != nil {
+				fmt.Fprintln(out, snapshot.Label())
+				fmt.Fprintln(out)
+			}
+			if len(matches) == 0 {
+				fmt.Fprintf(out, "No plugins match %q\n", strings.Join(args, " "))
+				return nil
+			}
+
+			// Plugins sharing a name are told apart by UUID
+			duplicates := configManager.DuplicateNames()
+			highlight := isTerminal(out)
+			table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(table, "PLUGIN\tSCORE\tMATCH")
+			for _, match := range matches {
+				name := match.Plugin
+				if _, ok := duplicates[name]; ok {
+					name = fmt.Sprintf("%s (%s)", name, match.UUID)
+				}
+				fmt.Fprintf(table, "%s\t%d\t%s: %s\n", name, match.Score, match.Field, highlightMatch(match, highlight))
+			}
+			return table.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&fuzzy, "fuzzy", false, "Also match the characters of the query with gaps between them")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Show at most this many results, 0 for all")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json)")
+	return cmd
+}
+
+// highlightMatch returns the text of a match with the matching characters
+// in bold when highlight is set
+func highlightMatch(match plugins.SearchMatch, highlight bool) string {
+	if !highlight {
+		return match.Text
+	}
+
+	matching := make(map[int]bool, len(match.Positions))
+	for _, position := range match.Positions {
+		matching[position] = true
+	}
+
+	var b strings.Builder
+	for i, r := range []rune(match.Text) {
+		if matching[i] {
+			b.WriteString("\x1b[1m" + string(r) + "\x1b[0m")
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/config"
+	"github.com/ploffredi/wpcli/internal/consent"
+	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/ploffredi/wpcli/internal/paths"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// loadSettings returns the effective settings: the registry settings from the
+// local repository, when there is one, overridden by the user configuration
+func loadSettings(dirs *paths.Paths) (*plugins.Settings, error) {
+	userConfig, err := config.Load(dirs.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &plugins.Settings{}
+	if err := userConfig.ApplySettings(settings); err != nil {
+		return nil, err
+	}
+
+	// The repository location and the trust levels are only ever taken from
+	// the user configuration, which a registry cannot make more trusted
+	repository := settings.DefaultRepository
+	if repository == "" {
+		repository = git.DefaultRepositoryURL
+	}
+	repositoryTrust, pluginTrust := settings.RepositoryTrust, settings.PluginTrust
+	if err := settings.CheckTrust(); err != nil {
+		return nil, err
+	}
+
+	configManager := plugins.NewConfigManager(git.NewRepoManager(dirs.CacheDir, repository).GetRepoPath())
+	if err := configManager.Load(); err == nil {
+		settings = configManager.GetSettings()
+		if err := userConfig.ApplySettings(settings); err != nil {
+			return nil, err
+		}
+	}
+
+	settings.DefaultRepository = repository
+	settings.RepositoryTrust, settings.PluginTrust = repositoryTrust, pluginTrust
+	if settings.InstallDir == "" {
+		settings.InstallDir = dirs.PluginsDir()
+	}
+
+	st, err := state.Load(state.Path(dirs.ConfigDir))
+	if err != nil {
+		return nil, err
+	}
+	settings.Installed = st.Installed
+	settings.ConsentPath = consent.Path(dirs.ConfigDir)
+	return settings, nil
+}
+
+// newRepoManager creates the repository manager for the configured repository
+func newRepoManager(dirs *paths.Paths, settings *plugins.Settings) *git.RepoManager {
+	return git.NewRepoManager(dirs.CacheDir, settings.DefaultRepository)
+}
+
+// setDefaultTimeout makes the plugin_timeout setting the default of the
+// --timeout flag
+func setDefaultTimeout(rootCmd *cobra.Command, settings *plugins.Settings) error {
+	if settings.PluginTimeout == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(settings.PluginTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid plugin_timeout setting: %w", err)
+	}
+
+	flag := rootCmd.PersistentFlags().Lookup("timeout")
+	if err := flag.Value.Set(timeout.String()); err != nil {
+		return err
+	}
+	flag.DefValue = flag.Value.String()
+	return nil
+}
+
+// retryPolicy builds the repository retry policy from the settings
+func (a *app) retryPolicy(settings *plugins.Settings) (git.RetryPolicy, error) {
+	policy := git.DefaultRetryPolicy
+	if a.isOffline() {
+		return git.NoRetry, nil
+	}
+
+	if settings.Retry.Attempts > 0 {
+		policy.Attempts = settings.Retry.Attempts
+	}
+
+	if settings.Retry.InitialDelay != "" {
+		delay, err := time.ParseDuration(settings.Retry.InitialDelay)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry.initial_delay setting: %w", err)
+		}
+		policy.InitialDelay = delay
+	}
+
+	if settings.Retry.MaxDelay != "" {
+		delay, err := time.ParseDuration(settings.Retry.MaxDelay)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry.max_delay setting: %w", err)
+		}
+		policy.MaxDelay = delay
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// unknownCommandError reports an unknown subcommand of cmd the way cobra does,
+// with suggestions that match the aliases of the commands as well as their
+// names
+func unknownCommandError(cmd *cobra.Command, name string) error {
+	message := fmt.Sprintf("unknown command %q for %q", name, cmd.CommandPath())
+	if suggestions := suggestCommands(cmd, name); len(suggestions) > 0 && !cmd.DisableSuggestions {
+		message += "\n\nDid you mean this?\n"
+		for _, suggestion := range suggestions {
+			message += fmt.Sprintf("\t%v\n", suggestion)
+		}
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// suggestCommands returns the names of the subcommands of cmd whose name or
+// alias is close to, or starts with, the given name
+func suggestCommands(cmd *cobra.Command, name string) []string {
+	distance := cmd.SuggestionsMinimumDistance
+	if distance <= 0 {
+		distance = 2
+	}
+
+	var suggestions []string
+	for _, child := range cmd.Commands() {
+		if !child.IsAvailableCommand() {
+			continue
+		}
+		for _, candidate := range append([]string{child.Name()}, child.Aliases...) {
+			close := levenshtein(strings.ToLower(name), strings.ToLower(candidate)) <= distance
+			if close || strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(name)) || slices.ContainsFunc(child.SuggestFor, func(s string) bool { return strings.EqualFold(s, name) }) {
+				suggestions = append(suggestions, child.Name())
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
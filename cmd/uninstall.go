@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+func newUninstallCommand(a *app) *cobra.Command {
+	var version string
+	var allVersions bool
+
+	cmd := &cobra.Command{
+		Use:   "uninstall <plugin>",
+		Short: "Remove the installed module of a plugin",
+		Long: `Remove the installed module of a plugin, given by name or UUID.
+
+The installed version is removed, unless another one is given with --version;
+--all-versions removes every installed version of the plugin. When versions
+are left, the most recently installed one becomes the installed version.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			configManager, _, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+			plugin, err := configManager.GetPlugin(args[0])
+			if err != nil {
+				return err
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			st, err := state.Load(state.Path(dirs.ConfigDir))
+			if err != nil {
+				return err
+			}
+
+			installed := st.InstalledVersions[plugin.UUID]
+			if len(installed) == 0 {
+				return fmt.Errorf("plugin %s is not installed", plugin.Name)
+			}
+
+			versions := []string{st.Installed[plugin.UUID]}
+			switch {
+			case allVersions:
+				versions = slices.Clone(installed)
+			case version != "":
+				if !slices.Contains(installed, version) {
+					return fmt.Errorf("plugin %s has no installed version %s, installed versions are: %s", plugin.Name, version, strings.Join(installed, ", "))
+				}
+				versions = []string{version}
+			}
+
+			for _, v := range versions {
+				if err := plugins.Uninstall(settings.InstallDir, *plugin, v); err != nil {
+					return err
+				}
+				st.RemoveInstalled(plugin.UUID, v)
+				fmt.Fprintf(out, "Uninstalled %s %s\n", plugin.Name, v)
+			}
+
+			return st.Save()
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Version to remove instead of the installed one")
+	cmd.Flags().BoolVar(&allVersions, "all-versions", false, "Remove every installed version")
+	cmd.MarkFlagsMutuallyExclusive("version", "all-versions")
+	return cmd
+}
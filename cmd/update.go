@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/git"
+	"github.com/spf13/cobra"
+)
+
+func newUpdateCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "update",
+		Short: "Update the plugin catalog",
+		Long:  `Pull the latest plugin catalog from the wpstore repository and summarize what changed`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if a.isOffline() {
+				return fmt.Errorf("cannot update the plugin catalog in offline mode")
+			}
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+			if a.syncErr != nil {
+				return a.syncErr
+			}
+
+			if repoManager.IsLocal() {
+				fmt.Fprintf(out, "Using the local repository at %s, nothing to update\n", repoManager.GetRepoPath())
+				return nil
+			}
+
+			update := a.lastUpdate
+			if update == nil || !update.Updated() {
+				head, err := repoManager.Head()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "Plugin catalog is already up to date (%s)\n", head.Hash)
+				return nil
+			}
+
+			fmt.Fprintf(out, "Plugin catalog updated (%s -> %s), %d files changed\n",
+				update.OldHash[:7], update.NewHash[:7], len(update.ChangedFiles))
+
+			if update.PluginsChanged() {
+				if err := a.printCatalogChanges(out, repoManager, update); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// printCatalogChanges lists the plugins and versions added by an update
+func (a *app) printCatalogChanges(out io.Writer, repoManager *git.RepoManager, update *git.UpdateResult) error {
+	snapshot, err := repoManager.SnapshotAt(update.OldHash)
+	if err != nil {
+		return err
+	}
+
+	data, err := snapshot.ReadFile("plugins.yml")
+	if err != nil {
+		return err
+	}
+
+	previous := a.newConfigManager(repoManager.GetRepoPath())
+	if err := previous.Parse(data); err != nil {
+		return fmt.Errorf("failed to load previous plugins configuration: %w", err)
+	}
+
+	current := a.newConfigManager(repoManager.GetRepoPath())
+	if err := current.Load(); err != nil {
+		return fmt.Errorf("failed to load plugins configuration: %w", err)
+	}
+
+	knownVersions := make(map[string]map[string]bool)
+	for _, plugin := range previous.GetPlugins() {
+		knownVersions[plugin.UUID] = make(map[string]bool)
+		for _, version := range plugin.Versions {
+			knownVersions[plugin.UUID][version.Version] = true
+		}
+	}
+
+	var newPlugins, newVersions []string
+	for _, plugin := range current.GetPlugins() {
+		known, exists := knownVersions[plugin.UUID]
+		if !exists {
+			newPlugins = append(newPlugins, plugin.Name)
+			continue
+		}
+		for _, version := range plugin.Versions {
+			if !known[version.Version] {
+				newVersions = append(newVersions, fmt.Sprintf("%s %s", plugin.Name, version.Version))
+			}
+		}
+	}
+
+	if len(newPlugins) > 0 {
+		fmt.Fprintf(out, "%d new plugins available: %s\n", len(newPlugins), strings.Join(newPlugins, ", "))
+	}
+	if len(newVersions) > 0 {
+		fmt.Fprintf(out, "%d new plugin versions available: %s\n", len(newVersions), strings.Join(newVersions, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/ploffredi/wpcli/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// Statuses of the plugins handled by upgrade
+const (
+	upgradeUpgraded   = "upgraded"
+	upgradeUpToDate   = "up-to-date"
+	upgradeRolledBack = "rolled-back"
+)
+
+// upgradeResult tells what upgrade did to an installed plugin
+type upgradeResult struct {
+	Plugin string `json:"plugin"`
+	UUID   string `json:"uuid"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+}
+
+func newUpgradeCommand(a *app) *cobra.Command {
+	var all, rollback bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [plugin]",
+		Short: "Upgrade installed plugins to their latest version",
+		Long: `Install the latest version of an installed plugin, given by name or UUID, or
+of every installed plugin with --all. The module is verified against its
+checksum before the plugin switches to it, and the previous version is kept:
+--rollback switches the plugin back to it.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all {
+				return cobra.NoArgs(cmd, args)
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("give the plugin to upgrade, or --all")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid value for flag --output: %s. Valid values are: text, json", output)
+			}
+
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+			configManager, _, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			st, err := state.Load(state.Path(dirs.ConfigDir))
+			if err != nil {
+				return err
+			}
+
+			var targets []plugins.Plugin
+			if all {
+				for _, plugin := range configManager.GetPlugins() {
+					if _, ok := st.Installed[plugin.UUID]; ok {
+						targets = append(targets, plugin)
+					}
+				}
+			} else {
+				plugin, err := configManager.GetPlugin(args[0])
+				if err != nil {
+					return err
+				}
+				if _, ok := st.Installed[plugin.UUID]; !ok {
+					return fmt.Errorf("plugin %s is not installed, run 'wpcli install %s'", plugin.Name, plugin.Name)
+				}
+				targets = []plugins.Plugin{*plugin}
+			}
+
+			results := []upgradeResult{}
+			for _, plugin := range targets {
+				result := upgradeResult{Plugin: plugin.Name, UUID: plugin.UUID, From: st.Installed[plugin.UUID]}
+				if rollback {
+					if result.To, err = previousVersion(st, plugin); err != nil {
+						return err
+					}
+					result.Status = upgradeRolledBack
+				} else {
+					latest := plugin.Versions[0]
+					result.To = latest.Version
+					result.Status = upgradeUpToDate
+					if plugins.CompareVersions(latest.Version, result.From) > 0 {
+						if _, err := a.installModule(cmd, repoManager.GetRepoPath(), settings, st, plugin, latest); err != nil {
+							return err
+						}
+						result.Status = upgradeUpgraded
+					}
+				}
+
+				// The state switches the plugin to the new version once its
+				// module is in place
+				if result.Status != upgradeUpToDate {
+					st.AddInstalled(plugin.UUID, result.To)
+					if err := st.Save(); err != nil {
+						return err
+					}
+				}
+				results = append(results, result)
+			}
+
+			if output == "json" {
+				return writeJSON(out, results)
+			}
+			if len(results) == 0 {
+				fmt.Fprintln(out, "No installed plugins to upgrade")
+			}
+			for _, result := range results {
+				switch result.Status {
+				case upgradeUpgraded:
+					fmt.Fprintf(out, "Upgraded %s %s -> %s\n", result.Plugin, result.From, result.To)
+				case upgradeRolledBack:
+					fmt.Fprintf(out, "Rolled back %s %s -> %s\n", result.Plugin, result.From, result.To)
+				default:
+					fmt.Fprintf(out, "%s %s is up to date\n", result.Plugin, result.From)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Upgrade every installed plugin")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Switch back to the version installed before")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (valid values: text, json)")
+	cmd.MarkFlagsMutuallyExclusive("all", "rollback")
+	return cmd
+}
+
+// previousVersion returns the version of a plugin installed most recently
+// before the installed one, whose module is still installed
+func previousVersion(st *state.State, plugin plugins.Plugin) (string, error) {
+	versions := st.InstalledVersions[plugin.UUID]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i] != st.Installed[plugin.UUID] {
+			return versions[i], nil
+		}
+	}
+	return "", fmt.Errorf("plugin %s has no previous version to roll back to", plugin.Name)
+}
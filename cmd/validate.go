@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCommand(a *app) *cobra.Command {
+	var checkTranslations bool
+
+	cmd := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Check plugins.yml and the plugin configurations for mistakes",
+		Long: `Check the plugin catalog and the configuration of every plugin version it
+references, reporting each problem with its file, line and severity.
+
+The path is a registry directory or its plugins.yml, the local repository by
+default. Errors are problems that break plugin commands, such as missing
+fields or files, invalid flags and names used twice; warnings point at likely
+mistakes, such as unknown fields, which are errors with --strict. The command
+fails when there are errors, so it can run in the CI of a registry.
+
+With --check-translations, it also reports how many descriptions of the
+latest plugin versions are translated in each supported language, or in each
+language the registry uses, and lists the missing ones, followed by the
+plugins whose description is a plain English string or a language map.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			var repoPath string
+			if len(args) == 1 {
+				repoPath = args[0]
+				if info, err := os.Stat(repoPath); err == nil && !info.IsDir() {
+					repoPath = filepath.Dir(repoPath)
+				}
+			} else {
+				repoManager, err := a.openRepository()
+				if err != nil {
+					return err
+				}
+				repoPath = repoManager.GetRepoPath()
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+			strict, _ := cmd.Flags().GetBool("strict")
+			strict = strict || settings.StrictConfig
+
+			findings, err := plugins.ValidateRegistry(repoPath, a.reservedNames(cmd.Root()), strict)
+			if err != nil {
+				return err
+			}
+
+			errorCount, warningCount := 0, 0
+			for _, finding := range findings {
+				fmt.Fprintln(out, finding)
+				if finding.Severity == plugins.SeverityError {
+					errorCount++
+				} else {
+					warningCount++
+				}
+			}
+
+			if checkTranslations {
+				coverage, err := plugins.TranslationCoverage(repoPath, settings.SupportedLanguages)
+				if err != nil {
+					return err
+				}
+				printCoverage(out, coverage, len(findings) > 0)
+
+				plain, mapped, err := plugins.DescriptionForms(repoPath)
+				if err != nil {
+					return err
+				}
+				printDescriptionForms(out, plain, mapped)
+			}
+
+			if len(findings) == 0 {
+				fmt.Fprintf(out, "No problems found in %s\n", repoPath)
+				return nil
+			}
+			fmt.Fprintf(out, "\n%d error(s), %d warning(s)\n", errorCount, warningCount)
+			if errorCount > 0 {
+				return fmt.Errorf("validation of %s failed", repoPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&checkTranslations, "check-translations", false, "Also report the translation coverage of the plugin descriptions")
+	return cmd
+}
+
+// printCoverage prints the translation coverage of each language, after the
+// findings when there are some
+func printCoverage(out io.Writer, coverage []plugins.Coverage, afterFindings bool) {
+	if afterFindings {
+		fmt.Fprintln(out)
+	}
+	if len(coverage) == 0 {
+		fmt.Fprintln(out, "Translation coverage: no languages other than English")
+		return
+	}
+
+	fmt.Fprintln(out, "Translation coverage:")
+	for _, language := range coverage {
+		percent := 100
+		if language.Total > 0 {
+			percent = language.Translated * 100 / language.Total
+		}
+		fmt.Fprintf(out, "  %s (%s): %d of %d descriptions translated (%d%%)\n", language.Language, i18n.LanguageName(language.Language), language.Translated, language.Total, percent)
+		for _, missing := range language.Missing {
+			fmt.Fprintf(out, "    missing: %s\n", missing)
+		}
+	}
+}
+
+// printDescriptionForms prints the plugins whose description is written as a
+// plain string and those whose description is a map of languages
+func printDescriptionForms(out io.Writer, plain, mapped []string) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Plugin descriptions:")
+	if len(plain) > 0 {
+		fmt.Fprintf(out, "  as a string: %s\n", strings.Join(plain, ", "))
+	}
+	if len(mapped) > 0 {
+		fmt.Fprintf(out, "  as a language map: %s\n", strings.Join(mapped, ", "))
+	}
+}
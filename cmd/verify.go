@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify [plugin]",
+		Short: "Verify the checksums of plugin modules",
+		Long: `Verify the WebAssembly modules of every plugin version, or of the versions
+of the given plugin, against the sha256 checksums of the catalog.
+
+Each module is reported as ok, mismatch, missing, or unverified when its
+version has no checksum. Modules downloaded from a URL are verified once
+installed, and reported as not installed before. The command fails when a module does not match or is
+missing, and when a module is unverified with require_checksums set or the
+restricted trust level.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoManager, err := a.openRepository()
+			if err != nil {
+				return err
+			}
+
+			configManager, _, err := a.loadCatalog(repoManager)
+			if err != nil {
+				return err
+			}
+
+			catalog := configManager.GetPlugins()
+			if len(args) == 1 {
+				plugin, err := configManager.GetPlugin(args[0])
+				if err != nil {
+					return err
+				}
+				catalog = []plugins.Plugin{*plugin}
+			}
+
+			dirs, err := a.deps.Paths()
+			if err != nil {
+				return err
+			}
+			settings, err := a.deps.Settings(dirs)
+			if err != nil {
+				return err
+			}
+
+			table := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(table, "PLUGIN\tVERSION\tSTATUS")
+			listed, verified, failed := 0, 0, 0
+			for _, plugin := range catalog {
+				for _, version := range plugin.Versions {
+					if version.Wasm == "" {
+						continue
+					}
+
+					path := plugins.ModuleLocation(repoManager.GetRepoPath(), settings.InstallDir, plugin, version)
+					status, err := plugins.VerifyModule(plugin, version, path)
+					if status == "" {
+						return err
+					}
+
+					listed++
+					// Remote modules are only verified once installed
+					if status == plugins.ModuleMissing && plugins.IsRemoteModule(version) {
+						fmt.Fprintf(table, "%s\t%s\t%s\n", plugin.Name, version.Version, "not installed")
+						continue
+					}
+
+					verified++
+					if status == plugins.ModuleMismatch || status == plugins.ModuleMissing ||
+						(status == plugins.ModuleUnverified && settings.Policy(plugin).RequireChecksums) {
+						failed++
+					}
+					fmt.Fprintf(table, "%s\t%s\t%s\n", plugin.Name, version.Version, status)
+				}
+			}
+
+			if listed == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No plugin modules to verify")
+				return nil
+			}
+			if err := table.Flush(); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d plugin modules failed verification", failed, verified)
+			}
+			return nil
+		},
+	}
+}
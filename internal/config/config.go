@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ploffredi/wpcli/internal/plugins"
+	"gopkg.in/yaml.v3"
+)
+
+const fileName = "config.yml"
+
+// Config is the user configuration stored in config.yml in the config directory
+type Config struct {
+	// Settings is kept as a raw node so it can be layered over the registry settings
+	Settings yaml.Node `yaml:"settings"`
+}
+
+// Path returns the location of the user configuration file
+func Path(configDir string) string {
+	return filepath.Join(configDir, fileName)
+}
+
+// Load reads the user configuration, returning an empty one if it does not exist
+func Load(configDir string) (*Config, error) {
+	config := &Config{}
+
+	data, err := os.ReadFile(Path(configDir))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", fileName, err)
+	}
+
+	return config, nil
+}
+
+// ApplySettings overrides the given settings with the ones set by the user.
+// Settings the user did not set keep their current value.
+func (c *Config) ApplySettings(settings *plugins.Settings) error {
+	if c.Settings.Kind == 0 {
+		return nil
+	}
+
+	if err := c.Settings.Decode(settings); err != nil {
+		return fmt.Errorf("failed to parse settings in %s: %w", fileName, err)
+	}
+
+	return nil
+}
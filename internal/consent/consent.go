@@ -0,0 +1,129 @@
+package consent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const fileName = "consent.json"
+
+// Consent holds the permissions the user granted to plugin versions
+type Consent struct {
+	// Grants are keyed by Key
+	Grants map[string]Grant `json:"grants,omitempty"`
+
+	path string
+}
+
+// Grant records the permissions granted to a plugin version
+type Grant struct {
+	Plugin  string `json:"plugin"`
+	UUID    string `json:"uuid"`
+	Version string `json:"version"`
+	// Hash identifies the permissions granted, so that the grant no longer
+	// applies once the plugin version requests others
+	Hash      string    `json:"hash"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Path returns the location of the consent file inside the config directory
+func Path(configDir string) string {
+	return filepath.Join(configDir, fileName)
+}
+
+// Key returns the key of the grant of a plugin version
+func Key(uuid, version string) string {
+	return uuid + "@" + version
+}
+
+// Load reads the consent file, returning an empty consent if it does not
+// exist
+func Load(path string) (*Consent, error) {
+	c := &Consent{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consent file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse consent file %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Save writes the consent back to the file it was loaded from
+func (c *Consent) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode consent: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated file
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write consent file: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write consent file: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup returns the grant of a plugin version, whatever the permissions
+// it was given for
+func (c *Consent) Lookup(uuid, version string) (Grant, bool) {
+	grant, ok := c.Grants[Key(uuid, version)]
+	return grant, ok
+}
+
+// Granted reports whether the permissions identified by hash were granted
+// to a plugin version
+func (c *Consent) Granted(uuid, version, hash string) bool {
+	grant, ok := c.Lookup(uuid, version)
+	return ok && grant.Hash == hash
+}
+
+// Grant records a grant, replacing the previous one of the plugin version
+func (c *Consent) Grant(grant Grant) {
+	if c.Grants == nil {
+		c.Grants = make(map[string]Grant)
+	}
+	c.Grants[Key(grant.UUID, grant.Version)] = grant
+}
+
+// PluginGrants returns the grants of the versions of a plugin, sorted by
+// version
+func (c *Consent) PluginGrants(uuid string) []Grant {
+	var grants []Grant
+	for _, grant := range c.Grants {
+		if grant.UUID == uuid {
+			grants = append(grants, grant)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].Version < grants[j].Version })
+	return grants
+}
+
+// Revoke forgets the grant of a plugin version, or of every version of the
+// plugin when version is empty, and returns the grants forgotten
+func (c *Consent) Revoke(uuid, version string) []Grant {
+	var revoked []Grant
+	for key, grant := range c.Grants {
+		if grant.UUID == uuid && (version == "" || grant.Version == version) {
+			revoked = append(revoked, grant)
+			delete(c.Grants, key)
+		}
+	}
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].Version < revoked[j].Version })
+	return revoked
+}
@@ -0,0 +1,52 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// acceptedBoolValues lists the spellings of booleans in error messages
+const acceptedBoolValues = "true, false, yes, no, y, n, on, off, 1, 0"
+
+// ParseBool parses a boolean, accepting yes/no and on/off besides the values
+// accepted by strconv.ParseBool
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "on":
+		return true, nil
+	case "no", "n", "off":
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean '%s', accepted values are %s", value, acceptedBoolValues)
+	}
+	return parsed, nil
+}
+
+// boolValue is the pflag value of boolean flags, accepting the spellings of ParseBool
+type boolValue bool
+
+func (v *boolValue) Set(value string) error {
+	parsed, err := ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(parsed)
+	return nil
+}
+
+func (v *boolValue) String() string {
+	return strconv.FormatBool(bool(*v))
+}
+
+func (v *boolValue) Type() string {
+	return "bool"
+}
+
+// IsBoolFlag lets the flag be given without a value
+func (v *boolValue) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,78 @@
+package flags
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr string
+	}{
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "TRUE", want: true},
+		{value: "yes", want: true},
+		{value: "no", want: false},
+		{value: "Yes", want: true},
+		{value: "y", want: true},
+		{value: "n", want: false},
+		{value: "on", want: true},
+		{value: "off", want: false},
+		{value: "OFF", want: false},
+		{value: "1", want: true},
+		{value: "0", want: false},
+		{value: " yes ", want: true},
+		{value: "maybe", wantErr: "invalid boolean 'maybe', accepted values are true, false, yes, no, y, n, on, off, 1, 0"},
+		{value: "2", wantErr: "invalid boolean '2'"},
+		{value: "", wantErr: "invalid boolean ''"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseBool(test.value)
+			checkError(t, err, test.wantErr)
+			if err == nil && got != test.want {
+				t.Errorf("ParseBool(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBoolFlagHandlerValidateValue(t *testing.T) {
+	flag := &Flag{Name: "--dry-run", Type: TypeBool, ValidValues: []string{"true", "false"}}
+	handler := &BoolFlagHandler{}
+	for _, value := range []string{"1", "0", "yes", "off"} {
+		checkError(t, handler.ValidateValue(flag, value), "")
+	}
+	checkError(t, handler.ValidateValue(flag, "sure"), "invalid boolean value for flag --dry-run: invalid boolean 'sure', accepted values are")
+
+	// Valid values are compared as booleans
+	onlyTrue := &Flag{Name: "--confirm", Type: TypeBool, ValidValues: []string{"yes"}}
+	checkError(t, handler.ValidateValue(onlyTrue, "on"), "")
+	checkError(t, handler.ValidateValue(onlyTrue, "0"), "invalid value for flag --confirm: 0. Valid values are: yes")
+}
+
+func TestBoolFlagHandlerGetValue(t *testing.T) {
+	for value, want := range map[string]string{"yes": "true", "on": "true", "1": "true", "no": "false", "off": "false", "0": "false"} {
+		cmd := &cobra.Command{Use: "test"}
+		flag := &Flag{Name: "--force", Type: TypeBool}
+		handler := &BoolFlagHandler{}
+		if err := handler.AddFlag(cmd, flag); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.ParseFlags([]string{"--force=" + value}); err != nil {
+			t.Fatalf("--force=%s: %v", value, err)
+		}
+		got, err := handler.GetValue(cmd, flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("GetValue of --force=%s = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package flags
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits maps the lower case unit suffixes of byte sizes to their
+// multiplier. A trailing "b" is optional, so 10M, 10MB and 10mb are equal.
+var byteUnits = map[string]float64{
+	"":   1,
+	"k":  1e3,
+	"m":  1e6,
+	"g":  1e9,
+	"ki": 1 << 10,
+	"mi": 1 << 20,
+	"gi": 1 << 30,
+}
+
+// acceptedByteUnits lists the units in error messages
+const acceptedByteUnits = "B, k, M, G, Ki, Mi, Gi (case insensitive, optionally followed by B)"
+
+// ParseBytes parses a byte size such as 512, 10MB or 1.5GiB into a number of bytes
+func ParseBytes(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end == -1 {
+		end = len(value)
+	}
+
+	number, unit := value[:end], strings.ToLower(strings.TrimSpace(value[end:]))
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	if unit != "b" {
+		unit = strings.TrimSuffix(unit, "b")
+	} else {
+		unit = ""
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size '%s': unknown unit, accepted units are %s", value, acceptedByteUnits)
+	}
+
+	bytes := math.Round(size * multiplier)
+	// float64(math.MaxInt64) rounds up to 2^63, which int64 cannot hold
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size '%s': too large", value)
+	}
+	return int64(bytes), nil
+}
+
+// FormatBytes formats a number of bytes with the largest binary unit that
+// divides it, such as 64MiB
+func FormatBytes(bytes int64) string {
+	for _, unit := range []string{"Gi", "Mi", "Ki"} {
+		multiplier := int64(byteUnits[strings.ToLower(unit)])
+		if bytes != 0 && bytes%multiplier == 0 {
+			return fmt.Sprintf("%d%sB", bytes/multiplier, unit)
+		}
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
+
+// bytesValue is the pflag value of byte size flags, holding the number of bytes
+type bytesValue struct {
+	bytes    int64
+	flagName string
+	err      error
+}
+
+func (v *bytesValue) Set(value string) error {
+	bytes, err := ParseBytes(value)
+	if err != nil {
+		v.err = fmt.Errorf("invalid value for flag --%s: %w", v.flagName, err)
+		return v.err
+	}
+	v.bytes = bytes
+	return nil
+}
+
+func (v *bytesValue) String() string {
+	return strconv.FormatInt(v.bytes, 10)
+}
+
+func (v *bytesValue) Type() string {
+	return "bytes"
+}
+
+func (v *bytesValue) setError() error {
+	return v.err
+}
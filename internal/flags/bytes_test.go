@@ -0,0 +1,56 @@
+package flags
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr string
+	}{
+		{value: "0", want: 0},
+		{value: "512", want: 512},
+		{value: "512B", want: 512},
+		{value: "1k", want: 1000},
+		{value: "1kB", want: 1000},
+		{value: "10M", want: 10_000_000},
+		{value: "10MB", want: 10_000_000},
+		{value: "10mb", want: 10_000_000},
+		{value: "2G", want: 2_000_000_000},
+		{value: "1Ki", want: 1024},
+		{value: "1KiB", want: 1024},
+		{value: "64MiB", want: 64 << 20},
+		{value: "64mib", want: 64 << 20},
+		{value: "2GiB", want: 2 << 30},
+		{value: "1.5GiB", want: 3 << 29},
+		{value: "1.5MiB", want: 1_572_864},
+		{value: "0.5k", want: 500},
+		{value: ".5Ki", want: 512},
+		// Fractions of a byte are rounded
+		{value: "1.0005k", want: 1001},
+		{value: " 10 MB ", want: 10_000_000},
+		{value: "8589934591Gi", want: 8589934591 << 30},
+		{value: "8589934592GiB", wantErr: "invalid size '8589934592GiB': too large"},
+		// MaxInt64 itself rounds up to 2^63 as a float64
+		{value: "9223372036854775807", wantErr: "too large"},
+		{value: "99999999999G", wantErr: "too large"},
+		{value: "10XB", wantErr: "invalid size '10XB': unknown unit, accepted units are B, k, M, G, Ki, Mi, Gi"},
+		{value: "10T", wantErr: "unknown unit"},
+		{value: "10KiBs", wantErr: "unknown unit"},
+		{value: "10iB", wantErr: "unknown unit"},
+		{value: "MB", wantErr: "invalid size 'MB'"},
+		{value: "", wantErr: "invalid size ''"},
+		{value: "-1k", wantErr: "invalid size '-1k'"},
+		{value: "1.2.3M", wantErr: "invalid size '1.2.3M'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseBytes(test.value)
+			checkError(t, err, test.wantErr)
+			if err == nil && got != test.want {
+				t.Errorf("ParseBytes(%q) = %d, want %d", test.value, got, test.want)
+			}
+		})
+	}
+}
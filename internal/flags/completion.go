@@ -0,0 +1,36 @@
+package flags
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completeValidValues returns a completion function offering the valid values
+// of flag, described by their description in the language of the flag when
+// they have one
+func completeValidValues(flag *Flag) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		var completions []cobra.Completion
+		for _, value := range flag.ValidValues {
+			if !hasValuePrefix(flag, value, toComplete) {
+				continue
+			}
+			if description := flag.ValueDescriptions[value].Get(flag.language); description != "" {
+				completions = append(completions, cobra.CompletionWithDesc(value, description))
+			} else {
+				completions = append(completions, value)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// hasValuePrefix reports whether value starts with prefix, ignoring case for
+// case insensitive flags
+func hasValuePrefix(flag *Flag, value, prefix string) bool {
+	if flag.CaseInsensitive {
+		return strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix))
+	}
+	return strings.HasPrefix(value, prefix)
+}
@@ -0,0 +1,96 @@
+package flags
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// replacedByAnnotation records on a deprecated pflag the flag that replaces it
+const replacedByAnnotation = "wpcli/replaced-by"
+
+// IsDeprecated reports whether the flag is deprecated, either explicitly or
+// because it was replaced by another flag
+func (f *Flag) IsDeprecated() bool {
+	return f.Deprecated != "" || f.ReplacedBy != ""
+}
+
+// deprecationMessage returns the message printed when a deprecated flag is
+// used, pointing to the command line name of its replacement if any
+func (f *Flag) deprecationMessage(replacement string) string {
+	message := f.Deprecated
+	if replacement == "" {
+		return message
+	}
+
+	hint := fmt.Sprintf("use --%s instead", replacement)
+	if message == "" {
+		return hint
+	}
+	return fmt.Sprintf("%s, %s", message, hint)
+}
+
+// markDeprecated marks the deprecated flags of cmd and checks that their
+// replacements exist
+func markDeprecated(cmd *cobra.Command, flags []*Flag) error {
+	for _, flag := range flags {
+		if !flag.IsDeprecated() {
+			continue
+		}
+
+		flagName := flag.CLIName()
+		var replacement string
+		if flag.ReplacedBy != "" {
+			replacementFlag := findFlag(flags, flag.ReplacedBy)
+			if replacementFlag == nil {
+				return fmt.Errorf("flag %s is replaced by undeclared flag %s", flag.Name, flag.ReplacedBy)
+			}
+			replacement = replacementFlag.CLIName()
+			if err := flagSet(cmd, flag).SetAnnotation(flagName, replacedByAnnotation, []string{replacement}); err != nil {
+				return err
+			}
+		}
+
+		if err := flagSet(cmd, flag).MarkDeprecated(flagName, flag.deprecationMessage(replacement)); err != nil {
+			return fmt.Errorf("failed to mark flag %s as deprecated: %w", flagName, err)
+		}
+	}
+	return nil
+}
+
+// findFlag returns the flag declared with the given name
+func findFlag(flags []*Flag, name string) *Flag {
+	for _, flag := range flags {
+		if NormalizeFlagName(flag.Name) == NormalizeFlagName(name) {
+			return flag
+		}
+	}
+	return nil
+}
+
+// ApplyReplacements copies the value of each deprecated flag given on the
+// command line to the flag that replaces it, unless that flag was also given.
+// It must run before validation so the value is validated as the new flag.
+func ApplyReplacements(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		replacement := replacedBy(flag)
+		if err != nil || replacement == "" || cmd.Flags().Changed(replacement) {
+			return
+		}
+		if setErr := cmd.Flags().Set(replacement, flag.Value.String()); setErr != nil {
+			err = fmt.Errorf("invalid value for flag --%s (given as deprecated --%s): %w", replacement, flag.Name, setErr)
+		}
+	})
+	return err
+}
+
+// replacedBy returns the name of the flag replacing a deprecated flag
+func replacedBy(flag *pflag.Flag) string {
+	values := flag.Annotations[replacedByAnnotation]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
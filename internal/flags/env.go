@@ -0,0 +1,29 @@
+package flags
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// expandDefault replaces the environment variables in the default value of
+// flags with expand_env set. A variable that is unset and has no fallback
+// expands to an empty string.
+func (f *Flag) expandDefault(logger *slog.Logger) {
+	if !f.ExpandEnv {
+		return
+	}
+
+	f.Default = os.Expand(f.Default, func(name string) string {
+		name, fallback, hasFallback := strings.Cut(name, ":-")
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		if !hasFallback {
+			logger.Debug("environment variable in flag default is not set", "flag", f.Name, "variable", name)
+		}
+		return fallback
+	})
+	// Values containing "$" must not be expanded again
+	f.ExpandEnv = false
+}
@@ -2,8 +2,15 @@ package flags
 
 import (
 	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/ploffredi/wpcli/internal/i18n"
 	"github.com/spf13/cobra"
 )
 
@@ -11,10 +18,18 @@ import (
 type FlagType string
 
 const (
-	TypeString FlagType = "string"
-	TypeBool   FlagType = "bool"
-	TypeInt    FlagType = "int"
-	TypeEnum   FlagType = "enum"
+	TypeString    FlagType = "string"
+	TypeBool      FlagType = "bool"
+	TypeInt       FlagType = "int"
+	TypeEnum      FlagType = "enum"
+	TypeFloat     FlagType = "float"
+	TypeDuration  FlagType = "duration"
+	TypeCount     FlagType = "count"
+	TypeBytes     FlagType = "bytes"
+	TypeIntSlice  FlagType = "intslice"
+	TypeURL       FlagType = "url"
+	TypePath      FlagType = "path"
+	TypeTimestamp FlagType = "timestamp"
 )
 
 // Flag represents a command flag with its configuration
@@ -22,17 +37,84 @@ type Flag struct {
 	Name        string
 	Shorthand   string
 	Type        FlagType
-	Description string   `yaml:"description"`
-	Required    bool     `yaml:"required"`
-	Default     string   `yaml:"default,omitempty"`
-	ValidValues []string `yaml:"valid_values,omitempty"`
+	Description i18n.Text `yaml:"description"`
+	Required    bool      `yaml:"required"`
+	Default     string    `yaml:"default,omitempty"`
+	ValidValues []string  `yaml:"valid_values,omitempty"`
+	// Aliases maps alternative spellings to their valid value, and
+	// CaseInsensitive matches valid values and aliases ignoring case
+	Aliases         map[string]string `yaml:"-"`
+	CaseInsensitive bool              `yaml:"case_insensitive,omitempty"`
+	// ValueDescriptions holds the descriptions of valid values, shown as
+	// completion hints
+	ValueDescriptions map[string]i18n.Text `yaml:"-"`
+	// Min and Max bound the value of int and bytes flags, and each element
+	// of intslice flags; nil means unbounded
+	Min *int64 `yaml:"min,omitempty"`
+	Max *int64 `yaml:"max,omitempty"`
+	// Pattern is a regular expression string values must match, with an
+	// optional human readable PatternHint shown when a value does not match
+	Pattern     string `yaml:"pattern,omitempty"`
+	PatternHint string `yaml:"pattern_hint,omitempty"`
+	// AllowedSchemes restricts the schemes of url flags, https and http
+	// by default
+	AllowedSchemes []string `yaml:"allowed_schemes,omitempty"`
+	// MustExist, MustBeDir and MustBeWritable are the file system checks
+	// of path flags. The last two apply to existing paths; a missing path
+	// that must be writable needs a writable parent directory.
+	MustExist      bool `yaml:"must_exist,omitempty"`
+	MustBeDir      bool `yaml:"must_be_dir,omitempty"`
+	MustBeWritable bool `yaml:"must_be_writable,omitempty"`
+	// Layouts are the Go time layouts accepted by timestamp flags, RFC3339
+	// and 2006-01-02 by default
+	Layouts []string `yaml:"layouts,omitempty"`
+	// MinLength, MaxLength, StartsWith and NotEmpty constrain the values of
+	// string flags; lengths count characters, not bytes
+	MinLength  *int   `yaml:"min_length,omitempty"`
+	MaxLength  *int   `yaml:"max_length,omitempty"`
+	StartsWith string `yaml:"starts_with,omitempty"`
+	NotEmpty   bool   `yaml:"not_empty,omitempty"`
+	// Env names an environment variable supplying the value when the flag
+	// is not given on the command line
+	Env string `yaml:"env,omitempty"`
+	// Deprecated is the message shown when a deprecated flag is used, and
+	// ReplacedBy names the flag that receives its value instead
+	Deprecated string `yaml:"deprecated,omitempty"`
+	ReplacedBy string `yaml:"replaced_by,omitempty"`
+	// ExposeAs is the name of the flag on the command line when it differs
+	// from Name, e.g. because Name is reserved by wpcli. Plugins still
+	// receive the value under Name.
+	ExposeAs string `yaml:"expose_as,omitempty"`
+	// Hidden flags work as usual but are left out of the help output
+	Hidden bool `yaml:"hidden,omitempty"`
+	// ImplicitValue is the value of the flag when it is given without one,
+	// as in --color for --color=auto. Bool flags imply "true" by default.
+	ImplicitValue string `yaml:"implicit_value,omitempty"`
+	// Requires lists the flags that must be set when this flag is set
+	Requires []string `yaml:"requires,omitempty"`
+	// Sensitive values, such as tokens, are masked wherever wpcli echoes
+	// them but passed unchanged to the plugin
+	Sensitive bool `yaml:"sensitive,omitempty"`
+	// ExpandEnv expands environment variables in Default when the flag is
+	// added, supporting $VAR, ${VAR} and ${VAR:-fallback}
+	ExpandEnv bool `yaml:"expand_env,omitempty"`
+	// Persistent flags are inherited by the subcommands of the command
+	// they are added to
+	Persistent bool `yaml:"-"`
+
+	pattern *regexp.Regexp
+	// language is the language of the help and error messages, set by AddFlags
+	language string
 }
 
 // FlagHandler defines the interface for handling different flag types
 type FlagHandler interface {
 	AddFlag(cmd *cobra.Command, flag *Flag) error
 	ValidateValue(flag *Flag, value string) error
-	GetValue(cmd *cobra.Command, flagName string) (string, error)
+	GetValue(cmd *cobra.Command, flag *Flag) (string, error)
+	// TypedValue converts a value returned by GetValue to its Go type:
+	// string, bool, int64, float64, time.Duration or time.Time
+	TypedValue(flag *Flag, value string) (interface{}, error)
 }
 
 // FlagValue represents a validated flag value
@@ -47,7 +129,7 @@ func NewFlag(name, shorthand string, flagType FlagType, description string, requ
 		Name:        name,
 		Shorthand:   shorthand,
 		Type:        flagType,
-		Description: description,
+		Description: i18n.Text{i18n.DefaultLanguage: description},
 		Required:    required,
 		Default:     defaultValue,
 		ValidValues: validValues,
@@ -59,14 +141,23 @@ func NormalizeFlagName(name string) string {
 	return strings.TrimPrefix(name, "--")
 }
 
+// CLIName returns the name of the flag on the command line, without the "--" prefix
+func (f *Flag) CLIName() string {
+	if f.ExposeAs != "" {
+		return NormalizeFlagName(f.ExposeAs)
+	}
+	return NormalizeFlagName(f.Name)
+}
+
 // NormalizeShorthand removes the "-" prefix from shorthand flags
 func NormalizeShorthand(shorthand string) string {
 	return strings.TrimPrefix(shorthand, "-")
 }
 
-// GetDescription returns the description in the given language, falling back to "default" if not found
+// GetDescription returns the description in the given language, falling back
+// to English and then to "default" if not found
 func (f *Flag) GetDescription(language string) string {
-	return f.Description
+	return f.Description.Get(language)
 }
 
 // Validate checks if the flag configuration is valid
@@ -79,6 +170,10 @@ func (f *Flag) Validate() error {
 		return fmt.Errorf("flag type cannot be empty")
 	}
 
+	if err := f.validateSpellings(); err != nil {
+		return err
+	}
+
 	// Only validate valid values for enum flags that have them
 	if f.Type == TypeEnum && len(f.ValidValues) > 0 {
 		if f.Default != "" && !f.IsValidValue(f.Default) {
@@ -86,6 +181,238 @@ func (f *Flag) Validate() error {
 		}
 	}
 
+	// Valid values of int flags are numbers or ranges such as 1-1023
+	if (f.Type == TypeInt || f.Type == TypeIntSlice) && len(f.ValidValues) > 0 {
+		for _, entry := range f.ValidValues {
+			if _, _, err := parseIntRange(entry); err != nil {
+				return fmt.Errorf("invalid valid value for %s flag %s: %w", f.Type, f.Name, err)
+			}
+		}
+		if f.Type == TypeInt && f.Default != "" && !f.IsValidValue(f.Default) {
+			return fmt.Errorf("default value %s is not in valid values for int flag %s", f.Default, f.Name)
+		}
+	}
+
+	if f.Type == TypeCount && f.Default != "" {
+		if count, err := strconv.Atoi(f.Default); err != nil || count < 0 {
+			return fmt.Errorf("default value %s of count flag %s must be a non-negative number", f.Default, f.Name)
+		}
+	}
+
+	if f.ImplicitValue != "" && f.Type == TypeCount {
+		return fmt.Errorf("count flag %s cannot have an implicit value", f.Name)
+	}
+
+	if len(f.AllowedSchemes) > 0 && f.Type != TypeURL {
+		return fmt.Errorf("allowed_schemes is only supported by url flags, flag %s is a %s flag", f.Name, f.Type)
+	}
+
+	if (f.MustExist || f.MustBeDir || f.MustBeWritable) && f.Type != TypePath {
+		return fmt.Errorf("must_exist, must_be_dir and must_be_writable are only supported by path flags, flag %s is a %s flag", f.Name, f.Type)
+	}
+
+	if len(f.Layouts) > 0 && f.Type != TypeTimestamp {
+		return fmt.Errorf("layouts is only supported by timestamp flags, flag %s is a %s flag", f.Name, f.Type)
+	}
+
+	if err := f.validateConstraints(); err != nil {
+		return err
+	}
+
+	if f.Type == TypeURL && f.Default != "" {
+		if _, err := parseURL(f, f.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	if f.IsDeprecated() && f.Required {
+		return fmt.Errorf("deprecated flag %s cannot be required", f.Name)
+	}
+
+	if f.ReplacedBy != "" && NormalizeFlagName(f.ReplacedBy) == NormalizeFlagName(f.Name) {
+		return fmt.Errorf("flag %s cannot be replaced by itself", f.Name)
+	}
+
+	for _, name := range f.Requires {
+		if NormalizeFlagName(name) == NormalizeFlagName(f.Name) {
+			return fmt.Errorf("flag %s cannot require itself", f.Name)
+		}
+	}
+
+	if f.Pattern != "" {
+		if len(f.ValidValues) > 0 {
+			return fmt.Errorf("flag %s cannot have both pattern and valid_values", f.Name)
+		}
+
+		pattern, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for flag %s: %w", f.Name, err)
+		}
+		f.pattern = pattern
+
+		if f.Default != "" && !pattern.MatchString(f.Default) {
+			return fmt.Errorf("default value %s does not match the pattern of flag %s", f.Default, f.Name)
+		}
+	}
+
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		return fmt.Errorf("min %d is greater than max %d for flag %s", *f.Min, *f.Max, f.Name)
+	}
+
+	if f.Type == TypeIntSlice && f.Default != "" {
+		if err := (&IntSliceFlagHandler{}).ValidateValue(f, f.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	} else if (f.Min != nil || f.Max != nil) && f.Default != "" {
+		value, err := f.rangeValue(f.Default)
+		if err != nil {
+			return fmt.Errorf("invalid default value for %s flag %s: %s", f.Type, f.Name, f.Default)
+		}
+		if err := f.CheckRange(value); err != nil {
+			return fmt.Errorf("default value %s for flag %s must be %s", f.Default, f.Name, f.RangeDescription())
+		}
+	}
+
+	return nil
+}
+
+// IsSet reports whether the flag was given on the command line or through
+// its environment variable
+func (f *Flag) IsSet(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed(f.CLIName()) {
+		return true
+	}
+	_, ok := f.lookupEnv()
+	return ok
+}
+
+// envValue returns the value of the environment variable bound to the flag
+// when the flag was not given on the command line
+func (f *Flag) envValue(cmd *cobra.Command) (string, bool) {
+	if cmd.Flags().Changed(f.CLIName()) {
+		return "", false
+	}
+	return f.lookupEnv()
+}
+
+func (f *Flag) lookupEnv() (string, bool) {
+	if f.Env == "" {
+		return "", false
+	}
+	return os.LookupEnv(f.Env)
+}
+
+// rangeValue returns the number Min and Max are compared to
+func (f *Flag) rangeValue(value string) (int64, error) {
+	if f.Type == TypeBytes {
+		return ParseBytes(value)
+	}
+	return parseInt(f, value)
+}
+
+// CheckRange checks an int value against the Min and Max bounds of the flag
+func (f *Flag) CheckRange(value int64) error {
+	if (f.Min != nil && value < *f.Min) || (f.Max != nil && value > *f.Max) {
+		return fmt.Errorf("invalid value for flag %s: %d. Value must be %s", f.Name, value, f.RangeDescription())
+	}
+	return nil
+}
+
+// RangeDescription describes the allowed range, e.g. "between 1 and 100"
+func (f *Flag) RangeDescription() string {
+	switch {
+	case f.Min != nil && f.Max != nil:
+		return fmt.Sprintf("between %d and %d", *f.Min, *f.Max)
+	case f.Min != nil:
+		return fmt.Sprintf("at least %d", *f.Min)
+	case f.Max != nil:
+		return fmt.Sprintf("at most %d", *f.Max)
+	default:
+		return "any value"
+	}
+}
+
+// MatchPattern checks a value against the pattern of the flag, if any
+func (f *Flag) MatchPattern(value string) error {
+	if f.Pattern == "" {
+		return nil
+	}
+
+	if f.pattern == nil {
+		pattern, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for flag %s: %w", f.Name, err)
+		}
+		f.pattern = pattern
+	}
+
+	if f.pattern.MatchString(value) {
+		return nil
+	}
+
+	if f.PatternHint != "" {
+		return fmt.Errorf("invalid value for flag %s: %s. Value must match %s (%s)", f.Name, value, f.Pattern, f.PatternHint)
+	}
+	return fmt.Errorf("invalid value for flag %s: %s. Value must match %s", f.Name, value, f.Pattern)
+}
+
+// validateConstraints rejects string constraints on other flag types and
+// combinations no value can satisfy
+func (f *Flag) validateConstraints() error {
+	if f.MinLength == nil && f.MaxLength == nil && f.StartsWith == "" && !f.NotEmpty {
+		return nil
+	}
+	if f.Type != TypeString {
+		return fmt.Errorf("min_length, max_length, starts_with and not_empty are only supported by string flags, flag %s is a %s flag", f.Name, f.Type)
+	}
+
+	if f.MinLength != nil && *f.MinLength < 0 {
+		return fmt.Errorf("min_length %d of flag %s cannot be negative", *f.MinLength, f.Name)
+	}
+	if f.MaxLength != nil && *f.MaxLength < 0 {
+		return fmt.Errorf("max_length %d of flag %s cannot be negative", *f.MaxLength, f.Name)
+	}
+	if f.MinLength != nil && f.MaxLength != nil && *f.MinLength > *f.MaxLength {
+		return fmt.Errorf("min_length %d is greater than max_length %d for flag %s", *f.MinLength, *f.MaxLength, f.Name)
+	}
+	if f.NotEmpty && f.MaxLength != nil && *f.MaxLength == 0 {
+		return fmt.Errorf("flag %s cannot be both not_empty and have max_length 0", f.Name)
+	}
+	if f.MaxLength != nil && utf8.RuneCountInString(f.StartsWith) > *f.MaxLength {
+		return fmt.Errorf("starts_with '%s' is longer than max_length %d for flag %s", f.StartsWith, *f.MaxLength, f.Name)
+	}
+
+	if f.Default != "" {
+		if err := f.CheckConstraints(f.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckConstraints checks a string value against the min_length,
+// max_length, starts_with and not_empty constraints of the flag
+func (f *Flag) CheckConstraints(value string) error {
+	shown := value
+	if f.Sensitive {
+		shown = MaskedValue
+	}
+
+	if f.NotEmpty && strings.TrimSpace(value) == "" {
+		return fmt.Errorf("invalid value for flag %s: value cannot be empty (not_empty)", f.Name)
+	}
+
+	length := utf8.RuneCountInString(value)
+	if f.MinLength != nil && length < *f.MinLength {
+		return fmt.Errorf("invalid value for flag %s: '%s' is %d characters long, shorter than min_length %d", f.Name, shown, length, *f.MinLength)
+	}
+	if f.MaxLength != nil && length > *f.MaxLength {
+		return fmt.Errorf("invalid value for flag %s: '%s' is %d characters long, longer than max_length %d", f.Name, shown, length, *f.MaxLength)
+	}
+
+	if f.StartsWith != "" && !strings.HasPrefix(value, f.StartsWith) {
+		return fmt.Errorf("invalid value for flag %s: '%s' does not start with '%s' (starts_with)", f.Name, shown, f.StartsWith)
+	}
 	return nil
 }
 
@@ -100,12 +427,96 @@ func (f *Flag) IsValidValue(value string) bool {
 		value = f.Default
 	}
 
+	if f.Type == TypeInt {
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return false
+		}
+		valid, err := f.matchesIntValidValue(number)
+		return err == nil && valid
+	}
+
+	_, ok := f.Canonical(value)
+	return ok
+}
+
+// validateSpellings rejects aliases that are also valid values and, for case
+// insensitive flags, valid values and aliases differing only in case that
+// stand for different values, as Canonical could not tell which is meant
+func (f *Flag) validateSpellings() error {
+	spellings := make(map[string]string)
+	spellingOf := make(map[string]string)
+	add := func(spelling, canonical string) error {
+		key := spelling
+		if f.CaseInsensitive {
+			key = strings.ToLower(spelling)
+		}
+		if other, ok := spellings[key]; ok && other != canonical {
+			return fmt.Errorf("%s and %s of flag %s differ only in case but stand for %s and %s", spellingOf[key], spelling, f.Name, other, canonical)
+		}
+		spellings[key] = canonical
+		spellingOf[key] = spelling
+		return nil
+	}
+
+	for _, value := range f.ValidValues {
+		if err := add(value, value); err != nil {
+			return err
+		}
+	}
+	for _, alias := range slices.Sorted(maps.Keys(f.Aliases)) {
+		if slices.Contains(f.ValidValues, alias) && f.Aliases[alias] != alias {
+			return fmt.Errorf("alias %s of flag %s is also a valid value", alias, f.Name)
+		}
+		if err := add(alias, f.Aliases[alias]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Canonical returns the valid value a user supplied value stands for,
+// resolving aliases and, for case insensitive flags, differences in case
+func (f *Flag) Canonical(value string) (string, bool) {
 	for _, v := range f.ValidValues {
 		if v == value {
-			return true
+			return v, true
+		}
+	}
+	if canonical, ok := f.Aliases[value]; ok {
+		return canonical, true
+	}
+
+	if !f.CaseInsensitive {
+		return value, false
+	}
+
+	for _, v := range f.ValidValues {
+		if strings.EqualFold(v, value) {
+			return v, true
 		}
 	}
-	return false
+	for alias, canonical := range f.Aliases {
+		if strings.EqualFold(alias, value) {
+			return canonical, true
+		}
+	}
+	return value, false
+}
+
+// Canonicalize replaces a value given on the command line with the valid
+// value it stands for, so plugins never see aliases or other spellings
+func Canonicalize(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	if len(flag.ValidValues) == 0 || !cmd.Flags().Changed(flagName) {
+		return nil
+	}
+
+	value := cmd.Flags().Lookup(flagName).Value
+	if canonical, ok := flag.Canonical(value.String()); ok && canonical != value.String() {
+		return value.Set(canonical)
+	}
+	return nil
 }
 
 // GetValidValues returns a map of valid values for quick lookup
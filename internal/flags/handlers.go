@@ -1,34 +1,36 @@
 package flags
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // StringFlagHandler handles string flags
 type StringFlagHandler struct{}
 
 func (h *StringFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
-	flagName := NormalizeFlagName(flag.Name)
+	flagName := flag.CLIName()
 	shorthand := NormalizeShorthand(flag.Shorthand)
 	defaultValue := flag.Default
-	description := flag.GetDescription("en")
+	description := usageDescription(flag, flag.GetDescription(flag.language))
 
 	if shorthand != "" {
-		cmd.Flags().StringP(flagName, shorthand, defaultValue, description)
+		flagSet(cmd, flag).StringP(flagName, shorthand, defaultValue, description)
 	} else {
-		cmd.Flags().String(flagName, defaultValue, description)
+		flagSet(cmd, flag).String(flagName, defaultValue, description)
 	}
 
-	if flag.Required {
-		if err := cmd.MarkFlagRequired(flagName); err != nil {
-			return fmt.Errorf("failed to mark flag %s as required: %w", flagName, err)
-		}
+	if err := markHidden(cmd, flag); err != nil {
+		return err
 	}
 
-	return nil
+	return markRequired(cmd, flag)
 }
 
 func (h *StringFlagHandler) ValidateValue(flag *Flag, value string) error {
@@ -36,11 +38,29 @@ func (h *StringFlagHandler) ValidateValue(flag *Flag, value string) error {
 		return fmt.Errorf("invalid value for flag %s: %s. Valid values are: %s",
 			flag.Name, value, strings.Join(flag.ValidValues, ", "))
 	}
-	return nil
+	if err := flag.CheckConstraints(value); err != nil {
+		return err
+	}
+	return flag.MatchPattern(value)
+}
+
+func (h *StringFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+	if value, ok := flag.envValue(cmd); ok {
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetString(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return value, nil
 }
 
-func (h *StringFlagHandler) GetValue(cmd *cobra.Command, flagName string) (string, error) {
-	value, _ := cmd.Flags().GetString(flagName)
+func (h *StringFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
 	return value, nil
 }
 
@@ -48,126 +68,833 @@ func (h *StringFlagHandler) GetValue(cmd *cobra.Command, flagName string) (strin
 type BoolFlagHandler struct{}
 
 func (h *BoolFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
-	flagName := NormalizeFlagName(flag.Name)
+	flagName := flag.CLIName()
 	shorthand := NormalizeShorthand(flag.Shorthand)
-	defaultValue := flag.Default == "true"
-	description := flag.GetDescription("en")
-
-	if shorthand != "" {
-		cmd.Flags().BoolP(flagName, shorthand, defaultValue, description)
-	} else {
-		cmd.Flags().Bool(flagName, defaultValue, description)
+	value := new(boolValue)
+	if flag.Default != "" {
+		if err := value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value for bool flag %s: %w", flagName, err)
+		}
 	}
+	description := usageDescription(flag, flag.GetDescription(flag.language))
 
-	if flag.Required {
-		if err := cmd.MarkFlagRequired(flagName); err != nil {
-			return fmt.Errorf("failed to mark flag %s as required: %w", flagName, err)
-		}
+	pflagFlag := flagSet(cmd, flag).VarPF(value, flagName, shorthand, description)
+	pflagFlag.NoOptDefVal = "true"
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
 	}
 
-	return nil
+	return markRequired(cmd, flag)
 }
 
 func (h *BoolFlagHandler) ValidateValue(flag *Flag, value string) error {
-	if !flag.IsValidValue(value) {
+	parsed, err := ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean value for flag %s: %w", flag.Name, err)
+	}
+
+	// Valid values are compared as booleans, so yes matches true
+	if len(flag.ValidValues) > 0 {
+		for _, v := range flag.ValidValues {
+			if validValue, err := ParseBool(v); err == nil && validValue == parsed {
+				return nil
+			}
+		}
 		return fmt.Errorf("invalid value for flag %s: %s. Valid values are: %s",
 			flag.Name, value, strings.Join(flag.ValidValues, ", "))
 	}
 	return nil
 }
 
-func (h *BoolFlagHandler) GetValue(cmd *cobra.Command, flagName string) (string, error) {
-	value, _ := cmd.Flags().GetBool(flagName)
-	return fmt.Sprintf("%v", value), nil
+func (h *BoolFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// Values from the environment are reported as true or false too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if parsed, err := ParseBool(value); err == nil {
+			return strconv.FormatBool(parsed), nil
+		}
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetBool(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return strconv.FormatBool(value), nil
+}
+
+func (h *BoolFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	typed, err := ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean value for flag %s: %w", flag.Name, err)
+	}
+	return typed, nil
 }
 
 // IntFlagHandler handles integer flags
 type IntFlagHandler struct{}
 
 func (h *IntFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
-	flagName := NormalizeFlagName(flag.Name)
+	flagName := flag.CLIName()
 	shorthand := NormalizeShorthand(flag.Shorthand)
-	defaultValue := 0
+	// Values are int64 so that values beyond 32 bits work on 32-bit platforms
+	value := &intValue{flag: flag}
 	if flag.Default != "" {
-		if _, err := fmt.Sscanf(flag.Default, "%d", &defaultValue); err != nil {
-			return fmt.Errorf("invalid default value for int flag %s: %w", flagName, err)
+		var err error
+		if value.value, err = parseInt(flag, flag.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
 		}
 	}
 
-	description := flag.GetDescription("en")
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
 
-	if shorthand != "" {
-		cmd.Flags().IntP(flagName, shorthand, defaultValue, description)
-	} else {
-		cmd.Flags().Int(flagName, defaultValue, description)
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *IntFlagHandler) ValidateValue(flag *Flag, value string) error {
+	// An optional flag without a default has no value to check
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if flag.Default == "" {
+			return nil
+		}
+		value = flag.Default
+	}
+
+	intValue, err := parseInt(flag, value)
+	if err != nil {
+		return err
 	}
 
-	if flag.Required {
-		if err := cmd.MarkFlagRequired(flagName); err != nil {
-			return fmt.Errorf("failed to mark flag %s as required: %w", flagName, err)
+	if err := flag.CheckRange(intValue); err != nil {
+		return err
+	}
+
+	// If there are valid values, numbers or ranges, validate against them
+	if len(flag.ValidValues) > 0 {
+		valid, err := flag.matchesIntValidValue(intValue)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("invalid value for flag %s: %d. Valid values are: %s",
+				flag.Name, intValue, strings.Join(flag.ValidValues, ", "))
 		}
 	}
 
 	return nil
 }
 
-func (h *IntFlagHandler) ValidateValue(flag *Flag, value string) error {
-	var intValue int
-	if _, err := fmt.Sscanf(value, "%d", &intValue); err != nil {
-		return fmt.Errorf("invalid integer value for flag %s: %s", flag.Name, value)
+func (h *IntFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+	// Values from the environment are given in canonical form too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if parsed, err := parseInt(flag, value); err == nil {
+			return strconv.FormatInt(parsed, 10), nil
+		}
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetInt64(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return strconv.FormatInt(value, 10), nil
+}
+
+func (h *IntFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	return parseInt(flag, value)
+}
+
+// parseInt parses the decimal value of an int flag, ignoring surrounding
+// whitespace, reporting values that are not integers or do not fit in 64 bits
+func parseInt(flag *Flag, value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("invalid integer value for flag %s: %s is out of the 64-bit integer range", flag.Name, value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer value for flag %s: '%s' is not a decimal integer", flag.Name, value)
+	}
+	return parsed, nil
+}
+
+// FloatFlagHandler handles floating point flags
+type FloatFlagHandler struct{}
+
+func (h *FloatFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &floatValue{flag: flag}
+	if flag.Default != "" {
+		var err error
+		if value.value, err = parseFloat(flag, flag.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *FloatFlagHandler) ValidateValue(flag *Flag, value string) error {
+	floatValue, err := parseFloat(flag, value)
+	if err != nil {
+		return err
 	}
 
-	// If there are valid values, validate against them
+	// Valid values are compared numerically, so 0.5 and .5 are the same value
 	if len(flag.ValidValues) > 0 {
-		validValuesMap := make(map[int]bool)
 		for _, v := range flag.ValidValues {
-			var intValidValue int
-			if _, err := fmt.Sscanf(v, "%d", &intValidValue); err != nil {
-				return fmt.Errorf("invalid valid value for int flag %s: %s", flag.Name, v)
+			validValue, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid valid value for float flag %s: %s", flag.Name, v)
+			}
+			if validValue == floatValue {
+				return nil
 			}
-			validValuesMap[intValidValue] = true
 		}
 
-		if !validValuesMap[intValue] {
-			return fmt.Errorf("invalid value for flag %s: %d. Valid values are: %s",
-				flag.Name, intValue, strings.Join(flag.ValidValues, ", "))
+		return fmt.Errorf("invalid value for flag %s: %s. Valid values are: %s",
+			flag.Name, value, strings.Join(flag.ValidValues, ", "))
+	}
+
+	return nil
+}
+
+func (h *FloatFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+	// Values from the environment are given in canonical form too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if parsed, err := parseFloat(flag, value); err == nil {
+			return strconv.FormatFloat(parsed, 'g', -1, 64), nil
+		}
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetFloat64(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+func (h *FloatFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	return parseFloat(flag, value)
+}
+
+// parseFloat parses the value of a float flag
+func parseFloat(flag *Flag, value string) (float64, error) {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float value for flag %s: '%s' is not a number", flag.Name, value)
+	}
+	return parsed, nil
+}
+
+// DurationFlagHandler handles duration flags such as 30s or 1h30m
+type DurationFlagHandler struct{}
+
+func (h *DurationFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	var defaultValue time.Duration
+	if flag.Default != "" {
+		value, err := time.ParseDuration(flag.Default)
+		if err != nil {
+			return fmt.Errorf("invalid default value for duration flag %s: %w", flagName, err)
+		}
+		defaultValue = value
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+
+	if shorthand != "" {
+		flagSet(cmd, flag).DurationP(flagName, shorthand, defaultValue, description)
+	} else {
+		flagSet(cmd, flag).Duration(flagName, defaultValue, description)
+	}
+
+	pflagFlag := flagSet(cmd, flag).Lookup(flagName)
+	pflagFlag.Value = &durationValue{Value: pflagFlag.Value, flagName: flagName}
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *DurationFlagHandler) ValidateValue(flag *Flag, value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration for flag --%s: '%s'", flag.CLIName(), value)
+	}
+
+	// Valid values are compared as durations, so 90s and 1m30s are the same value
+	if len(flag.ValidValues) > 0 {
+		for _, v := range flag.ValidValues {
+			validValue, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid valid value for duration flag %s: %s", flag.Name, v)
+			}
+			if validValue == duration {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("invalid value for flag %s: %s. Valid values are: %s",
+			flag.Name, value, strings.Join(flag.ValidValues, ", "))
+	}
+
+	return nil
+}
+
+func (h *DurationFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+	// Values from the environment are given in canonical form too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed.String(), nil
+		}
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetDuration(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return value.String(), nil
+}
+
+func (h *DurationFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	typed, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration for flag --%s: '%s'", flag.CLIName(), value)
+	}
+	return typed, nil
+}
+
+// durationValue wraps the pflag duration value to remember invalid input,
+// which FlagErrorFunc then reports instead of the generic pflag message
+type durationValue struct {
+	pflag.Value
+	flagName string
+	err      error
+}
+
+func (v *durationValue) Set(value string) error {
+	if err := v.Value.Set(value); err != nil {
+		v.err = fmt.Errorf("invalid duration for flag --%s: '%s'", v.flagName, value)
+		return v.err
+	}
+	return nil
+}
+
+// recordingValue is implemented by the pflag values of the handlers that
+// remember why the last value given on the command line was invalid
+type recordingValue interface {
+	setError() error
+}
+
+func (v *durationValue) setError() error {
+	return v.err
+}
+
+// intValue is the pflag value of int flags. Unlike the pflag int64 value it
+// accepts decimal input only, like values from the environment.
+type intValue struct {
+	value int64
+	flag  *Flag
+	err   error
+}
+
+func (v *intValue) Set(value string) error {
+	parsed, err := parseInt(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+	v.value = parsed
+	return nil
+}
+
+func (v *intValue) String() string {
+	return strconv.FormatInt(v.value, 10)
+}
+
+func (v *intValue) Type() string {
+	return "int64"
+}
+
+func (v *intValue) setError() error {
+	return v.err
+}
+
+// floatValue is the pflag value of float flags, reporting invalid input
+// like the other values of the handlers
+type floatValue struct {
+	value float64
+	flag  *Flag
+	err   error
+}
+
+func (v *floatValue) Set(value string) error {
+	parsed, err := parseFloat(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+	v.value = parsed
+	return nil
+}
+
+func (v *floatValue) String() string {
+	return strconv.FormatFloat(v.value, 'g', -1, 64)
+}
+
+func (v *floatValue) Type() string {
+	return "float64"
+}
+
+func (v *floatValue) setError() error {
+	return v.err
+}
+
+// FlagErrorFunc reports flag parsing errors with the message of the flag
+// handler when the handler recorded one
+func FlagErrorFunc(cmd *cobra.Command, err error) error {
+	var handlerErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if value, ok := flag.Value.(recordingValue); ok && value.setError() != nil {
+			handlerErr = value.setError()
+		}
+	})
+	if handlerErr != nil {
+		return handlerErr
+	}
+	return err
+}
+
+// BytesFlagHandler handles byte size flags such as 10MB or 1.5GiB
+type BytesFlagHandler struct{}
+
+func (h *BytesFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &bytesValue{flagName: flagName}
+	if flag.Default != "" {
+		if err := value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value for bytes flag %s: %w", flagName, err)
+		}
+		value.err = nil
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	// The help shows the default as written in the configuration
+	if flag.Default != "" {
+		flagSet(cmd, flag).Lookup(flagName).DefValue = flag.Default
+	}
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *BytesFlagHandler) ValidateValue(flag *Flag, value string) error {
+	bytes, err := ParseBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for flag %s: %w", flag.Name, err)
+	}
+	return flag.CheckRange(bytes)
+}
+
+func (h *BytesFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// Sizes are reported as a plain number of bytes, including sizes from
+	// the environment; invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if bytes, err := ParseBytes(value); err == nil {
+			return strconv.FormatInt(bytes, 10), nil
+		}
+		return value, nil
+	}
+
+	return cmd.Flags().Lookup(flag.CLIName()).Value.String(), nil
+}
+
+func (h *BytesFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	bytes, err := ParseBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for flag %s: %w", flag.Name, err)
+	}
+	return bytes, nil
+}
+
+// IntSliceFlagHandler handles flags taking a list of integers, such as --ports 80,443
+type IntSliceFlagHandler struct{}
+
+func (h *IntSliceFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &intSliceValue{values: []int64{}, flag: flag}
+	if flag.Default != "" {
+		defaults, err := parseIntSlice(flag, flag.Default)
+		if err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+		value.values = defaults
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *IntSliceFlagHandler) ValidateValue(flag *Flag, value string) error {
+	values, err := parseIntSlice(flag, value)
+	if err != nil {
+		return err
+	}
+
+	for i, element := range values {
+		if flag.CheckRange(element) != nil {
+			return fmt.Errorf("invalid value for flag %s: element %d (%d) must be %s", flag.Name, i, element, flag.RangeDescription())
+		}
+		if len(flag.ValidValues) == 0 {
+			continue
+		}
+		valid, err := flag.matchesIntValidValue(element)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("invalid value for flag %s: element %d (%d) is not valid. Valid values are: %s",
+				flag.Name, i, element, strings.Join(flag.ValidValues, ", "))
+		}
+	}
+	return nil
+}
+
+func (h *IntSliceFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// Lists are reported as JSON arrays, including lists from the
+	// environment; invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if values, err := parseIntSlice(flag, value); err == nil {
+			return formatIntSlice(values), nil
+		}
+		return value, nil
+	}
+
+	return cmd.Flags().Lookup(flag.CLIName()).Value.String(), nil
+}
+
+func (h *IntSliceFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	return parseIntSlice(flag, value)
+}
+
+// URLFlagHandler handles absolute URL flags, such as --endpoint https://example.com
+type URLFlagHandler struct{}
+
+func (h *URLFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &urlValue{flag: flag}
+	if flag.Default != "" {
+		if err := value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *URLFlagHandler) ValidateValue(flag *Flag, value string) error {
+	_, err := parseURL(flag, value)
+	return err
+}
+
+func (h *URLFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// URLs are reported normalized, including URLs from the environment;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if u, err := parseURL(flag, value); err == nil {
+			return u.String(), nil
+		}
+		return value, nil
+	}
+
+	return cmd.Flags().Lookup(flag.CLIName()).Value.String(), nil
+}
+
+func (h *URLFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	if value == "" {
+		return value, nil
+	}
+	u, err := parseURL(flag, value)
+	if err != nil {
+		return nil, err
+	}
+	return u.String(), nil
+}
+
+// PathFlagHandler handles file system path flags. Values are expanded and
+// made absolute, so plugins get usable host paths.
+type PathFlagHandler struct{}
+
+func (h *PathFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &pathValue{flag: flag}
+	if flag.Default != "" {
+		if err := value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	// The help shows the default as written in the configuration, e.g. ~/.cache
+	if flag.Default != "" {
+		flagSet(cmd, flag).Lookup(flagName).DefValue = flag.Default
+	}
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *PathFlagHandler) ValidateValue(flag *Flag, value string) error {
+	path, err := expandPath(flag, value)
+	if err != nil || path == "" {
+		return err
+	}
+	return checkPath(flag, path)
+}
+
+func (h *PathFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// Paths from the environment are expanded like the ones given on the
+	// command line
+	if value, ok := flag.envValue(cmd); ok {
+		return expandPath(flag, value)
+	}
+
+	return cmd.Flags().Lookup(flag.CLIName()).Value.String(), nil
+}
+
+func (h *PathFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	return value, nil
+}
+
+// TimestampFlagHandler handles timestamp flags such as --since 2024-01-15
+// or --since -24h
+type TimestampFlagHandler struct{}
+
+func (h *TimestampFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	value := &timestampValue{flag: flag}
+	if flag.Default != "" {
+		if err := value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	description := usageDescription(flag, flag.GetDescription(flag.language))
+	flagSet(cmd, flag).VarP(value, flagName, shorthand, description)
+
+	// The help shows the default as written in the configuration, since
+	// relative defaults such as -24h change with every run
+	if flag.Default != "" {
+		flagSet(cmd, flag).Lookup(flagName).DefValue = flag.Default
+	}
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *TimestampFlagHandler) ValidateValue(flag *Flag, value string) error {
+	_, err := parseTimestamp(flag, value)
+	return err
+}
+
+func (h *TimestampFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	// Timestamps from the environment are reported in RFC3339 UTC form too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if t, err := parseTimestamp(flag, value); err == nil {
+			return formatTimestamp(t), nil
 		}
+		return value, nil
+	}
+
+	return cmd.Flags().Lookup(flag.CLIName()).Value.String(), nil
+}
+
+func (h *TimestampFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	if value == "" {
+		return time.Time{}, nil
 	}
+	return parseTimestamp(flag, value)
+}
+
+// CountFlagHandler handles flags counting their occurrences, such as -vvv
+type CountFlagHandler struct{}
+
+func (h *CountFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
+	flagName := flag.CLIName()
+	shorthand := NormalizeShorthand(flag.Shorthand)
+	description := usageDescription(flag, flag.GetDescription(flag.language))
 
+	flagSet(cmd, flag).CountP(flagName, shorthand, description)
+
+	if flag.Default != "" {
+		pflagFlag := flagSet(cmd, flag).Lookup(flagName)
+		if err := pflagFlag.Value.Set(flag.Default); err != nil {
+			return fmt.Errorf("invalid default value for count flag %s: %w", flagName, err)
+		}
+		pflagFlag.DefValue = flag.Default
+	}
+
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
+}
+
+func (h *CountFlagHandler) ValidateValue(flag *Flag, value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return fmt.Errorf("invalid count value for flag %s: %s", flag.Name, value)
+	}
 	return nil
 }
 
-func (h *IntFlagHandler) GetValue(cmd *cobra.Command, flagName string) (string, error) {
-	value, _ := cmd.Flags().GetInt(flagName)
-	return fmt.Sprintf("%d", value), nil
+func (h *CountFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+	// Values from the environment are given in canonical form too;
+	// invalid ones are left for ValidateValue to report
+	if value, ok := flag.envValue(cmd); ok {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return strconv.Itoa(parsed), nil
+		}
+		return value, nil
+	}
+
+	flagName := flag.CLIName()
+	value, err := cmd.Flags().GetCount(flagName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read flag %s: %w", flagName, err)
+	}
+	return strconv.Itoa(value), nil
+}
+
+func (h *CountFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
+	typed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid count value for flag %s: %s", flag.Name, value)
+	}
+	return typed, nil
 }
 
 // EnumFlagHandler handles enum flags
 type EnumFlagHandler struct{}
 
 func (h *EnumFlagHandler) AddFlag(cmd *cobra.Command, flag *Flag) error {
-	flagName := NormalizeFlagName(flag.Name)
+	flagName := flag.CLIName()
 	shorthand := NormalizeShorthand(flag.Shorthand)
 	defaultValue := flag.Default
-	description := flag.GetDescription("en")
-
-	if len(flag.ValidValues) > 0 {
-		description = fmt.Sprintf("%s (valid values: %s)", description, strings.Join(flag.ValidValues, ", "))
-	}
+	description := usageDescription(flag, flag.GetDescription(flag.language))
 
 	if shorthand != "" {
-		cmd.Flags().StringP(flagName, shorthand, defaultValue, description)
+		flagSet(cmd, flag).StringP(flagName, shorthand, defaultValue, description)
 	} else {
-		cmd.Flags().String(flagName, defaultValue, description)
+		flagSet(cmd, flag).String(flagName, defaultValue, description)
 	}
 
-	if flag.Required {
-		if err := cmd.MarkFlagRequired(flagName); err != nil {
-			return fmt.Errorf("failed to mark flag %s as required: %w", flagName, err)
-		}
+	if err := cmd.RegisterFlagCompletionFunc(flagName, completeValidValues(flag)); err != nil {
+		return fmt.Errorf("failed to register completion for flag %s: %w", flagName, err)
 	}
 
-	return nil
+	if err := markHidden(cmd, flag); err != nil {
+		return err
+	}
+
+	return markRequired(cmd, flag)
 }
 
 func (h *EnumFlagHandler) ValidateValue(flag *Flag, value string) error {
@@ -177,8 +904,7 @@ func (h *EnumFlagHandler) ValidateValue(flag *Flag, value string) error {
 	}
 
 	// Always validate against valid values for enum flags
-	validValuesMap := flag.GetValidValues()
-	if !validValuesMap[value] {
+	if _, ok := flag.Canonical(value); !ok {
 		return fmt.Errorf("invalid value for flag %s: %s. Valid values are: %s",
 			flag.Name, value, strings.Join(flag.ValidValues, ", "))
 	}
@@ -186,11 +912,90 @@ func (h *EnumFlagHandler) ValidateValue(flag *Flag, value string) error {
 	return nil
 }
 
-func (h *EnumFlagHandler) GetValue(cmd *cobra.Command, flagName string) (string, error) {
-	value, _ := cmd.Flags().GetString(flagName)
+func (h *EnumFlagHandler) GetValue(cmd *cobra.Command, flag *Flag) (string, error) {
+	if err := checkRegistered(cmd, flag); err != nil {
+		return "", err
+	}
+
+	value, ok := flag.envValue(cmd)
+	if !ok {
+		var err error
+		if value, err = cmd.Flags().GetString(flag.CLIName()); err != nil {
+			return "", fmt.Errorf("failed to read flag %s: %w", flag.CLIName(), err)
+		}
+	}
+
+	// Aliases and other spellings are reported as the valid value they stand for
+	canonical, _ := flag.Canonical(value)
+	return canonical, nil
+}
+
+func (h *EnumFlagHandler) TypedValue(flag *Flag, value string) (interface{}, error) {
 	return value, nil
 }
 
+// flagSet returns the flag set of cmd a flag is added to
+func flagSet(cmd *cobra.Command, flag *Flag) *pflag.FlagSet {
+	if flag.Persistent {
+		return cmd.PersistentFlags()
+	}
+	return cmd.Flags()
+}
+
+// checkRegistered reports a flag that was never added to cmd, which would
+// otherwise read as an empty value
+func checkRegistered(cmd *cobra.Command, flag *Flag) error {
+	if cmd.Flag(flag.CLIName()) == nil {
+		return fmt.Errorf("flag %s is not registered on command %s", flag.CLIName(), cmd.CommandPath())
+	}
+	return nil
+}
+
+// markHidden hides a hidden flag from the help output
+func markHidden(cmd *cobra.Command, flag *Flag) error {
+	if !flag.Hidden {
+		return nil
+	}
+
+	flagName := flag.CLIName()
+	if err := flagSet(cmd, flag).MarkHidden(flagName); err != nil {
+		return fmt.Errorf("failed to mark flag %s as hidden: %w", flagName, err)
+	}
+	return nil
+}
+
+// ShowHidden reveals the hidden flags of cmd and its subcommands in the help
+// output. Deprecated flags stay hidden.
+func ShowHidden(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Deprecated == "" {
+			flag.Hidden = false
+		}
+	})
+	for _, child := range cmd.Commands() {
+		ShowHidden(child)
+	}
+}
+
+// markRequired marks a required flag for ValidateRequired, unless the
+// environment variable bound to the flag provides its value. The annotation
+// holds the language of the error message.
+func markRequired(cmd *cobra.Command, flag *Flag) error {
+	if !flag.Required {
+		return nil
+	}
+
+	if _, ok := flag.lookupEnv(); ok {
+		return nil
+	}
+
+	flagName := flag.CLIName()
+	if err := flagSet(cmd, flag).SetAnnotation(flagName, requiredAnnotation, []string{flag.language}); err != nil {
+		return fmt.Errorf("failed to mark flag %s as required: %w", flagName, err)
+	}
+	return nil
+}
+
 // GetHandler returns the appropriate handler for a flag type
 func GetHandler(flagType FlagType, flag *Flag) FlagHandler {
 	// If the flag has valid values, treat it as an enum regardless of its type
@@ -205,6 +1010,22 @@ func GetHandler(flagType FlagType, flag *Flag) FlagHandler {
 		return &BoolFlagHandler{}
 	case TypeInt:
 		return &IntFlagHandler{}
+	case TypeFloat:
+		return &FloatFlagHandler{}
+	case TypeDuration:
+		return &DurationFlagHandler{}
+	case TypeCount:
+		return &CountFlagHandler{}
+	case TypeBytes:
+		return &BytesFlagHandler{}
+	case TypeIntSlice:
+		return &IntSliceFlagHandler{}
+	case TypeURL:
+		return &URLFlagHandler{}
+	case TypePath:
+		return &PathFlagHandler{}
+	case TypeTimestamp:
+		return &TimestampFlagHandler{}
 	default:
 		return &StringFlagHandler{} // Default to string handler
 	}
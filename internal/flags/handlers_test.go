@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestIntFlagHandlerValidateValue(t *testing.T) {
+	zero := int64(0)
+	tests := []struct {
+		name    string
+		flag    Flag
+		value   string
+		wantErr string
+	}{
+		{name: "empty without default", value: ""},
+		{name: "empty with valid default", flag: Flag{Default: "5"}, value: ""},
+		{name: "empty with invalid default", flag: Flag{Default: "five"}, value: "", wantErr: "'five' is not a decimal integer"},
+		{name: "zero", value: "0"},
+		{name: "trailing letters", value: "12abc", wantErr: "'12abc' is not a decimal integer"},
+		{name: "negative", value: "-3"},
+		{name: "negative below min", flag: Flag{Min: &zero}, value: "-3", wantErr: "invalid value for flag --count: -3. Value must be at least 0"},
+		{name: "surrounding whitespace", value: "  7\t"},
+		{name: "only whitespace", value: "   "},
+		{name: "inner whitespace", value: "1 2", wantErr: "'1 2' is not a decimal integer"},
+		{name: "valid value", flag: Flag{ValidValues: []string{"1", "3"}}, value: "3"},
+		{name: "not a valid value", flag: Flag{ValidValues: []string{"1", "3"}}, value: "2", wantErr: "Valid values are: 1, 3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flag := test.flag
+			flag.Name = "--count"
+			flag.Type = TypeInt
+			err := (&IntFlagHandler{}).ValidateValue(&flag, test.value)
+			checkError(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr string
+	}{
+		{value: "42", want: 42},
+		{value: "+42", want: 42},
+		{value: "-42", want: -42},
+		{value: "9223372036854775807", want: 9223372036854775807},
+		{value: "-9223372036854775808", want: -9223372036854775808},
+		// Beyond 32 bits, which int64 values hold on every platform
+		{value: "4294967296", want: 4294967296},
+		{value: "9223372036854775808", wantErr: "invalid integer value for flag --count: 9223372036854775808 is out of the 64-bit integer range"},
+		{value: "-9223372036854775809", wantErr: "invalid integer value for flag --count: -9223372036854775809 is out of the 64-bit integer range"},
+		{value: "0x10", wantErr: "invalid integer value for flag --count: '0x10' is not a decimal integer"},
+		{value: "010", want: 10},
+		{value: "7 bananas", wantErr: "'7 bananas' is not a decimal integer"},
+		{value: "7.5", wantErr: "'7.5' is not a decimal integer"},
+		{value: "1e3", wantErr: "'1e3' is not a decimal integer"},
+		{value: "++1", wantErr: "'++1' is not a decimal integer"},
+	}
+
+	flag := &Flag{Name: "--count", Type: TypeInt}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseInt(flag, test.value)
+			checkError(t, err, test.wantErr)
+			if err == nil && got != test.want {
+				t.Errorf("parseInt(%q) = %d, want %d", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIntFlagDefaultOverflow(t *testing.T) {
+	flag := &Flag{Name: "--count", Type: TypeInt, Default: "9223372036854775808"}
+	err := (&IntFlagHandler{}).AddFlag(&cobra.Command{Use: "test"}, flag)
+	checkError(t, err, "invalid default value: invalid integer value for flag --count: 9223372036854775808 is out of the 64-bit integer range")
+}
+
+// checkError fails the test unless err contains want, or is nil when want
+// is empty
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("no error, want one containing %q", want)
+	} else if !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
@@ -0,0 +1,95 @@
+package flags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// usageTemplate is the usage template of plugin commands. It lists required
+// flags in their own section ahead of the optional ones.
+const usageTemplate = `Usage:{{if .Runnable}}
+  {{.UseLine}}{{end}}{{if gt (len .Aliases) 0}}
+
+Aliases:
+  {{.NameAndAliases}}{{end}}{{if .HasExample}}
+
+Examples:
+{{.Example}}{{end}}{{if hasRequiredFlags .}}
+
+Required Flags:
+{{requiredFlagUsages . | trimTrailingWhitespaces}}{{end}}{{if hasOptionalFlags .}}
+
+Flags:
+{{optionalFlagUsages . | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
+
+Global Flags:
+{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}
+`
+
+func init() {
+	cobra.AddTemplateFunc("hasRequiredFlags", func(cmd *cobra.Command) bool {
+		return requiredFlags(cmd, true).HasAvailableFlags()
+	})
+	cobra.AddTemplateFunc("hasOptionalFlags", func(cmd *cobra.Command) bool {
+		return requiredFlags(cmd, false).HasAvailableFlags()
+	})
+	cobra.AddTemplateFunc("requiredFlagUsages", func(cmd *cobra.Command) string {
+		return requiredFlags(cmd, true).FlagUsages()
+	})
+	cobra.AddTemplateFunc("optionalFlagUsages", func(cmd *cobra.Command) string {
+		return requiredFlags(cmd, false).FlagUsages()
+	})
+}
+
+// ApplyHelpTemplate customizes the help output of a plugin command
+func ApplyHelpTemplate(cmd *cobra.Command) {
+	cmd.SetUsageTemplate(usageTemplate)
+}
+
+// requiredFlags returns the local flags of cmd that are, or are not, required
+func requiredFlags(cmd *cobra.Command, required bool) *pflag.FlagSet {
+	set := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		if isRequired(flag) == required {
+			set.AddFlag(flag)
+		}
+	})
+	return set
+}
+
+// isRequired reports whether a flag was marked required by markRequired
+func isRequired(flag *pflag.Flag) bool {
+	return len(flag.Annotations[requiredAnnotation]) > 0
+}
+
+// Labels of the flag help text, in the languages wpcli translates them to
+var (
+	validValuesLabel = i18n.Text{"en": "valid values", "es": "valores válidos", "it": "valori validi"}
+	envLabel         = i18n.Text{"en": "env", "es": "entorno", "it": "ambiente"}
+	requiredLabel    = i18n.Text{"en": "required", "es": "obligatorio", "it": "obbligatorio"}
+)
+
+// ValidValuesLabel returns the label of the valid values in help text, so
+// the arguments of plugin commands list them like flags do
+func ValidValuesLabel(language string) string {
+	return validValuesLabel.Get(language)
+}
+
+// usageDescription returns the help text of a flag, listing the valid values
+// inline and marking required flags
+func usageDescription(flag *Flag, description string) string {
+	if len(flag.ValidValues) > 0 {
+		description = fmt.Sprintf("%s (%s: %s)", description, validValuesLabel.Get(flag.language), strings.Join(flag.ValidValues, ", "))
+	}
+	if flag.Env != "" {
+		description = fmt.Sprintf("%s (%s: %s)", description, envLabel.Get(flag.language), flag.Env)
+	}
+	if flag.Required {
+		description = fmt.Sprintf("%s (%s)", description, requiredLabel.Get(flag.language))
+	}
+	return description
+}
@@ -0,0 +1,83 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseIntSlice parses the value of an int slice flag, given either as
+// comma separated numbers or as the JSON array GetValue returns
+func parseIntSlice(flag *Flag, value string) ([]int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return []int64{}, nil
+	}
+
+	if strings.HasPrefix(value, "[") {
+		var values []int64
+		if err := json.Unmarshal([]byte(value), &values); err != nil {
+			return nil, fmt.Errorf("invalid value for flag %s: %s is not a list of integers", flag.Name, value)
+		}
+		return values, nil
+	}
+
+	elements := strings.Split(value, ",")
+	values := make([]int64, len(elements))
+	for i, element := range elements {
+		parsed, err := strconv.ParseInt(strings.TrimSpace(element), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for flag %s: element %d ('%s') is not an integer", flag.Name, i, element)
+		}
+		values[i] = parsed
+	}
+	return values, nil
+}
+
+// formatIntSlice formats the values of an int slice flag as a JSON array
+func formatIntSlice(values []int64) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.FormatInt(value, 10)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// intSliceValue is the pflag value of int slice flags. Like the pflag slice
+// values, the flag can be repeated and each occurrence adds comma separated
+// numbers, replacing the default on the first occurrence.
+type intSliceValue struct {
+	values  []int64
+	flag    *Flag
+	changed bool
+	err     error
+}
+
+func (v *intSliceValue) Set(value string) error {
+	values, err := parseIntSlice(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+
+	if v.changed {
+		v.values = append(v.values, values...)
+	} else {
+		v.values = values
+		v.changed = true
+	}
+	return nil
+}
+
+func (v *intSliceValue) String() string {
+	return formatIntSlice(v.values)
+}
+
+func (v *intSliceValue) Type() string {
+	return "intSlice"
+}
+
+func (v *intSliceValue) setError() error {
+	return v.err
+}
@@ -0,0 +1,132 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandPath expands a leading ~ and environment variables in the value of
+// a path flag and makes it absolute. An empty value stays empty.
+func expandPath(flag *Flag, value string) (string, error) {
+	path, err := ExpandPath(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for flag %s: %w", flag.Name, err)
+	}
+	return path, nil
+}
+
+// ExpandPath expands environment variables and a leading ~ in a path and
+// makes it absolute, resolving relative paths against the working directory.
+// An empty path stays empty.
+func ExpandPath(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	value = os.ExpandEnv(value)
+	if value == "~" || strings.HasPrefix(value, "~/") || strings.HasPrefix(value, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand ~: %w", err)
+		}
+		value = filepath.Join(home, value[1:])
+	}
+
+	path, err := filepath.Abs(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", value, err)
+	}
+	return path, nil
+}
+
+// checkPath performs the filesystem checks requested by the flag on an
+// expanded path. must_be_dir and must_be_writable apply to the path when it
+// exists; a missing path must be creatable in a writable directory instead.
+func checkPath(flag *Flag, path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if flag.MustExist {
+			return fmt.Errorf("invalid value for flag %s: path %s does not exist", flag.Name, path)
+		}
+		if flag.MustBeWritable {
+			parent := filepath.Dir(path)
+			if err := checkWritableDir(parent); err != nil {
+				return fmt.Errorf("invalid value for flag %s: path %s does not exist and its directory %s is not writable", flag.Name, path, parent)
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for flag %s: failed to check path %s: %w", flag.Name, path, err)
+	}
+
+	if flag.MustBeDir && !info.IsDir() {
+		return fmt.Errorf("invalid value for flag %s: path %s exists but is not a directory", flag.Name, path)
+	}
+
+	if flag.MustBeWritable {
+		writable := checkWritableDir
+		if !info.IsDir() {
+			writable = checkWritableFile
+		}
+		if err := writable(path); err != nil {
+			return fmt.Errorf("invalid value for flag %s: path %s is not writable", flag.Name, path)
+		}
+	}
+	return nil
+}
+
+// checkWritableDir checks that files can be created in a directory by
+// creating and removing a temporary one, which works on every platform
+func checkWritableDir(dir string) error {
+	file, err := os.CreateTemp(dir, ".wpcli-write-check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkWritableFile checks that a file can be opened for writing, without
+// truncating it
+func checkWritableFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// pathValue is the pflag value of path flags, holding the expanded path
+type pathValue struct {
+	path string
+	flag *Flag
+	err  error
+}
+
+func (v *pathValue) Set(value string) error {
+	path, err := expandPath(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+	v.path = path
+	return nil
+}
+
+func (v *pathValue) String() string {
+	return v.path
+}
+
+func (v *pathValue) Type() string {
+	return "path"
+}
+
+func (v *pathValue) setError() error {
+	return v.err
+}
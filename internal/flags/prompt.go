@@ -0,0 +1,169 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/spf13/cobra"
+)
+
+// maxPromptAttempts is how many answers are read for a flag before it is
+// left to ValidateRequired
+const maxPromptAttempts = 3
+
+// Messages of the prompts
+var (
+	promptValue = i18n.Text{
+		"en": "Value",
+		"es": "Valor",
+		"it": "Valore",
+	}
+	promptChoice = i18n.Text{
+		"en": "Number or value",
+		"es": "Número o valor",
+		"it": "Numero o valore",
+	}
+	promptEmpty = i18n.Text{
+		"en": "A value is required",
+		"es": "Se requiere un valor",
+		"it": "È richiesto un valore",
+	}
+)
+
+// Prompter asks the user for the values of the required flags a command is
+// run without
+type Prompter struct {
+	// In and Out are where answers are read from and prompts written to
+	In  io.Reader
+	Out io.Writer
+	// ReadSecret reads the value of a sensitive flag without echoing it.
+	// Without it, sensitive values are read from In like the others.
+	ReadSecret func() (string, error)
+}
+
+// PromptRequired asks for the required flags of cmd that were not given, in
+// the order of flags, and sets them to the answers. A flag left without a
+// value, because input ended or the answers were invalid, is reported by
+// ValidateRequired afterwards.
+func (p *Prompter) PromptRequired(cmd *cobra.Command, flags []*Flag) error {
+	for _, flag := range flags {
+		pflag := cmd.Flags().Lookup(flag.CLIName())
+		if pflag == nil || pflag.Changed || len(pflag.Annotations[requiredAnnotation]) == 0 {
+			continue
+		}
+		if err := p.prompt(cmd, flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prompt asks for the value of a flag. The valid values of enum flags are
+// offered as a numbered menu, and the default is taken on an empty answer.
+func (p *Prompter) prompt(cmd *cobra.Command, flag *Flag) error {
+	handler := GetHandler(flag.Type, flag)
+	_, isEnum := handler.(*EnumFlagHandler)
+
+	fmt.Fprintf(p.Out, "--%s", flag.CLIName())
+	if description := flag.GetDescription(flag.language); description != "" {
+		fmt.Fprintf(p.Out, ": %s", description)
+	}
+	fmt.Fprintln(p.Out)
+
+	label := promptValue.Get(flag.language)
+	if isEnum {
+		label = promptChoice.Get(flag.language)
+		for i, value := range flag.ValidValues {
+			fmt.Fprintf(p.Out, "  %d) %s", i+1, value)
+			if description := flag.ValueDescriptions[value].Get(flag.language); description != "" {
+				fmt.Fprintf(p.Out, " - %s", description)
+			}
+			fmt.Fprintln(p.Out)
+		}
+	}
+	if flag.Default != "" {
+		if flag.Sensitive {
+			label = fmt.Sprintf("%s [%s]", label, MaskedValue)
+		} else {
+			label = fmt.Sprintf("%s [%s]", label, flag.Default)
+		}
+	}
+
+	for attempt := 0; attempt < maxPromptAttempts; attempt++ {
+		fmt.Fprintf(p.Out, "%s: ", label)
+		answer, err := p.read(flag)
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(p.Out)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the value of flag %s: %w", flag.CLIName(), err)
+		}
+
+		answer = strings.TrimRight(answer, "\r")
+		if !flag.Sensitive {
+			answer = strings.TrimSpace(answer)
+		}
+		if answer == "" {
+			answer = flag.Default
+		}
+		// Numbers choose from the menu, unless they are valid values themselves
+		if n, err := strconv.Atoi(answer); isEnum && err == nil && n >= 1 && n <= len(flag.ValidValues) && !flag.IsValidValue(answer) {
+			answer = flag.ValidValues[n-1]
+		}
+		if answer == "" {
+			fmt.Fprintln(p.Out, promptEmpty.Get(flag.language))
+			continue
+		}
+
+		if err := handler.ValidateValue(flag, answer); err != nil {
+			fmt.Fprintln(p.Out, err)
+			continue
+		}
+		if err := cmd.Flags().Set(flag.CLIName(), answer); err != nil {
+			fmt.Fprintln(p.Out, err)
+			continue
+		}
+		return nil
+	}
+	return nil
+}
+
+// read reads an answer, without echoing the ones for sensitive flags
+func (p *Prompter) read(flag *Flag) (string, error) {
+	if flag.Sensitive && p.ReadSecret != nil {
+		answer, err := p.ReadSecret()
+		if err == nil {
+			// The newline of the answer was not echoed either
+			fmt.Fprintln(p.Out)
+		}
+		return answer, err
+	}
+	return ReadLine(p.In)
+}
+
+// ReadLine reads a line a byte at a time, so the rest of the input is left
+// to the plugin module
+func ReadLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
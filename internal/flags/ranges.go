@@ -0,0 +1,53 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseIntRange parses a valid value of an int flag, either a number or an
+// inclusive range such as 1-1023. Negative bounds are allowed, as in -10--1.
+func parseIntRange(entry string) (low, high int64, err error) {
+	entry = strings.TrimSpace(entry)
+
+	// The first character may be the sign of the lower bound
+	separator := -1
+	if len(entry) > 1 {
+		if i := strings.Index(entry[1:], "-"); i != -1 {
+			separator = i + 1
+		}
+	}
+	if separator == -1 {
+		value, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("'%s' is neither an integer nor a range", entry)
+		}
+		return value, value, nil
+	}
+
+	low, lowErr := strconv.ParseInt(strings.TrimSpace(entry[:separator]), 10, 64)
+	high, highErr := strconv.ParseInt(strings.TrimSpace(entry[separator+1:]), 10, 64)
+	if lowErr != nil || highErr != nil {
+		return 0, 0, fmt.Errorf("'%s' is not a valid range, ranges are written as 1-1023", entry)
+	}
+	if low > high {
+		return 0, 0, fmt.Errorf("range '%s' has its lower bound above its upper bound", entry)
+	}
+	return low, high, nil
+}
+
+// matchesIntValidValue reports whether value is one of the numbers or falls
+// in one of the ranges listed in the valid values of an int flag
+func (f *Flag) matchesIntValidValue(value int64) (bool, error) {
+	for _, entry := range f.ValidValues {
+		low, high, err := parseIntRange(entry)
+		if err != nil {
+			return false, fmt.Errorf("invalid valid value for int flag %s: %w", f.Name, err)
+		}
+		if value >= low && value <= high {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,44 @@
+package flags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// requiredAnnotation marks on a pflag a flag that must be given. Cobra's own
+// required check runs before PreRunE and cannot be localized, so wpcli
+// checks required flags itself with ValidateRequired.
+const requiredAnnotation = "wpcli/required"
+
+// requiredMessage is the error reported for missing required flags
+var requiredMessage = i18n.Text{
+	"en": "required flag(s) %s not set",
+	"es": "flag(s) obligatorio(s) %s no establecido(s)",
+	"it": "flag obbligatori %s non impostati",
+}
+
+// ValidateRequired reports the required flags of cmd that were not given, in
+// the language the flags were added with
+func ValidateRequired(cmd *cobra.Command) error {
+	var missing []string
+	language := ""
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		values := flag.Annotations[requiredAnnotation]
+		if len(values) == 0 || flag.Changed {
+			return
+		}
+		missing = append(missing, fmt.Sprintf("%q", flag.Name))
+		language = values[0]
+	})
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf(requiredMessage.Get(language), strings.Join(missing, ", "))
+}
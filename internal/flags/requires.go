@@ -0,0 +1,89 @@
+package flags
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// checkDependencies reports requires entries naming flags that are neither
+// declared nor inherited, and flags that require each other, directly or
+// through other flags
+func checkDependencies(flags, inherited []*Flag) error {
+	visible := append(append([]*Flag{}, inherited...), flags...)
+	for _, flag := range flags {
+		for _, name := range flag.Requires {
+			if findFlag(visible, name) == nil {
+				return fmt.Errorf("flag %s requires undeclared flag %s", flag.Name, name)
+			}
+		}
+	}
+
+	// Depth first search for cycles, tracking the flags on the current path
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*Flag]int)
+	var visit func(flag *Flag) error
+	visit = func(flag *Flag) error {
+		switch state[flag] {
+		case visiting:
+			return fmt.Errorf("flag %s is part of a circular requires chain", flag.Name)
+		case done:
+			return nil
+		}
+
+		state[flag] = visiting
+		for _, name := range flag.Requires {
+			if err := visit(findFlag(visible, name)); err != nil {
+				return err
+			}
+		}
+		state[flag] = done
+		return nil
+	}
+
+	for _, flag := range flags {
+		if err := visit(flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRequiresCheck makes cmd check the requires of its flags and of the flags
+// it inherits before running, after the PreRunE cmd already has. The parent
+// command does not run the checks of the flags it passes down.
+func addRequiresCheck(cmd *cobra.Command, flags, inherited []*Flag) {
+	visible := append(append([]*Flag{}, inherited...), flags...)
+	hasRequires := false
+	for _, flag := range visible {
+		hasRequires = hasRequires || len(flag.Requires) > 0
+	}
+	if !hasRequires {
+		return
+	}
+
+	ChainPreRunE(cmd, func(cmd *cobra.Command, args []string) error {
+		return CheckRequires(cmd, visible)
+	})
+}
+
+// CheckRequires reports a flag that is set while a flag it requires is not.
+// Flags count as set when given on the command line or in their environment
+// variable; a default value does not satisfy a requirement.
+func CheckRequires(cmd *cobra.Command, flags []*Flag) error {
+	for _, flag := range flags {
+		if len(flag.Requires) == 0 || !flag.IsSet(cmd) {
+			continue
+		}
+		for _, name := range flag.Requires {
+			required := findFlag(flags, name)
+			if required != nil && !required.IsSet(cmd) {
+				return fmt.Errorf("flag --%s requires --%s to be set", flag.CLIName(), required.CLIName())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package flags
+
+import (
+	"reflect"
+
+	"github.com/spf13/cobra"
+)
+
+// Sources of flag values, telling where the value of a flag comes from
+const (
+	// SourceFlag is a value given on the command line, including through a
+	// deprecated flag it replaces or as the answer to a prompt
+	SourceFlag = "flag"
+	// SourceEnv is a value read from the environment variable of the flag
+	SourceEnv = "env"
+	// SourceConfigDefault is a default taken from the wpcli configuration.
+	// No flag takes its default from there yet, so the source is reserved.
+	SourceConfigDefault = "config_default"
+	// SourceManifestDefault is the default declared by the plugin
+	SourceManifestDefault = "manifest_default"
+)
+
+// Provenance tells where the value of a flag comes from
+type Provenance struct {
+	Source string
+	// Changed reports whether the value differs from the default declared
+	// by the plugin
+	Changed bool
+}
+
+// GetFlagProvenance returns where the values of GetTypedFlagValues come
+// from, keyed the same way
+func GetFlagProvenance(cmd *cobra.Command, flags []*Flag, values map[string]interface{}) map[string]Provenance {
+	provenance := make(map[string]Provenance)
+	for _, flag := range flags {
+		name := NormalizeFlagName(flag.Name)
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		source := flag.source(cmd)
+		provenance[name] = Provenance{
+			Source:  source,
+			Changed: source != SourceManifestDefault && !flag.isDefault(cmd, value),
+		}
+	}
+	return provenance
+}
+
+// source returns where the value of the flag comes from
+func (f *Flag) source(cmd *cobra.Command) string {
+	if cmd.Flags().Changed(f.CLIName()) {
+		return SourceFlag
+	}
+	if _, ok := f.lookupEnv(); ok {
+		return SourceEnv
+	}
+	return SourceManifestDefault
+}
+
+// isDefault reports whether a typed value of the flag is its default
+func (f *Flag) isDefault(cmd *cobra.Command, value interface{}) bool {
+	declared := cmd.Flags().Lookup(f.CLIName())
+	if declared == nil {
+		return false
+	}
+	defaultValue, err := GetHandler(f.Type, f).TypedValue(f, declared.DefValue)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(value, defaultValue)
+}
@@ -0,0 +1,77 @@
+package flags
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultLayouts are the layouts of timestamp flags without layouts
+var defaultLayouts = []string{time.RFC3339, time.DateOnly}
+
+// timestampLayouts returns the layouts accepted by a timestamp flag
+func (f *Flag) timestampLayouts() []string {
+	if len(f.Layouts) > 0 {
+		return f.Layouts
+	}
+	return defaultLayouts
+}
+
+// parseTimestamp parses the value of a timestamp flag using the layouts of
+// the flag, or as a duration relative to now such as -24h. Values without a
+// time zone are in UTC. RFC3339 is always accepted, being the form GetValue
+// returns.
+func parseTimestamp(flag *Flag, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		if offset, err := time.ParseDuration(value); err == nil {
+			return time.Now().Add(offset).UTC(), nil
+		}
+	}
+
+	for _, layout := range append(slices.Clip(flag.timestampLayouts()), time.RFC3339) {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid value for flag %s: '%s' is not a valid timestamp. Accepted layouts are: %s, or a duration relative to now such as -24h",
+		flag.Name, value, strings.Join(flag.timestampLayouts(), ", "))
+}
+
+// formatTimestamp formats a timestamp the way GetValue reports it
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// timestampValue is the pflag value of timestamp flags, holding the
+// timestamp in RFC3339 UTC form
+type timestampValue struct {
+	value string
+	flag  *Flag
+	err   error
+}
+
+func (v *timestampValue) Set(value string) error {
+	t, err := parseTimestamp(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+	v.value = formatTimestamp(t)
+	return nil
+}
+
+func (v *timestampValue) String() string {
+	return v.value
+}
+
+func (v *timestampValue) Type() string {
+	return "timestamp"
+}
+
+func (v *timestampValue) setError() error {
+	return v.err
+}
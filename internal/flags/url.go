@@ -0,0 +1,89 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// defaultAllowedSchemes are the schemes of url flags without allowed_schemes
+var defaultAllowedSchemes = []string{"https", "http"}
+
+// allowedSchemes returns the schemes accepted by a url flag
+func (f *Flag) allowedSchemes() []string {
+	if len(f.AllowedSchemes) > 0 {
+		return f.AllowedSchemes
+	}
+	return defaultAllowedSchemes
+}
+
+// parseURL parses the value of a url flag, which must be an absolute URL
+// with one of the allowed schemes and, except for file URLs, a host
+func parseURL(flag *Flag, value string) (*url.URL, error) {
+	value = strings.TrimSpace(value)
+	u, err := url.Parse(value)
+	if err != nil {
+		// url.Parse errors repeat the whole value, keep only the reason
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid value for flag %s: '%s' is not a valid URL: %v", flag.Name, value, err)
+	}
+
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("invalid value for flag %s: '%s': missing scheme", flag.Name, value)
+	}
+
+	allowed := flag.allowedSchemes()
+	schemeAllowed := false
+	for _, scheme := range allowed {
+		if strings.EqualFold(scheme, u.Scheme) {
+			schemeAllowed = true
+			break
+		}
+	}
+	if !schemeAllowed {
+		return nil, fmt.Errorf("invalid value for flag %s: '%s': scheme %s not allowed, allowed schemes are %s",
+			flag.Name, value, u.Scheme, strings.Join(allowed, ", "))
+	}
+
+	if u.Host == "" && u.Scheme != "file" {
+		return nil, fmt.Errorf("invalid value for flag %s: '%s': missing host", flag.Name, value)
+	}
+
+	// url.Parse already lowercases the scheme; the host is case insensitive
+	// too, while the user info, path and query are kept as given
+	u.Host = strings.ToLower(u.Host)
+	return u, nil
+}
+
+// urlValue is the pflag value of url flags, holding the normalized URL
+type urlValue struct {
+	value string
+	flag  *Flag
+	err   error
+}
+
+func (v *urlValue) Set(value string) error {
+	u, err := parseURL(v.flag, value)
+	if err != nil {
+		v.err = err
+		return err
+	}
+	v.value = u.String()
+	return nil
+}
+
+func (v *urlValue) String() string {
+	return v.value
+}
+
+func (v *urlValue) Type() string {
+	return "url"
+}
+
+func (v *urlValue) setError() error {
+	return v.err
+}
@@ -1,16 +1,58 @@
 package flags
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
-// AddFlags adds multiple flags to a command
-func AddFlags(cmd *cobra.Command, flags []*Flag) error {
+// manifestNameAnnotation records on a pflag the name declared by the plugin
+// when the flag is exposed under another name
+const manifestNameAnnotation = "wpcli/manifest-name"
+
+// sensitiveAnnotation marks on a pflag a flag whose value must not be echoed
+const sensitiveAnnotation = "wpcli/sensitive"
+
+// MaskedValue replaces the values of sensitive flags in output
+const MaskedValue = "***"
+
+// manifestName returns the name the plugin declared for a flag
+func manifestName(flag *pflag.Flag) string {
+	if values := flag.Annotations[manifestNameAnnotation]; len(values) > 0 {
+		return values[0]
+	}
+	return flag.Name
+}
+
+// AddFlags adds multiple flags to a command, with help and error messages in
+// the given language when the flags provide it
+func AddFlags(logger *slog.Logger, cmd *cobra.Command, flags []*Flag, language string) error {
+	return AddInheritingFlags(logger, cmd, flags, nil, language)
+}
+
+// AddInheritingFlags adds flags like AddFlags to a command that also inherits
+// the persistent flags of its parent, such as the plugin level flags of a
+// group, which the requires entries of flags can name
+func AddInheritingFlags(logger *slog.Logger, cmd *cobra.Command, flags, inherited []*Flag, language string) error {
+	// pflag panics on duplicate names, so they are reported before any flag is added
+	if err := checkDuplicates(flags); err != nil {
+		return err
+	}
+
+	cmd.SetFlagErrorFunc(FlagErrorFunc)
+
 	for _, flag := range flags {
+		flag.language = language
+
+		// The default is expanded first so it is validated and shown in
+		// the help as resolved
+		flag.expandDefault(logger)
+
 		if err := flag.Validate(); err != nil {
 			return fmt.Errorf("invalid flag configuration: %w", err)
 		}
@@ -19,55 +61,234 @@ func AddFlags(cmd *cobra.Command, flags []*Flag) error {
 		if err := handler.AddFlag(cmd, flag); err != nil {
 			return fmt.Errorf("failed to add flag %s: %w", flag.Name, err)
 		}
+
+		if flag.ImplicitValue != "" {
+			if err := handler.ValidateValue(flag, flag.ImplicitValue); err != nil {
+				return fmt.Errorf("invalid implicit value: %w", err)
+			}
+			flagSet(cmd, flag).Lookup(flag.CLIName()).NoOptDefVal = flag.ImplicitValue
+		}
+
+		if flag.Sensitive {
+			if err := flagSet(cmd, flag).SetAnnotation(flag.CLIName(), sensitiveAnnotation, []string{"true"}); err != nil {
+				return err
+			}
+		}
+
+		// Renamed flags are reported under the name the plugin declared
+		if flag.ExposeAs != "" {
+			if err := flagSet(cmd, flag).SetAnnotation(flag.CLIName(), manifestNameAnnotation, []string{NormalizeFlagName(flag.Name)}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := checkDependencies(flags, inherited); err != nil {
+		return fmt.Errorf("invalid flag configuration: %w", err)
+	}
+	addRequiresCheck(cmd, flags, inherited)
+
+	return markDeprecated(cmd, flags)
+}
+
+// checkDuplicates reports flags sharing a name or a shorthand, with each other
+// or with the help flag cobra adds to every command
+func checkDuplicates(flags []*Flag) error {
+	const help = "the help flag"
+	names := map[string]string{"help": help}
+	shorthands := map[string]string{"h": help}
+
+	for _, flag := range flags {
+		name := flag.CLIName()
+		if other, ok := names[name]; ok {
+			if other == help {
+				return fmt.Errorf("flag %s uses the name --help of %s", flag.Name, help)
+			}
+			if NormalizeFlagName(other) == NormalizeFlagName(flag.Name) {
+				return fmt.Errorf("flag %s is declared twice", flag.Name)
+			}
+			return fmt.Errorf("flags %s and %s are both named --%s", other, flag.Name, name)
+		}
+		names[name] = flag.Name
+
+		shorthand := NormalizeShorthand(flag.Shorthand)
+		if shorthand == "" {
+			continue
+		}
+		if other, ok := shorthands[shorthand]; ok {
+			if other == help {
+				return fmt.Errorf("flag %s uses the shorthand -%s of %s", flag.Name, shorthand, help)
+			}
+			return fmt.Errorf("flags %s and %s both use shorthand -%s", other, flag.Name, shorthand)
+		}
+		shorthands[shorthand] = flag.Name
 	}
 	return nil
 }
 
-// ValidateFlags validates all flags for a command
+// ChainPreRunE makes cmd run hook before running, after the PreRunE cmd
+// already has, so hooks added by different callers all run
+func ChainPreRunE(cmd *cobra.Command, hook func(cmd *cobra.Command, args []string) error) {
+	preRunE := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if preRunE != nil {
+			if err := preRunE(cmd, args); err != nil {
+				return err
+			}
+		}
+		return hook(cmd, args)
+	}
+}
+
+// ValidateCommand prepares and checks the flags of cmd before it runs:
+// deprecated flags hand their value to their replacements, required flags
+// must be given, values must be valid and are then canonicalized
+func ValidateCommand(cmd *cobra.Command, flags []*Flag) error {
+	if err := ApplyReplacements(cmd); err != nil {
+		return err
+	}
+
+	if err := ValidateRequired(cmd); err != nil {
+		return err
+	}
+
+	if err := ValidateFlags(cmd, flags); err != nil {
+		return err
+	}
+
+	// Plugins receive the canonical spelling of the values
+	for _, flag := range flags {
+		if flag.IsSet(cmd) {
+			if err := Canonicalize(cmd, flag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateFlags validates all flags for a command. Every invalid flag is
+// reported, one per line, rather than only the first one.
 func ValidateFlags(cmd *cobra.Command, flags []*Flag) error {
+	var errs []error
 	for _, flag := range flags {
 		handler := GetHandler(flag.Type, flag)
-		flagName := NormalizeFlagName(flag.Name)
 
-		// Only validate if the flag was set
-		if cmd.Flags().Changed(flagName) {
-			value, err := handler.GetValue(cmd, flagName)
-			if err != nil {
-				return fmt.Errorf("failed to get value for flag %s: %w", flag.Name, err)
-			}
+		// The value is read even for unset flags so that flags missing
+		// from the command are reported
+		value, err := handler.GetValue(cmd, flag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get value for flag %s: %w", flag.Name, err))
+			continue
+		}
 
+		// Only validate if the flag was set
+		if flag.IsSet(cmd) {
 			if err := handler.ValidateValue(flag, value); err != nil {
-				return err
+				errs = append(errs, err)
 			}
 		}
 	}
-	return nil
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	lines := make([]error, len(errs))
+	for i, err := range errs {
+		lines[i] = fmt.Errorf("  - %w", err)
+	}
+	return fmt.Errorf("%d invalid flags:\n%w", len(errs), errors.Join(lines...))
 }
 
-// GetFlagValues returns a map of flag names to their values
+// GetFlagValues returns a map of flag names to their values, including the
+// values of sensitive flags
 func GetFlagValues(cmd *cobra.Command, flags []*Flag) (map[string]string, error) {
+	typedValues, err := GetTypedFlagValues(cmd, flags)
+	if err != nil {
+		return nil, err
+	}
+
 	values := make(map[string]string)
 	for _, flag := range flags {
+		if typed, ok := typedValues[NormalizeFlagName(flag.Name)]; ok {
+			values[flag.Name] = formatValue(typed)
+		}
+	}
+	return values, nil
+}
+
+// GetMaskedFlagValues returns the values of GetFlagValues with the values
+// of sensitive flags replaced by MaskedValue, for output shown to the user
+func GetMaskedFlagValues(cmd *cobra.Command, flags []*Flag) (map[string]string, error) {
+	values, err := GetFlagValues(cmd, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flag := range flags {
+		if _, ok := values[flag.Name]; ok && flag.Sensitive {
+			values[flag.Name] = MaskedValue
+		}
+	}
+	return values, nil
+}
+
+// GetTypedFlagValues returns the values of the flags keyed by their name
+// without the "--" prefix, typed according to the flag type. Deprecated flags
+// with a replacement are left out, as their value is the one of the
+// replacement.
+func GetTypedFlagValues(cmd *cobra.Command, flags []*Flag) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, flag := range flags {
+		if flag.ReplacedBy != "" {
+			continue
+		}
 		handler := GetHandler(flag.Type, flag)
-		flagName := NormalizeFlagName(flag.Name)
-		value, err := handler.GetValue(cmd, flagName)
+		value, err := handler.GetValue(cmd, flag)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get value for flag %s: %w", flag.Name, err)
 		}
-		values[flag.Name] = value
+
+		typed, err := handler.TypedValue(flag, value)
+		if err != nil {
+			return nil, err
+		}
+		values[NormalizeFlagName(flag.Name)] = typed
 	}
 	return values, nil
 }
 
-// BuildCommandSummary builds a string representation of the command with its arguments and flags
+// formatValue formats a typed flag value the way GetValue reports it
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// BuildCommandSummary builds a string representation of the command with its
+// arguments and flags, masking the values of sensitive flags
 func BuildCommandSummary(cmdName string, args []string, cmd *cobra.Command) string {
 	var parts []string
 	parts = append(parts, cmdName)
 	parts = append(parts, args...)
 
 	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		// Deprecated flags are reported under the name of their replacement
+		if replacedBy(flag) != "" {
+			return
+		}
 		if flag.Changed {
-			parts = append(parts, fmt.Sprintf("--%s=%v", flag.Name, flag.Value))
+			value := flag.Value.String()
+			if len(flag.Annotations[sensitiveAnnotation]) > 0 {
+				value = MaskedValue
+			}
+			parts = append(parts, fmt.Sprintf("--%s=%s", manifestName(flag), value))
 		}
 	})
 
@@ -85,6 +306,22 @@ func ParseFlagType(typeStr string) FlagType {
 		return TypeInt
 	case "enum":
 		return TypeEnum
+	case "float":
+		return TypeFloat
+	case "duration":
+		return TypeDuration
+	case "count":
+		return TypeCount
+	case "bytes":
+		return TypeBytes
+	case "intslice":
+		return TypeIntSlice
+	case "url":
+		return TypeURL
+	case "path":
+		return TypePath
+	case "timestamp":
+		return TypeTimestamp
 	default:
 		return TypeString // Default to string type
 	}
@@ -0,0 +1,144 @@
+package flags
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCheckDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []*Flag
+		wantErr string
+	}{
+		{
+			name:  "distinct",
+			flags: []*Flag{{Name: "--force", Shorthand: "f"}, {Name: "--file", Shorthand: "F"}},
+		},
+		{
+			name:    "same name",
+			flags:   []*Flag{{Name: "--force"}, {Name: "--force"}},
+			wantErr: "flag --force is declared twice",
+		},
+		{
+			name:    "same name once normalized",
+			flags:   []*Flag{{Name: "--dry-run"}, {Name: "--dry-run", ExposeAs: "--preview"}, {Name: "--preview"}},
+			wantErr: "flags --dry-run and --preview are both named --preview",
+		},
+		{
+			name:    "same shorthand",
+			flags:   []*Flag{{Name: "--force", Shorthand: "f"}, {Name: "--file", Shorthand: "-f"}},
+			wantErr: "flags --force and --file both use shorthand -f",
+		},
+		{
+			name:    "help name",
+			flags:   []*Flag{{Name: "--help"}},
+			wantErr: "flag --help uses the name --help of the help flag",
+		},
+		{
+			name:    "help shorthand",
+			flags:   []*Flag{{Name: "--host", Shorthand: "h"}},
+			wantErr: "flag --host uses the shorthand -h of the help flag",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkError(t, checkDuplicates(test.flags), test.wantErr)
+		})
+	}
+}
+
+// newTestCommand returns a command with the flags added and the arguments parsed
+func newTestCommand(t *testing.T, flags []*Flag, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	if err := AddFlags(slog.New(slog.DiscardHandler), cmd, flags, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestTypedFlagValuesKeepTheirJSONType(t *testing.T) {
+	flags := []*Flag{
+		{Name: "--name", Type: TypeString},
+		{Name: "--force", Type: TypeBool},
+		{Name: "--count", Type: TypeInt, Default: "1"},
+		{Name: "--ratio", Type: TypeFloat},
+		{Name: "--verbose", Type: TypeCount},
+		{Name: "--size", Type: TypeBytes},
+		{Name: "--ports", Type: TypeIntSlice},
+	}
+	cmd := newTestCommand(t, flags, "--name", "web", "--force", "--count", "3", "--ratio", "0.5",
+		"--verbose", "--verbose", "--size", "1KiB", "--ports", "80,443")
+
+	values, err := GetTypedFlagValues(cmd, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"count":3,"force":true,"name":"web","ports":[80,443],"ratio":0.5,"size":1024,"verbose":2}`
+	if string(data) != want {
+		t.Errorf("JSON = %s, want %s", data, want)
+	}
+
+	// Decoded, numbers are numbers, booleans booleans and slices arrays
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]interface{}{
+		"name":    "web",
+		"force":   true,
+		"count":   float64(3),
+		"ratio":   0.5,
+		"verbose": float64(2),
+		"size":    float64(1024),
+		"ports":   []interface{}{float64(80), float64(443)},
+	} {
+		if !reflect.DeepEqual(decoded[name], want) {
+			t.Errorf("%s = %#v, want %#v", name, decoded[name], want)
+		}
+	}
+}
+
+func TestEnvValuesAreCanonical(t *testing.T) {
+	tests := []struct {
+		flag *Flag
+		env  string
+		want string
+	}{
+		{flag: &Flag{Name: "--count", Type: TypeInt, Env: "TEST_COUNT"}, env: " +007 ", want: "7"},
+		{flag: &Flag{Name: "--ratio", Type: TypeFloat, Env: "TEST_RATIO"}, env: ".50", want: "0.5"},
+		{flag: &Flag{Name: "--wait", Type: TypeDuration, Env: "TEST_WAIT"}, env: "90s", want: "1m30s"},
+		{flag: &Flag{Name: "--verbose", Type: TypeCount, Env: "TEST_VERBOSE"}, env: "02", want: "2"},
+		{flag: &Flag{Name: "--force", Type: TypeBool, Env: "TEST_FORCE"}, env: "on", want: "true"},
+		// Invalid values are kept for ValidateValue to report
+		{flag: &Flag{Name: "--count", Type: TypeInt, Env: "TEST_COUNT"}, env: "many", want: "many"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.flag.Name+"="+test.env, func(t *testing.T) {
+			t.Setenv(test.flag.Env, test.env)
+			cmd := newTestCommand(t, []*Flag{test.flag})
+			got, err := GetHandler(test.flag.Type, test.flag).GetValue(cmd, test.flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("GetValue = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package flags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+// validValue is an entry of valid_values: either a plain string or a mapping
+// with the value, its aliases and its description
+type validValue struct {
+	Value       string
+	Aliases     []string
+	Description i18n.Text
+}
+
+func (v *validValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&v.Value)
+	}
+
+	var entry struct {
+		Value       string    `yaml:"value"`
+		Aliases     []string  `yaml:"aliases"`
+		Description i18n.Text `yaml:"description"`
+	}
+	if err := node.Decode(&entry); err != nil {
+		return err
+	}
+	if entry.Value == "" {
+		return fmt.Errorf("line %d: valid value without a value", node.Line)
+	}
+
+	v.Value = entry.Value
+	v.Aliases = entry.Aliases
+	v.Description = entry.Description
+	return nil
+}
+
+// UnmarshalYAML decodes a flag, accepting valid_values both as a list of
+// strings and as a list of values with aliases and descriptions, and the
+// default of list flags both as a comma separated string and as a list:
+//
+//	valid_values: [json, yaml]
+//	valid_values: [{value: json, aliases: [js], description: JSON output}, yaml]
+//	default: [80, 443]
+func (f *Flag) UnmarshalYAML(node *yaml.Node) error {
+	type plain Flag
+
+	// valid_values is decoded separately since its entries may be mappings,
+	// and so is a default given as a list
+	rest := *node
+	rest.Content = nil
+	var values, defaults *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch {
+		case node.Content[i].Value == "valid_values":
+			values = node.Content[i+1]
+			continue
+		case node.Content[i].Value == "default" && node.Content[i+1].Kind == yaml.SequenceNode:
+			defaults = node.Content[i+1]
+			continue
+		}
+		rest.Content = append(rest.Content, node.Content[i], node.Content[i+1])
+	}
+
+	if err := rest.Decode((*plain)(f)); err != nil {
+		return err
+	}
+
+	// A list default, such as [80, 443], is kept in its comma separated form
+	if defaults != nil {
+		var entries []string
+		if err := defaults.Decode(&entries); err != nil {
+			return err
+		}
+		f.Default = strings.Join(entries, ",")
+	}
+
+	if values == nil {
+		return nil
+	}
+
+	var entries []validValue
+	if err := values.Decode(&entries); err != nil {
+		return err
+	}
+
+	f.ValidValues = nil
+	for _, entry := range entries {
+		f.ValidValues = append(f.ValidValues, entry.Value)
+		if len(entry.Description) > 0 {
+			if f.ValueDescriptions == nil {
+				f.ValueDescriptions = make(map[string]i18n.Text)
+			}
+			f.ValueDescriptions[entry.Value] = entry.Description
+		}
+		for _, alias := range entry.Aliases {
+			if f.Aliases == nil {
+				f.Aliases = make(map[string]string)
+			}
+			if other, ok := f.Aliases[alias]; ok && other != entry.Value {
+				return fmt.Errorf("line %d: alias %s is given to both %s and %s", values.Line, alias, other, entry.Value)
+			}
+			f.Aliases[alias] = entry.Value
+		}
+	}
+
+	return nil
+}
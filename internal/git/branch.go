@@ -0,0 +1,118 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+const remoteName = "origin"
+
+// SetRef pins the branch the clone follows. By default the clone follows the
+// default branch of the remote, even when it is renamed.
+func (rm *RepoManager) SetRef(ref string) {
+	rm.ref = ref
+}
+
+// currentBranch returns the branch checked out in the local clone
+func (rm *RepoManager) currentBranch() (string, error) {
+	head, err := rm.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return defaultBranch, nil
+	}
+	return head.Name().Short(), nil
+}
+
+// targetBranch returns the branch to pull: the pinned ref, or the branch the
+// remote HEAD points to. An empty result means the remote did not advertise it.
+func (rm *RepoManager) targetBranch(source string) (string, error) {
+	if rm.ref != "" {
+		return rm.ref, nil
+	}
+
+	remote, err := rm.repo.Remote(remoteName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote: %w", err)
+	}
+	if source != rm.repoURL {
+		remote = git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: remoteName,
+			URLs: []string{source},
+		})
+	}
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			return ref.Target().Short(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// rememberDefaultBranch records the remote default branch as origin/HEAD, like git clone does
+func (rm *RepoManager) rememberDefaultBranch(branch string) error {
+	ref := plumbing.NewSymbolicReference(
+		plumbing.NewRemoteHEADReferenceName(remoteName),
+		plumbing.NewRemoteReferenceName(remoteName, branch),
+	)
+	if err := rm.repo.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("failed to record the default branch: %w", err)
+	}
+	return nil
+}
+
+// switchBranch fetches branch from the remote and checks it out, creating
+// the local tracking branch when needed
+func (rm *RepoManager) switchBranch(remoteURL, branch string) error {
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remoteName, branch))
+	err := rm.repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RemoteURL:  remoteURL,
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+	if errors.Is(err, git.NoMatchingRefSpecError{}) {
+		return fmt.Errorf("branch %s not found on the remote", branch)
+	}
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to fetch branch %s: %w", branch, err)
+	}
+
+	remoteRef, err := rm.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, branch), true)
+	if err != nil {
+		return fmt.Errorf("branch %s not found on the remote: %w", branch, err)
+	}
+
+	localRef := plumbing.NewBranchReferenceName(branch)
+	if err := rm.repo.Storer.SetReference(plumbing.NewHashReference(localRef, remoteRef.Hash())); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+
+	err = rm.repo.CreateBranch(&config.Branch{Name: branch, Remote: remoteName, Merge: localRef})
+	if err != nil && err != git.ErrBranchExists {
+		return fmt.Errorf("failed to configure branch %s: %w", branch, err)
+	}
+
+	worktree, err := rm.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: localRef, Force: true}); err != nil {
+		return fmt.Errorf("failed to check out branch %s: %w", branch, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package git
+
+import "fmt"
+
+// SetMirrors sets the mirror URLs tried, in order, when the primary
+// repository is unreachable
+func (rm *RepoManager) SetMirrors(mirrors []string) {
+	rm.mirrors = mirrors
+}
+
+// PreferSource makes Clone and Pull try the given URL first, typically the
+// source that worked during the previous run
+func (rm *RepoManager) PreferSource(source string) {
+	rm.preferred = source
+}
+
+// Source returns the URL the repository was last cloned or pulled from
+func (rm *RepoManager) Source() string {
+	if rm.source == "" {
+		return rm.repoURL
+	}
+	return rm.source
+}
+
+// sources returns the URLs to try in order: the preferred source, the primary
+// repository and then the mirrors, without duplicates
+func (rm *RepoManager) sources() []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, source := range append([]string{rm.preferred, rm.repoURL}, rm.mirrors...) {
+		if source == "" || seen[source] {
+			continue
+		}
+		seen[source] = true
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// withSources runs op against each source until one succeeds. The next source
+// is only tried when the failure is a network error. The source that worked
+// is tried first by the next operations, such as the pull after a clone.
+func (rm *RepoManager) withSources(op func(source string) error) error {
+	var errs []error
+	for _, source := range rm.sources() {
+		err := retry(rm.retry, rm.sleep, func() error {
+			return op(source)
+		})
+		if err == nil {
+			rm.source = source
+			rm.preferred = source
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		if !isTransient(err) {
+			break
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all repository sources failed: %v", errs)
+}
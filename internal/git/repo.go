@@ -2,29 +2,141 @@ package git
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 const (
-	wpstoreRepoURL = "https://github.com/ploffredi/wpstore.git"
-	defaultBranch  = "main"
+	// DefaultRepositoryURL is the repository used when none is configured
+	DefaultRepositoryURL = "https://github.com/ploffredi/wpstore.git"
+	// defaultBranch is used when the checked out HEAD is not a branch
+	defaultBranch = "main"
 )
 
 type RepoManager struct {
 	repoPath string
-	repo     *git.Repository
+	repoURL  string
+	local    bool
+	// mirrors are tried in order when the primary URL is unreachable
+	mirrors   []string
+	preferred string
+	source    string
+	// ref pins the branch to follow instead of the remote default branch
+	ref   string
+	repo  *git.Repository
+	retry RetryPolicy
+	sleep func(time.Duration)
+	// progress receives the progress of clones, which is not shown when nil
+	progress io.Writer
 }
 
-func NewRepoManager(basePath string) *RepoManager {
-	return &RepoManager{
+// NewRepoManager creates a manager for the repository at repoURL, cloned under
+// basePath. An empty URL selects the public wpstore repository. A file:// URL
+// or an absolute path is used in place, without cloning or pulling.
+func NewRepoManager(basePath, repoURL string) *RepoManager {
+	rm := &RepoManager{
 		repoPath: filepath.Join(basePath, "wpstore"),
+		repoURL:  repoURL,
+		retry:    DefaultRetryPolicy,
+		sleep:    time.Sleep,
 	}
+
+	if rm.repoURL == "" {
+		rm.repoURL = DefaultRepositoryURL
+	}
+
+	if path, ok := localPath(rm.repoURL); ok {
+		rm.repoPath = path
+		rm.local = true
+	}
+
+	return rm
+}
+
+// localPath returns the directory referenced by a file:// URL or an absolute path
+func localPath(repoURL string) (string, bool) {
+	if strings.HasPrefix(repoURL, "file://") {
+		parsed, err := url.Parse(repoURL)
+		if err != nil {
+			return filepath.FromSlash(strings.TrimPrefix(repoURL, "file://")), true
+		}
+		return filepath.FromSlash(parsed.Path), true
+	}
+
+	if filepath.IsAbs(repoURL) {
+		return repoURL, true
+	}
+
+	return "", false
+}
+
+// IsLocal reports whether the repository is a local directory used in place
+func (rm *RepoManager) IsLocal() bool {
+	return rm.local
+}
+
+// openLocal opens a local directory repository. The directory does not need
+// to be a git repository, in which case history related features are unavailable.
+func (rm *RepoManager) openLocal() error {
+	info, err := os.Stat(rm.repoPath)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("repository path does not exist: %s", rm.repoPath)
+	}
+
+	repo, err := git.PlainOpenWithOptions(rm.repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err == nil {
+		rm.repo = repo
+	}
+	return nil
+}
+
+// SetRetryPolicy changes how Clone and Pull retry transient network failures
+func (rm *RepoManager) SetRetryPolicy(policy RetryPolicy) {
+	rm.retry = policy
+}
+
+// SetProgress makes Clone write its progress to w, such as stderr
+func (rm *RepoManager) SetProgress(w io.Writer) {
+	rm.progress = w
+}
+
+// HeadInfo describes the commit currently checked out in the local clone
+type HeadInfo struct {
+	Hash       string    `json:"hash"`
+	Branch     string    `json:"branch"`
+	CommitTime time.Time `json:"commit_time"`
+}
+
+// Open opens the existing local clone without contacting the remote
+func (rm *RepoManager) Open() error {
+	if rm.local {
+		return rm.openLocal()
+	}
+
+	if _, err := os.Stat(rm.repoPath); os.IsNotExist(err) {
+		return fmt.Errorf("repository not found at %s; run wpcli once while online to clone it", rm.repoPath)
+	}
+
+	repo, err := git.PlainOpen(rm.repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open existing repository: %w", err)
+	}
+	rm.repo = repo
+	return nil
 }
 
 func (rm *RepoManager) Clone() error {
+	if rm.local {
+		return rm.openLocal()
+	}
+
 	if _, err := os.Stat(rm.repoPath); err == nil {
 		// Repository already exists, try to open it
 		repo, err := git.PlainOpen(rm.repoPath)
@@ -36,38 +148,190 @@ func (rm *RepoManager) Clone() error {
 	}
 
 	// Clone the repository
-	repo, err := git.PlainClone(rm.repoPath, false, &git.CloneOptions{
-		URL:      wpstoreRepoURL,
-		Progress: os.Stdout,
+	options := &git.CloneOptions{
+		Progress: rm.progress,
+	}
+	if rm.ref != "" {
+		options.ReferenceName = plumbing.NewBranchReferenceName(rm.ref)
+	}
+
+	var repo *git.Repository
+	err := rm.withSources(func(source string) error {
+		var err error
+		options.URL = source
+		repo, err = git.PlainClone(rm.repoPath, false, options)
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
 	rm.repo = repo
-	return nil
+	if rm.ref != "" {
+		return nil
+	}
+
+	// The clone checks out the remote default branch
+	branch, err := rm.currentBranch()
+	if err != nil {
+		return err
+	}
+	return rm.rememberDefaultBranch(branch)
 }
 
-func (rm *RepoManager) Pull() error {
+// Pull updates the local clone and reports what changed
+func (rm *RepoManager) Pull() (*UpdateResult, error) {
+	// Local repositories are used as they are
+	if rm.local {
+		return &UpdateResult{}, nil
+	}
+
 	if rm.repo == nil {
-		return fmt.Errorf("repository not initialized")
+		return nil, fmt.Errorf("repository not initialized")
 	}
 
 	worktree, err := rm.repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	before, err := rm.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	oldBranch, err := rm.currentBranch()
+	if err != nil {
+		return nil, err
 	}
 
-	err = worktree.Pull(&git.PullOptions{
-		RemoteName: "origin",
+	branch := oldBranch
+	err = rm.withSources(func(source string) error {
+		// The primary source is the URL the clone was made from
+		remoteURL := source
+		if source == rm.repoURL {
+			remoteURL = ""
+		}
+
+		// Follow the remote default branch when it is renamed
+		target, err := rm.targetBranch(source)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			target = branch
+		} else if rm.ref == "" {
+			if err := rm.rememberDefaultBranch(target); err != nil {
+				return err
+			}
+		}
+
+		if target != branch {
+			if err := rm.switchBranch(remoteURL, target); err != nil {
+				return err
+			}
+			branch = target
+		}
+
+		err = worktree.Pull(&git.PullOptions{
+			RemoteName:    remoteName,
+			RemoteURL:     remoteURL,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+		})
+		if err == git.NoErrAlreadyUpToDate {
+			return nil
+		}
+		return err
 	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to pull repository: %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull repository: %w", err)
 	}
 
-	return nil
+	after, err := rm.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	result, err := rm.updateResult(before.Hash(), after.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	result.OldBranch = oldBranch
+	result.NewBranch = branch
+	return result, nil
 }
 
 func (rm *RepoManager) GetRepoPath() string {
 	return rm.repoPath
 }
+
+// Head returns information about the commit currently checked out
+func (rm *RepoManager) Head() (*HeadInfo, error) {
+	if rm.repo == nil && rm.local {
+		return nil, fmt.Errorf("%s is not a git repository", rm.repoPath)
+	}
+	if rm.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	ref, err := rm.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	commit, err := rm.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+
+	info := &HeadInfo{
+		Hash:       ref.Hash().String()[:7],
+		CommitTime: commit.Committer.When,
+	}
+	if ref.Name().IsBranch() {
+		info.Branch = ref.Name().Short()
+	}
+
+	return info, nil
+}
+
+// HeadCommit returns the full hash of the commit HEAD points to
+func (rm *RepoManager) HeadCommit() (string, error) {
+	if rm.repo == nil {
+		return "", fmt.Errorf("%s is not a git repository", rm.repoPath)
+	}
+
+	ref, err := rm.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	return ref.Hash().String(), nil
+}
+
+// IsTracked reports whether path, a file or directory inside the repository,
+// contains files tracked by git
+func (rm *RepoManager) IsTracked(path string) (bool, error) {
+	if rm.repo == nil {
+		return false, nil
+	}
+
+	rel, err := filepath.Rel(rm.repoPath, path)
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	index, err := rm.repo.Storer.Index()
+	if err != nil {
+		return false, fmt.Errorf("failed to read repository index: %w", err)
+	}
+
+	for _, entry := range index.Entries {
+		if entry.Name == rel || strings.HasPrefix(entry.Name, rel+"/") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// RetryPolicy controls how network operations against the remote are retried
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first one
+	Attempts int
+	// InitialDelay is the delay before the first retry
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used unless the settings configure a different one
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:     3,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     4 * time.Second,
+}
+
+// NoRetry performs a single attempt
+var NoRetry = RetryPolicy{Attempts: 1}
+
+// retry runs op until it succeeds, fails with a non transient error or the
+// attempts are exhausted. Delays grow exponentially with full jitter.
+func retry(policy RetryPolicy, sleep func(time.Duration), op func() error) error {
+	delay := policy.InitialDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || attempt >= policy.Attempts || !isTransient(err) {
+			return err
+		}
+
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+		if delay > 0 {
+			sleep(time.Duration(rand.Int63n(int64(delay)) + 1))
+		}
+		delay *= 2
+	}
+}
+
+// isTransient reports whether err is a network failure worth retrying.
+// Authentication and missing repository errors are never retried.
+func isTransient(err error) bool {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrRepositoryNotFound),
+		errors.Is(err, transport.ErrEmptyRemoteRepository),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+
+	// go-git does not always wrap the underlying network error
+	message := strings.ToLower(err.Error())
+	for _, fragment := range []string{"connection reset", "connection refused", "timeout", "tls handshake", "unexpected eof", "broken pipe"} {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// dateLayouts are the accepted formats when a snapshot is requested by date
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Snapshot is a read-only view of the repository at a historical commit
+type Snapshot struct {
+	commit *object.Commit
+}
+
+// SnapshotAt resolves a commit hash, revision or date into a read-only snapshot.
+// Dates select the last commit made at or before the given time.
+func (rm *RepoManager) SnapshotAt(at string) (*Snapshot, error) {
+	if rm.repo == nil && rm.local {
+		return nil, fmt.Errorf("%s is not a git repository", rm.repoPath)
+	}
+	if rm.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	if err := rm.ensureHistory(); err != nil {
+		return nil, err
+	}
+
+	if when, ok := parseDate(at); ok {
+		return rm.snapshotAtDate(at, when)
+	}
+
+	hash, err := rm.repo.ResolveRevision(plumbing.Revision(at))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", at, err)
+	}
+
+	commit, err := rm.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", at, err)
+	}
+
+	return &Snapshot{commit: commit}, nil
+}
+
+func (rm *RepoManager) snapshotAtDate(at string, when time.Time) (*Snapshot, error) {
+	iter, err := rm.repo.Log(&git.LogOptions{Until: &when})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository history: %w", err)
+	}
+	defer iter.Close()
+
+	commit, err := iter.Next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("no commit found at or before %s", at)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository history: %w", err)
+	}
+
+	return &Snapshot{commit: commit}, nil
+}
+
+// ensureHistory replaces a shallow local clone with a full clone. go-git
+// cannot deepen a shallow clone with a fetch: the commits it already has are
+// not requested again, and the shallow boundary is kept.
+func (rm *RepoManager) ensureHistory() error {
+	if rm.local {
+		return nil
+	}
+
+	shallow, err := rm.repo.Storer.Shallow()
+	if err != nil {
+		return fmt.Errorf("failed to inspect repository: %w", err)
+	}
+	if len(shallow) == 0 {
+		return nil
+	}
+
+	branch, err := rm.currentBranch()
+	if err != nil {
+		return err
+	}
+	defaultHead, err := rm.repo.Reference(plumbing.NewRemoteHEADReferenceName(remoteName), false)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("failed to read the default branch: %w", err)
+	}
+
+	// The full clone is made beside the shallow one, which is kept until
+	// the clone succeeds
+	fullPath := rm.repoPath + ".full"
+	if err := os.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("failed to fetch repository history: %w", err)
+	}
+	var repo *git.Repository
+	err = rm.withSources(func(source string) error {
+		var err error
+		repo, err = git.PlainClone(fullPath, false, &git.CloneOptions{
+			URL:           source,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+			Progress:      rm.progress,
+		})
+		if err != nil {
+			os.RemoveAll(fullPath)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch repository history: %w", err)
+	}
+	if defaultHead != nil {
+		if err := repo.Storer.SetReference(defaultHead); err != nil {
+			return fmt.Errorf("failed to record the default branch: %w", err)
+		}
+	}
+
+	shallowPath := rm.repoPath + ".shallow"
+	if err := os.Rename(rm.repoPath, shallowPath); err != nil {
+		return fmt.Errorf("failed to replace the shallow clone: %w", err)
+	}
+	if err := os.Rename(fullPath, rm.repoPath); err != nil {
+		return fmt.Errorf("failed to replace the shallow clone: %w", err)
+	}
+	if err := os.RemoveAll(shallowPath); err != nil {
+		return fmt.Errorf("failed to remove the shallow clone: %w", err)
+	}
+
+	// The renamed clone is opened again for its new location
+	repo, err = git.PlainOpen(rm.repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open existing repository: %w", err)
+	}
+	rm.repo = repo
+	return nil
+}
+
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			// A bare date includes the whole day
+			if layout == "2006-01-02" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ReadFile returns the contents of a file in the snapshot's tree
+func (s *Snapshot) ReadFile(path string) ([]byte, error) {
+	file, err := s.commit.File(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s at %s: %w", path, s.ShortHash(), err)
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, s.ShortHash(), err)
+	}
+
+	return []byte(contents), nil
+}
+
+// Hash returns the full commit hash of the snapshot
+func (s *Snapshot) Hash() string {
+	return s.commit.Hash.String()
+}
+
+// ShortHash returns the abbreviated commit hash of the snapshot
+func (s *Snapshot) ShortHash() string {
+	return s.commit.Hash.String()[:7]
+}
+
+// Time returns the commit time of the snapshot
+func (s *Snapshot) Time() time.Time {
+	return s.commit.Committer.When
+}
+
+// Label returns a human readable description marking output as historical
+func (s *Snapshot) Label() string {
+	return fmt.Sprintf("Historical registry snapshot at %s (%s)", s.ShortHash(), s.Time().Format("2006-01-02 15:04"))
+}
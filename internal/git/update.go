@@ -0,0 +1,94 @@
+package git
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// UpdateResult describes what a pull changed in the local clone
+type UpdateResult struct {
+	// OldHash and NewHash are the commits checked out before and after the pull
+	OldHash string
+	NewHash string
+	// ChangedFiles lists the paths added, modified or deleted by the pull
+	ChangedFiles []string
+	// OldBranch and NewBranch are the branches checked out before and after the pull
+	OldBranch string
+	NewBranch string
+}
+
+// Updated reports whether the pull moved to a different commit
+func (r *UpdateResult) Updated() bool {
+	return r.OldHash != r.NewHash
+}
+
+// BranchChanged reports whether the pull switched to another branch, which
+// happens when the default branch of the remote is renamed
+func (r *UpdateResult) BranchChanged() bool {
+	return r.OldBranch != r.NewBranch
+}
+
+// Changed reports whether the pull changed the given file
+func (r *UpdateResult) Changed(path string) bool {
+	for _, file := range r.ChangedFiles {
+		if file == path {
+			return true
+		}
+	}
+	return false
+}
+
+// PluginsChanged reports whether the pull changed plugins.yml
+func (r *UpdateResult) PluginsChanged() bool {
+	return r.Changed("plugins.yml")
+}
+
+func (rm *RepoManager) updateResult(before, after plumbing.Hash) (*UpdateResult, error) {
+	result := &UpdateResult{
+		OldHash: before.String(),
+		NewHash: after.String(),
+	}
+	if before == after {
+		return result, nil
+	}
+
+	oldTree, err := rm.commitTree(before)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := rm.commitTree(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare commits: %w", err)
+	}
+
+	for _, change := range changes {
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		result.ChangedFiles = append(result.ChangedFiles, name)
+	}
+
+	return result, nil
+}
+
+func (rm *RepoManager) commitTree(hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := rm.repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", hash, err)
+	}
+
+	return tree, nil
+}
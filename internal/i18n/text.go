@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage is used when no language has been selected
+const DefaultLanguage = "en"
+
+// defaultKey holds text that applies to any language
+const defaultKey = "default"
+
+// fallbackLanguage is tried after the requested language, see SetFallbackLanguage
+var fallbackLanguage string
+
+// SetFallbackLanguage sets the language texts fall back to before English
+// when they have no translation in the requested one, such as the
+// default_language setting
+func SetFallbackLanguage(lang string) {
+	fallbackLanguage = lang
+}
+
+// Text is a piece of text translated in several languages, keyed by language
+// code. In YAML it can be written either as a plain string, which is treated
+// as the English text, or as a map of language codes to text.
+type Text map[string]string
+
+// UnmarshalYAML accepts both the plain string and the language map forms
+func (t *Text) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var text string
+		if err := node.Decode(&text); err != nil {
+			return err
+		}
+		*t = Text{DefaultLanguage: text}
+	case yaml.MappingNode:
+		translations := make(map[string]string)
+		if err := node.Decode(&translations); err != nil {
+			return err
+		}
+		*t = translations
+	default:
+		return fmt.Errorf("line %d: expected a string or a map of languages", node.Line)
+	}
+	return nil
+}
+
+// MarshalYAML writes English-only text back in the plain string form
+func (t Text) MarshalYAML() (interface{}, error) {
+	if len(t) == 1 {
+		if text, ok := t[DefaultLanguage]; ok {
+			return text, nil
+		}
+	}
+	return map[string]string(t), nil
+}
+
+// Get returns the text in the given language, falling back to the fallback
+// language, then to English, then to the "default" entry and finally to any
+// available translation
+func (t Text) Get(lang string) string {
+	for _, key := range []string{lang, fallbackLanguage, DefaultLanguage, defaultKey} {
+		if text, ok := t[key]; ok && text != "" {
+			return text
+		}
+	}
+
+	for _, key := range t.Languages() {
+		if t[key] != "" {
+			return t[key]
+		}
+	}
+
+	return ""
+}
+
+// Has reports whether a translation exists for the given language
+func (t Text) Has(lang string) bool {
+	return t[lang] != ""
+}
+
+// Languages returns the available languages in sorted order
+func (t Text) Languages() []string {
+	languages := make([]string, 0, len(t))
+	for lang := range t {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// String returns the English text
+func (t Text) String() string {
+	return t.Get(DefaultLanguage)
+}
+
+// languageNames are the display names of common language codes
+var languageNames = map[string]string{
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"it": "Italian",
+	"ja": "Japanese",
+	"pt": "Portuguese",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the English name of a language code, or the code
+// itself when the language is not known
+func LanguageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	if lang == defaultKey {
+		return "Default"
+	}
+	return lang
+}
@@ -0,0 +1,260 @@
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	appName       = "wpcli"
+	legacyDirName = ".wpcli"
+	repoDirName   = "wpstore"
+	// compiledDirName holds the compiled plugin modules
+	compiledDirName = "compiled"
+	// pluginsDirName holds the installed plugin modules
+	pluginsDirName = "plugins"
+	// commandCachePrefix starts the names of the parsed plugin command
+	// caches, one per repository commit
+	commandCachePrefix = "commands."
+)
+
+// Paths holds the directories wpcli uses to store its state
+type Paths struct {
+	// CacheDir holds data that can be regenerated, such as the repository clone
+	CacheDir string
+	// ConfigDir holds user configuration and local state
+	ConfigDir string
+	// Legacy is true when the pre-XDG ~/.wpcli directory is in use
+	Legacy bool
+}
+
+// Resolve returns the directories wpcli should use. An existing ~/.wpcli
+// directory keeps being used for backward compatibility, otherwise the
+// platform cache and config directories are used ($XDG_CACHE_HOME and
+// $XDG_CONFIG_HOME on Linux, ~/Library on macOS, %LocalAppData% and
+// %AppData% on Windows).
+func Resolve() (*Paths, error) {
+	legacyDir, err := LegacyDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(legacyDir); err == nil && info.IsDir() {
+		return &Paths{
+			CacheDir:  legacyDir,
+			ConfigDir: legacyDir,
+			Legacy:    true,
+		}, nil
+	}
+
+	return Platform()
+}
+
+// Platform returns the platform specific directories, ignoring ~/.wpcli
+func Platform() (*Paths, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config directory: %w", err)
+	}
+
+	return &Paths{
+		CacheDir:  filepath.Join(cacheDir, appName),
+		ConfigDir: filepath.Join(configDir, appName),
+	}, nil
+}
+
+// LegacyDir returns the location of the pre-XDG ~/.wpcli directory
+func LegacyDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, legacyDirName), nil
+}
+
+// Ensure creates the cache and config directories if they do not exist
+func (p *Paths) Ensure() error {
+	if err := os.MkdirAll(p.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.MkdirAll(p.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return nil
+}
+
+// CompiledDir returns the directory of the compiled plugin module cache
+func (p *Paths) CompiledDir() string {
+	return filepath.Join(p.CacheDir, compiledDirName)
+}
+
+// CompiledShardDir returns the directory of the compiled module cache of a
+// runtime version. Modules compiled by other runtime versions are kept apart,
+// so upgrades never load them and cache prune can remove them.
+func (p *Paths) CompiledShardDir(runtimeVersion string) string {
+	return filepath.Join(p.CompiledDir(), runtimeVersion)
+}
+
+// StaleCompiledShards returns the entries of the compiled module cache that
+// do not belong to the shard of the given runtime version: the shards of
+// other versions, and the modules wpcli kept before the cache was sharded
+func (p *Paths) StaleCompiledShards(runtimeVersion string) ([]string, error) {
+	entries, err := os.ReadDir(p.CompiledDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the compiled module cache: %w", err)
+	}
+
+	var stale []string
+	for _, entry := range entries {
+		if entry.Name() != runtimeVersion {
+			stale = append(stale, filepath.Join(p.CompiledDir(), entry.Name()))
+		}
+	}
+	return stale, nil
+}
+
+// PluginsDir returns the directory plugin modules are installed to. It is
+// kept with the state recording them, in the config directory.
+func (p *Paths) PluginsDir() string {
+	return filepath.Join(p.ConfigDir, pluginsDirName)
+}
+
+// CommandCacheFile returns the file caching the plugin commands parsed from
+// the given repository commit
+func (p *Paths) CommandCacheFile(commit string) string {
+	return filepath.Join(p.CacheDir, commandCachePrefix+commit+".json")
+}
+
+// CommandCacheFiles returns the parsed plugin command caches of every commit
+func (p *Paths) CommandCacheFiles() ([]string, error) {
+	return filepath.Glob(filepath.Join(p.CacheDir, commandCachePrefix+"*.json"))
+}
+
+// Migrate moves the contents of ~/.wpcli to the platform directories.
+// The repository clone, the compiled modules and the command caches go to
+// the cache directory, everything else to the config directory. It returns a description of each move performed.
+func Migrate() ([]string, error) {
+	legacyDir, err := LegacyDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(legacyDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", legacyDir, err)
+	}
+
+	target, err := Platform()
+	if err != nil {
+		return nil, err
+	}
+	if err := target.Ensure(); err != nil {
+		return nil, err
+	}
+
+	var moved []string
+	for _, entry := range entries {
+		destDir := target.ConfigDir
+		if isCacheEntry(entry.Name()) {
+			destDir = target.CacheDir
+		}
+
+		src := filepath.Join(legacyDir, entry.Name())
+		dst := filepath.Join(destDir, entry.Name())
+		if _, err := os.Stat(dst); err == nil {
+			return moved, fmt.Errorf("cannot move %s: %s already exists", src, dst)
+		}
+
+		if err := move(src, dst); err != nil {
+			return moved, fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+		}
+		moved = append(moved, fmt.Sprintf("%s -> %s", src, dst))
+	}
+
+	if err := os.Remove(legacyDir); err != nil {
+		return moved, fmt.Errorf("failed to remove %s: %w", legacyDir, err)
+	}
+
+	return moved, nil
+}
+
+// isCacheEntry reports whether an entry of ~/.wpcli belongs in the cache
+// directory, where the commands using it look for it
+func isCacheEntry(name string) bool {
+	return name == repoDirName || name == compiledDirName || strings.HasPrefix(name, commandCachePrefix)
+}
+
+// move renames src to dst, copying across filesystems when needed
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,237 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// commandCacheSchema is the version of the command cache format. Caches
+// written with another schema are parsed again.
+const commandCacheSchema = 4
+
+// CommandCache keeps plugins.yml and the plugin configurations of a
+// repository commit parsed, so that runs at the same commit skip parsing
+// their YAML. A nil *CommandCache, or one that could not be filled because
+// a file does not parse, reads the files directly.
+type CommandCache struct {
+	repoDir string
+	data    *commandCacheData
+}
+
+// commandCacheData is the content of a command cache file
+type commandCacheData struct {
+	Schema  int             `json:"schema"`
+	Commit  string          `json:"commit"`
+	Catalog json.RawMessage `json:"catalog"`
+	// Configs holds the plugin configurations by path relative to the
+	// repository
+	Configs map[string]json.RawMessage `json:"configs"`
+	// Files records the size and modification time of the files parsed,
+	// which tells when a local repository was edited without a commit
+	Files map[string]fileStamp `json:"files"`
+}
+
+type fileStamp struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// OpenCommandCache loads the command cache of the repository in repoDir at
+// the given commit from path. A missing, stale or corrupted cache is
+// replaced by parsing plugins.yml and the configuration of every plugin
+// version, unless one of them does not parse.
+func OpenCommandCache(logger *slog.Logger, path, repoDir, commit string) *CommandCache {
+	cache := &CommandCache{repoDir: repoDir}
+
+	data, err := readCommandCache(path)
+	if err == nil {
+		err = cache.check(data, commit)
+	}
+	if err == nil {
+		cache.data = data
+		return cache
+	}
+	if !os.IsNotExist(err) {
+		logger.Debug("discarding the plugin command cache", "path", path, "error", err)
+	}
+
+	data, err = cache.build(logger, commit)
+	if err != nil {
+		// The error is reported when the commands are built
+		logger.Debug("not caching the plugin commands", "error", err)
+		os.Remove(path)
+		return cache
+	}
+	if err := writeCommandCache(path, data); err != nil {
+		logger.Warn("failed to write the plugin command cache", "path", path, "error", err)
+	}
+	cache.data = data
+	return cache
+}
+
+// readCommandCache reads a command cache file
+func readCommandCache(path string) (*commandCacheData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &commandCacheData{}
+	if err := json.Unmarshal(content, data); err != nil {
+		return nil, fmt.Errorf("failed to parse the command cache: %w", err)
+	}
+	return data, nil
+}
+
+// writeCommandCache writes a command cache file, replacing it atomically
+func writeCommandCache(path string, data *commandCacheData) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// check tells whether cached data still matches the repository
+func (c *CommandCache) check(data *commandCacheData, commit string) error {
+	if data.Schema != commandCacheSchema {
+		return fmt.Errorf("cache schema %d, expected %d", data.Schema, commandCacheSchema)
+	}
+	if data.Commit != commit {
+		return fmt.Errorf("cache of commit %s, expected %s", data.Commit, commit)
+	}
+	if data.Catalog == nil || data.Files == nil {
+		return fmt.Errorf("incomplete cache")
+	}
+
+	for rel, cached := range data.Files {
+		stamp, err := statFile(filepath.Join(c.repoDir, rel))
+		if err != nil {
+			return err
+		}
+		if stamp.Size != cached.Size || !stamp.ModTime.Equal(cached.ModTime) {
+			return fmt.Errorf("%s changed", rel)
+		}
+	}
+	return nil
+}
+
+// build parses plugins.yml and the configuration of every plugin version
+func (c *CommandCache) build(logger *slog.Logger, commit string) (*commandCacheData, error) {
+	data := &commandCacheData{
+		Schema:  commandCacheSchema,
+		Commit:  commit,
+		Configs: make(map[string]json.RawMessage),
+		Files:   make(map[string]fileStamp),
+	}
+
+	// Files are stamped before they are read, so that an edit made in
+	// between invalidates the cache
+	configPath := filepath.Join(c.repoDir, "plugins.yml")
+	stamp, err := statFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	data.Files["plugins.yml"] = stamp
+	catalog, err := readCatalog(logger, configPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if data.Catalog, err = json.Marshal(catalog); err != nil {
+		return nil, err
+	}
+
+	for _, plugin := range catalog.Plugins {
+		for _, version := range plugin.Versions {
+			// A missing configuration is reported when the version is
+			// selected, which reads it directly
+			rel := filepath.Join(plugin.UUID, version.Version, version.Conf)
+			stamp, err := statFile(filepath.Join(c.repoDir, rel))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			data.Files[rel] = stamp
+
+			pluginConfig, err := loadPluginConfig(logger, filepath.Join(c.repoDir, rel), false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel, err)
+			}
+			if data.Configs[rel], err = json.Marshal(pluginConfig); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return data, nil
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// readCatalog returns the parsed plugins.yml at configPath, from the cache
+// when it holds it. The cache is parsed leniently, so strict parsing reads
+// the file.
+func (c *CommandCache) readCatalog(logger *slog.Logger, configPath string, strict bool) (*PluginConfig, error) {
+	if c == nil || c.data == nil || strict || configPath != filepath.Join(c.repoDir, "plugins.yml") {
+		return readCatalog(logger, configPath, strict)
+	}
+
+	config := &PluginConfig{}
+	if err := json.Unmarshal(c.data.Catalog, config); err != nil {
+		logger.Debug("failed to decode the cached plugins.yml", "error", err)
+		return readCatalog(logger, configPath, strict)
+	}
+	return config, nil
+}
+
+// loadPluginConfig returns the parsed plugin configuration at configPath,
+// from the cache when it holds it, like readCatalog
+func (c *CommandCache) loadPluginConfig(logger *slog.Logger, configPath string, strict bool) (*Plugin, error) {
+	if c == nil || c.data == nil || strict {
+		return loadPluginConfig(logger, configPath, strict)
+	}
+	rel, err := filepath.Rel(c.repoDir, configPath)
+	if err != nil {
+		return loadPluginConfig(logger, configPath, strict)
+	}
+	cached, ok := c.data.Configs[rel]
+	if !ok {
+		return loadPluginConfig(logger, configPath, strict)
+	}
+
+	config := &Plugin{}
+	if err := json.Unmarshal(cached, config); err != nil {
+		logger.Debug("failed to decode a cached plugin config", "path", configPath, "error", err)
+		return loadPluginConfig(logger, configPath, strict)
+	}
+	return config, nil
+}
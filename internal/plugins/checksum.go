@@ -0,0 +1,96 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Statuses of plugin modules reported by VerifyModule
+const (
+	ModuleOK         = "ok"
+	ModuleMismatch   = "mismatch"
+	ModuleMissing    = "missing"
+	ModuleUnverified = "unverified"
+)
+
+// ChecksumError reports a module whose SHA-256 digest differs from the one
+// of its version in the catalog
+type ChecksumError struct {
+	Plugin   string
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("plugin %s: checksum mismatch for module %s: expected sha256 %s, got %s", e.Plugin, e.Path, e.Expected, e.Actual)
+}
+
+// ModulePath returns the location of the module of a plugin version in the
+// repository
+func ModulePath(repoPath string, plugin Plugin, version Version) string {
+	return filepath.Join(repoPath, plugin.UUID, version.Version, version.Wasm)
+}
+
+// FileSHA256 returns the hex encoded SHA-256 digest of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyModule checks the module at path against the checksum of its plugin
+// version, returning its status. A mismatch is also returned as a
+// *ChecksumError.
+func VerifyModule(plugin Plugin, version Version, path string) (string, error) {
+	actual, err := FileSHA256(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ModuleMissing, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("plugin %s: failed to verify module %s: %w", plugin.Name, path, err)
+	}
+
+	expected := strings.ToLower(strings.TrimSpace(version.SHA256))
+	switch {
+	case expected == "":
+		return ModuleUnverified, nil
+	case expected != actual:
+		return ModuleMismatch, &ChecksumError{Plugin: plugin.Name, Path: path, Expected: expected, Actual: actual}
+	}
+	return ModuleOK, nil
+}
+
+// checkModule refuses to run a module that does not match its checksum, and
+// one without a checksum when checksums are required. A missing module is
+// left to the runtime to report.
+func checkModule(logger *slog.Logger, plugin Plugin, version Version, requiredBy string) error {
+	status, err := VerifyModule(plugin, version, version.Wasm)
+	if err != nil {
+		return err
+	}
+
+	if status == ModuleUnverified {
+		if requiredBy != "" {
+			return fmt.Errorf("plugin %s: module %s has no sha256 checksum, which %s demands", plugin.Name, version.Wasm, requiredBy)
+		}
+		logger.Warn("plugin module has no sha256 checksum, running it unverified", "plugin", plugin.Name, "version", version.Version)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+// groupMember records what a subcommand group child was built from so the
+// command can be built again under a different parent
+type groupMember struct {
+	plugin  Plugin
+	version Version
+	config  PluginCommandConfig
+	flags   []*flags.Flag
+}
+
+// collapseSingleCommandGroups registers a root level alias for every subcommand
+// group that ends up with exactly one command. The bare command name is used
+// when it is free, otherwise "<group>-<command>". When both names are taken the
+// group keeps its group-only form.
+func collapseSingleCommandGroups(logger *slog.Logger, rootCommands []*cobra.Command, groupMembers map[string][]groupMember, reserved []string, language string, settings *Settings) ([]*cobra.Command, error) {
+	taken := make(map[string]bool)
+	for _, name := range reserved {
+		taken[name] = true
+	}
+	for _, cmd := range rootCommands {
+		taken[cmd.Name()] = true
+		for _, alias := range cmd.Aliases {
+			taken[alias] = true
+		}
+	}
+
+	var aliases []*cobra.Command
+	for _, group := range rootCommands {
+		members := groupMembers[group.Name()]
+		if len(members) != 1 || len(group.Commands()) != 1 {
+			continue
+		}
+
+		child := group.Commands()[0]
+		aliasName := child.Name()
+		if taken[aliasName] {
+			aliasName = group.Name() + "-" + child.Name()
+		}
+		if taken[aliasName] {
+			continue
+		}
+
+		member := members[0]
+		alias, err := newPluginCommand(logger, member.plugin, member.version, member.config, member.flags, false, language, settings)
+		if err != nil {
+			return nil, err
+		}
+
+		alias.Use = aliasName + strings.TrimPrefix(alias.Use, child.Name())
+		// The aliases of the command are free at the root level only
+		alias.Aliases = slices.DeleteFunc(alias.Aliases, func(name string) bool { return taken[name] || name == aliasName })
+		for _, name := range alias.Aliases {
+			taken[name] = true
+		}
+		alias.Long += fmt.Sprintf("\n\nAlias for: wpcli %s %s", group.Name(), child.Name())
+		child.Long += fmt.Sprintf("\n\nAlso available as: wpcli %s", aliasName)
+		group.Long += fmt.Sprintf("\n\nThe only command of this group is also available as: wpcli %s", aliasName)
+
+		taken[aliasName] = true
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,171 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// Collision is a root level name a plugin cannot take, because wpcli or a
+// plugin earlier in the catalog uses it. The commands of the plugins involved
+// are also available under the UUID of their plugin.
+type Collision struct {
+	// Name is the command name, or the subcommand group name when Group is
+	// set, or an alias of Command
+	Name    string
+	Group   bool
+	Command string
+	// Plugin and UUID name the plugin left without the name
+	Plugin string
+	UUID   string
+	// Owner and OwnerUUID name the plugin keeping the name, and are empty
+	// when it is a builtin command
+	Owner     string
+	OwnerUUID string
+}
+
+func (c Collision) String() string {
+	// The command of an alias keeps its name
+	if c.Command != "" {
+		if c.OwnerUUID == "" {
+			return fmt.Sprintf("plugin %s: alias %s of command %s is reserved by wpcli; run the command as 'wpcli %s'", c.Plugin, c.Name, c.Command, c.Command)
+		}
+		return fmt.Sprintf("plugins %s and %s both use the name %s; %s keeps it, run the command of %s as 'wpcli %s'", c.Owner, c.Plugin, c.Name, c.Owner, c.Plugin, c.Command)
+	}
+
+	target := fmt.Sprintf("run it as 'wpcli %s %s'", c.UUID, c.Name)
+	kind := "command"
+	if c.Group {
+		target = fmt.Sprintf("run its commands as 'wpcli %s <command>'", c.UUID)
+		kind = "group"
+	}
+
+	if c.OwnerUUID == "" {
+		return fmt.Sprintf("plugin %s: %s %s is reserved by wpcli; %s", c.Plugin, kind, c.Name, target)
+	}
+	if c.Group {
+		target = fmt.Sprintf("run the commands of %s as 'wpcli %s <command>'", c.Plugin, c.UUID)
+	} else {
+		target = fmt.Sprintf("run the one of %s as 'wpcli %s %s'", c.Plugin, c.UUID, c.Name)
+	}
+	return fmt.Sprintf("plugins %s and %s both use the name %s; %s keeps it, %s", c.Owner, c.Plugin, c.Name, c.Owner, target)
+}
+
+// rootCommandConfigs returns the root level commands of the plugins without a
+// subcommand group by UUID: those the catalog lists, or else those of the
+// configuration of their selected version. Plugins whose configuration does
+// not load are left out, which is reported when their commands are built.
+func rootCommandConfigs(logger *slog.Logger, config *PluginConfig, configPath string, cache *CommandCache, settings *Settings) map[string][]PluginCommandConfig {
+	commands := make(map[string][]PluginCommandConfig)
+	for _, plugin := range config.Plugins {
+		if plugin.Subcommand != "" {
+			continue
+		}
+		if len(plugin.Commands) > 0 {
+			commands[plugin.UUID] = plugin.Commands
+			continue
+		}
+
+		version, err := settings.SelectVersion(plugin)
+		if err != nil {
+			version = plugin.Versions[0]
+		}
+		pluginConfig, err := cache.loadPluginConfig(logger, filepath.Join(filepath.Dir(configPath), plugin.UUID, version.Version, version.Conf), settings.StrictConfig)
+		if err != nil {
+			logger.Debug("failed to read the commands of a plugin", "plugin", plugin.Name, "error", err)
+			continue
+		}
+		commands[plugin.UUID] = pluginConfig.Commands
+	}
+	return commands
+}
+
+// findCollisions returns the root level names of the catalog that plugins
+// cannot take. Builtin commands keep their names, and so does the first
+// plugin of the catalog using a name, as a command name or an alias; the
+// plugins of a subcommand group share its name.
+func findCollisions(config *PluginConfig, commands map[string][]PluginCommandConfig, reserved Reserved) []Collision {
+	type owner struct {
+		plugin, uuid string
+		// group is the subcommand group holding the name, if any
+		group string
+	}
+	owners := make(map[string]owner)
+	for _, name := range reserved.Commands {
+		owners[name] = owner{}
+	}
+
+	var collisions []Collision
+	for _, plugin := range config.Plugins {
+		// Nested groups take the name of their root level group
+		if path := plugin.GroupPath(); len(path) > 0 {
+			existing, taken := owners[path[0]]
+			switch {
+			case !taken:
+				owners[path[0]] = owner{plugin.Name, plugin.UUID, path[0]}
+			case existing.group != path[0]:
+				collisions = append(collisions, Collision{Name: path[0], Group: true, Plugin: plugin.Name, UUID: plugin.UUID, Owner: existing.plugin, OwnerUUID: existing.uuid})
+			}
+			continue
+		}
+
+		for _, command := range commands[plugin.UUID] {
+			existing, taken := owners[command.Name]
+			switch {
+			case !taken:
+				owners[command.Name] = owner{plugin.Name, plugin.UUID, ""}
+			case existing.uuid != plugin.UUID || existing.group != "":
+				collisions = append(collisions, Collision{Name: command.Name, Plugin: plugin.Name, UUID: plugin.UUID, Owner: existing.plugin, OwnerUUID: existing.uuid})
+				continue
+			}
+
+			for _, alias := range command.Aliases {
+				existing, taken := owners[alias]
+				switch {
+				case !taken:
+					owners[alias] = owner{plugin.Name, plugin.UUID, ""}
+				case existing.uuid != plugin.UUID || existing.group != "":
+					collisions = append(collisions, Collision{Name: alias, Command: command.Name, Plugin: plugin.Name, UUID: plugin.UUID, Owner: existing.plugin, OwnerUUID: existing.uuid})
+				}
+			}
+		}
+	}
+	return collisions
+}
+
+// collidingPlugins returns the UUIDs of the plugins involved in collisions,
+// which are given a command named after their UUID. Commands losing an alias
+// keep their name, so do not need one.
+func collidingPlugins(collisions []Collision) map[string]bool {
+	uuids := make(map[string]bool)
+	for _, collision := range collisions {
+		if collision.Command != "" {
+			continue
+		}
+		uuids[collision.UUID] = true
+		if collision.OwnerUUID != "" {
+			uuids[collision.OwnerUUID] = true
+		}
+	}
+	return uuids
+}
+
+func uuidGroupShort(plugin Plugin, version Version) string {
+	return fmt.Sprintf("Commands of the plugin, reachable under its UUID (%s v%s)", plugin.Name, version.Version)
+}
+
+// newUUIDGroup builds the hidden command holding the commands of a plugin
+// under its UUID, which keeps them reachable when their names collide
+func newUUIDGroup(plugin Plugin, version Version) *cobra.Command {
+	return &cobra.Command{
+		Use:    plugin.UUID,
+		Short:  uuidGroupShort(plugin, version),
+		Long:   fmt.Sprintf("Commands of %s, available under its UUID because their names collide with other commands\n\nVersion: %s\n\nPlugin: %s", plugin.Name, version.Version, plugin.Name),
+		Hidden: true,
+		Annotations: map[string]string{
+			CompletionAnnotation: fmt.Sprintf("Commands of %s", plugin.Name),
+		},
+	}
+}
@@ -2,202 +2,564 @@ package plugins
 
 import (
 	"fmt"
-	"os"
+	"log/slog"
 	"path/filepath"
-	"sort"
+	"slices"
 	"strings"
 
 	"github.com/ploffredi/wpcli/internal/flags"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-// GetPluginCommands returns a list of commands available from the plugins
-func GetPluginCommands(configPath string) ([]*cobra.Command, error) {
-	config := &PluginConfig{}
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read plugins.yml: %w", err)
-	}
+// Annotations identifying the plugin a command was built from
+const (
+	PluginUUIDAnnotation    = "wpcli/plugin-uuid"
+	PluginNameAnnotation    = "wpcli/plugin-name"
+	PluginVersionAnnotation = "wpcli/plugin-version"
+	// PluginLatestVersionAnnotation holds the latest version of the plugin,
+	// which differs from PluginVersionAnnotation when another one is selected
+	PluginLatestVersionAnnotation = "wpcli/plugin-latest-version"
+	PluginGroupAnnotation         = "wpcli/plugin-group"
+	// PluginConfigAnnotation and PluginCommandAnnotation locate the
+	// configuration of a command, see CommandConfig
+	PluginConfigAnnotation  = "wpcli/plugin-config"
+	PluginCommandAnnotation = "wpcli/plugin-command"
+	// CompletionAnnotation holds the description shown by shell completion,
+	// which names the plugin and group the command comes from
+	CompletionAnnotation = "wpcli/completion"
+)
+
+// Reserved lists the names wpcli already uses, which plugin commands cannot take
+type Reserved struct {
+	// Commands are the root level command names in use, such as builtins
+	Commands []string
+	// Flags and Shorthands are the flags wpcli defines on every command
+	Flags      []string
+	Shorthands []string
+}
+
+// LoadError reports a plugin, or a command of a plugin, left out of the
+// commands because its configuration is invalid
+type LoadError struct {
+	Plugin  string
+	UUID    string
+	Version string
+	// Command is empty when the whole plugin is left out
+	Command string
+	Err     error
+}
+
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
 
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse plugins.yml: %w", err)
+// GetPluginCommands returns a list of commands available from the plugins,
+// with flag help and errors in the given language when plugins provide it.
+// Commands are built from the latest version of each plugin unless the
+// plugin_versions of the effective settings select another one, see
+// SelectVersion. Only the plugins with the given UUIDs are read, or all of
+// them when none is given. Plugins and commands with an invalid
+// configuration or selected version are skipped and reported as LoadErrors
+// alongside the commands that could be built; only a catalog that cannot be
+// read fails. Root level names are given to builtin commands and then to
+// plugins in catalog order, see findCollisions; the commands of the plugins
+// involved in a collision are also built under their UUID. The files are
+// read from cache when it holds them.
+func GetPluginCommands(logger *slog.Logger, configPath string, cache *CommandCache, reserved Reserved, language string, settings *Settings, uuids ...string) ([]*cobra.Command, []LoadError, error) {
+	config, err := cache.readCatalog(logger, configPath, settings.StrictConfig)
+	if err != nil {
+		return nil, nil, err
 	}
+	dropUninstalled(config, settings)
 
 	// Group plugins by subcommand
-	subcommandGroups := make(map[string]*cobra.Command)
-	subcommandVersions := make(map[string]string)
-	subcommandPlugins := make(map[string]string)
+	groups := newGroupTree()
+	groupMembers := make(map[string][]groupMember)
 	var rootCommands []*cobra.Command
+	var skipped []LoadError
+
+	collisions := findCollisions(config, rootCommandConfigs(logger, config, configPath, cache, settings), reserved)
+	colliding := collidingPlugins(collisions)
+	lost := make(map[string]bool)
+	for _, collision := range collisions {
+		lost[collision.UUID+"/"+collision.Name] = true
+	}
 
 	for _, plugin := range config.Plugins {
-		// Sort versions in descending order to get the latest version first
-		versions := make([]Version, len(plugin.Versions))
-		copy(versions, plugin.Versions)
-		sort.Slice(versions, func(i, j int) bool {
-			return versions[i].Version > versions[j].Version
-		})
+		if len(uuids) > 0 && !slices.Contains(uuids, plugin.UUID) {
+			continue
+		}
 
-		// Use only the latest version
-		latestVersion := versions[0]
+		// Commands come from a single version, the latest one by default
+		latestVersion, err := settings.SelectVersion(plugin)
+		if err != nil {
+			skipped = append(skipped, LoadError{Plugin: plugin.Name, UUID: plugin.UUID, Err: err})
+			continue
+		}
+		// skip records why the plugin, or one of its commands, is left out
+		skip := func(command string, err error) {
+			skipped = append(skipped, LoadError{Plugin: plugin.Name, UUID: plugin.UUID, Version: latestVersion.Version, Command: command, Err: err})
+		}
 
 		// Read plugin-specific YAML configuration
-		pluginConfigPath := filepath.Join(filepath.Dir(configPath), plugin.UUID, latestVersion.Version, latestVersion.Conf)
-		pluginConfig, err := loadPluginConfig(pluginConfigPath)
+		pluginConfig, err := loadPluginVersion(logger, configPath, cache, &plugin, &latestVersion, reserved, settings)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load plugin config for %s: %w", plugin.Name, err)
+			skip("", err)
+			continue
 		}
+		pluginFlags := pluginConfig.Flags
 
-		// Get or create the parent command for plugins with subcommands
+		// Get or create the parent command for plugins with subcommands,
+		// and the groups it is nested in
 		var parentCmd *cobra.Command
 		if plugin.Subcommand != "" {
-			if cmd, exists := subcommandGroups[plugin.Subcommand]; exists {
-				parentCmd = cmd
-			} else {
-				parentCmd = &cobra.Command{
-					Use:   plugin.Subcommand,
-					Short: fmt.Sprintf("Commands for %s plugins (%s v%s)", plugin.Subcommand, plugin.Name, latestVersion.Version),
-					Long:  fmt.Sprintf("Commands for %s plugins\n\nVersion: %s\n\nPlugin: %s", plugin.Subcommand, latestVersion.Version, plugin.Name),
-				}
-				subcommandGroups[plugin.Subcommand] = parentCmd
-				subcommandVersions[plugin.Subcommand] = latestVersion.Version
-				subcommandPlugins[plugin.Subcommand] = plugin.Name
-				rootCommands = append(rootCommands, parentCmd)
+			group, root, err := groups.add(plugin, latestVersion)
+			if err != nil {
+				skip("", err)
+				continue
+			}
+			parentCmd = group
+			if root != nil && !lost[plugin.UUID+"/"+root.Name()] {
+				rootCommands = append(rootCommands, root)
+			}
+		}
+
+		var uuidGroup *cobra.Command
+		if colliding[plugin.UUID] {
+			uuidGroup = newUUIDGroup(plugin, latestVersion)
+		}
+
+		if parentCmd != nil && len(pluginFlags) > 0 {
+			if err := addGroupFlags(logger, parentCmd, plugin, pluginFlags, language); err != nil {
+				skip("", err)
+				continue
 			}
 		}
 
 		// Create commands for each plugin command
 		for _, cmdConfig := range pluginConfig.Commands {
-			// Create a copy of cmdConfig for the closure
-			cmdConfigCopy := cmdConfig
-
-			// Count required arguments
-			requiredArgs := 0
-			for _, arg := range cmdConfigCopy.Args {
-				if arg.Required {
-					requiredArgs++
-				}
+			if err := checkReservedFlags(plugin, "command "+cmdConfig.Name, cmdConfig.Flags, reserved); err != nil {
+				skip(cmdConfig.Name, err)
+				continue
+			}
+			if err := checkPluginFlagConflicts(pluginFlags, cmdConfig); err != nil {
+				skip(cmdConfig.Name, fmt.Errorf("plugin %s: %w", plugin.Name, err))
+				continue
 			}
 
-			// Extract command name from usage pattern
-			parts := strings.Fields(cmdConfigCopy.Usage)
-			var cmdName string
-			if len(parts) > 0 {
-				cmdName = cmdConfigCopy.Name // Use the name from the config
-			} else {
-				cmdName = cmdConfigCopy.Name
+			cmd, err := newPluginCommand(logger, plugin, latestVersion, cmdConfig, pluginFlags, parentCmd != nil, language, settings)
+			if err != nil {
+				skip(cmdConfig.Name, fmt.Errorf("plugin %s: command %s: %w", plugin.Name, cmdConfig.Name, err))
+				continue
 			}
 
-			// Build usage pattern with arguments
-			usage := cmdConfigCopy.Usage
-			usage = strings.TrimPrefix(usage, "wpcli ")
+			// Add the command to the appropriate parent. The names and
+			// aliases taken first in a group keep their command.
+			if parentCmd != nil && hasChild(parentCmd, cmd.Name()) {
+				skip(cmdConfig.Name, fmt.Errorf("plugin %s: command %s is already defined in group %s", plugin.Name, cmdConfig.Name, plugin.Subcommand))
+				continue
+			}
+			if parentCmd != nil {
+				cmd.Aliases = slices.DeleteFunc(cmd.Aliases, func(alias string) bool {
+					if hasChild(parentCmd, alias) {
+						logger.Warn("dropping a command alias already in use in its group", "plugin", plugin.Name, "command", cmdConfig.Name, "alias", alias, "group", plugin.Subcommand)
+						return true
+					}
+					return false
+				})
+				parentCmd.AddCommand(cmd)
+				groupMembers[plugin.Subcommand] = append(groupMembers[plugin.Subcommand], groupMember{plugin, latestVersion, cmdConfig, pluginFlags})
+			} else if !lost[plugin.UUID+"/"+cmdConfig.Name] {
+				cmd.Aliases = slices.DeleteFunc(cmd.Aliases, func(alias string) bool { return lost[plugin.UUID+"/"+alias] })
+				rootCommands = append(rootCommands, cmd)
+			}
 
-			description := cmdConfigCopy.Description
+			if uuidGroup != nil {
+				uuidCmd, err := newPluginCommand(logger, plugin, latestVersion, cmdConfig, pluginFlags, false, language, settings)
+				if err != nil {
+					skip(cmdConfig.Name, fmt.Errorf("plugin %s: command %s: %w", plugin.Name, cmdConfig.Name, err))
+					continue
+				}
+				uuidGroup.AddCommand(uuidCmd)
+			}
+		}
 
-			cmd := &cobra.Command{
-				Use:   usage,
-				Short: fmt.Sprintf("%s (%s v%s)", description, plugin.Name, latestVersion.Version),
-				Long:  description,
-				Args: func(cmd *cobra.Command, args []string) error {
-					// Validate arguments
-					if len(args) < requiredArgs {
-						return fmt.Errorf("requires at least %d argument(s)", requiredArgs)
-					}
-					return nil
-				},
-				PreRunE: func(cmd *cobra.Command, args []string) error {
-					// First validate that all required flags are provided
-					if err := cmd.ValidateRequiredFlags(); err != nil {
-						return err
-					}
+		if uuidGroup != nil && uuidGroup.HasSubCommands() {
+			rootCommands = append(rootCommands, uuidGroup)
+		}
+	}
+	groups.describe()
 
-					// Then validate all flag values
-					for _, flag := range cmdConfigCopy.Flags {
-						handler := flags.GetHandler(flags.ParseFlagType(string(flag.Type)), flag)
-						flagName := flags.NormalizeFlagName(flag.Name)
-						// Always validate if the flag was set, regardless of default value
-						if cmd.Flags().Changed(flagName) {
-							value, err := handler.GetValue(cmd, flagName)
-							if err != nil {
-								return fmt.Errorf("failed to get value for flag %s: %w", flag.Name, err)
-							}
-
-							if err := handler.ValidateValue(flag, value); err != nil {
-								return err
-							}
-						}
-					}
-					return nil
-				},
-				RunE: func(cmd *cobra.Command, args []string) error {
-					// Re-run validation in RunE to ensure errors are properly propagated
-					if err := cmd.ValidateRequiredFlags(); err != nil {
-						return err
-					}
+	if settings.CollapseSingleCommandGroups {
+		aliases, err := collapseSingleCommandGroups(logger, rootCommands, groupMembers, reserved.Commands, language, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		rootCommands = append(rootCommands, aliases...)
+	}
 
-					// Then validate all flag values again
-					for _, flag := range cmdConfigCopy.Flags {
-						handler := flags.GetHandler(flags.ParseFlagType(string(flag.Type)), flag)
-						flagName := flags.NormalizeFlagName(flag.Name)
-
-						// Always validate if the flag was set, regardless of default value
-						if cmd.Flags().Changed(flagName) {
-							value, err := handler.GetValue(cmd, flagName)
-							if err != nil {
-								return fmt.Errorf("failed to get value for flag %s: %w", flag.Name, err)
-							}
-
-							if err := handler.ValidateValue(flag, value); err != nil {
-								return err
-							}
-						}
-					}
+	return rootCommands, skipped, nil
+}
 
-					// Only print command summary if validation passes
-					cmdStr := flags.BuildCommandSummary(cmdName, args, cmd)
-					fmt.Printf("Executing: %s\n", cmdStr)
-					return nil
-				},
-			}
+// loadPluginVersion reads the configuration of the version of a plugin its
+// commands are built from, and checks it. The plugin gets the memory limit
+// and the permissions that apply to its commands, and the version the
+// location of its module. Plugin level flags are made persistent, so the
+// commands below the one they are added to inherit them.
+func loadPluginVersion(logger *slog.Logger, configPath string, cache *CommandCache, plugin *Plugin, version *Version, reserved Reserved, settings *Settings) (*Plugin, error) {
+	versionDir := filepath.Join(filepath.Dir(configPath), plugin.UUID, version.Version)
+	pluginConfigPath := filepath.Join(versionDir, version.Conf)
+	version.ConfigPath = pluginConfigPath
+	if version.Wasm != "" {
+		version.Wasm = ModuleLocation(filepath.Dir(configPath), settings.InstallDir, *plugin, *version)
+	}
+	pluginConfig, err := cache.loadPluginConfig(logger, pluginConfigPath, settings.StrictConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin config for %s: %w", plugin.Name, err)
+	}
+	if err := CheckRequiresWpcli(logger, *plugin, pluginConfig); err != nil {
+		return nil, err
+	}
+
+	// The commands run the modules with the limit that applies to them
+	plugin.MemoryLimit = EffectiveMemoryLimit(*plugin, pluginConfig, settings)
+	if _, err := ParseMemoryLimit(plugin.MemoryLimit); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", plugin.Name, err)
+	}
+	// The commands hand their flags to the module in the payload it reads
+	if err := checkPayloadVersion(pluginConfig.PayloadVersion); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", plugin.Name, err)
+	}
+	plugin.PayloadVersion = pluginConfig.PayloadVersion
+	// The commands need the permissions of the version granted
+	if err := checkPermissions(pluginConfig.Permissions); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", plugin.Name, err)
+	}
+	requested := RequestedPermissions(pluginConfig)
+	plugin.Permissions = &requested
 
-			// Add arguments
-			for _, arg := range cmdConfigCopy.Args {
-				cmd.Use = strings.ReplaceAll(cmd.Use, "<"+arg.Name+">", fmt.Sprintf("<%s>", arg.Name))
-				argDesc := arg.Description
-				cmd.Long = fmt.Sprintf("%s\n\nArguments:\n  %s (%s) - %s", cmd.Long, arg.Name, arg.Type, argDesc)
+	for _, flag := range pluginConfig.Flags {
+		flag.Persistent = true
+	}
+	if err := checkReservedFlags(*plugin, "the plugin", pluginConfig.Flags, reserved); err != nil {
+		return nil, err
+	}
+	return pluginConfig, nil
+}
+
+// GetRunCommands builds the commands of the plugin with the given UUID for
+// wpcli run: every command of the version selected as for
+// GetPluginCommands, with the plugin level flags, outside of any group and
+// whatever names other plugins use. Only the configuration of that plugin is
+// read. Commands with an invalid configuration are skipped and reported as
+// LoadErrors; a plugin that cannot be loaded fails.
+func GetRunCommands(logger *slog.Logger, configPath string, cache *CommandCache, uuid string, reserved Reserved, language string, settings *Settings) ([]*cobra.Command, []LoadError, error) {
+	config, err := cache.readCatalog(logger, configPath, settings.StrictConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	index := slices.IndexFunc(config.Plugins, func(plugin Plugin) bool { return plugin.UUID == uuid })
+	if index < 0 {
+		return nil, nil, fmt.Errorf("plugin with UUID %s not found", uuid)
+	}
+	plugin := config.Plugins[index]
+
+	version, err := settings.SelectVersion(plugin)
+	if err != nil {
+		return nil, nil, err
+	}
+	pluginConfig, err := loadPluginVersion(logger, configPath, cache, &plugin, &version, reserved, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var commands []*cobra.Command
+	var skipped []LoadError
+	for _, cmdConfig := range pluginConfig.Commands {
+		err := checkReservedFlags(plugin, "command "+cmdConfig.Name, cmdConfig.Flags, reserved)
+		if err == nil {
+			if err = checkPluginFlagConflicts(pluginConfig.Flags, cmdConfig); err != nil {
+				err = fmt.Errorf("plugin %s: %w", plugin.Name, err)
+			}
+		}
+		var cmd *cobra.Command
+		if err == nil {
+			if cmd, err = newPluginCommand(logger, plugin, version, cmdConfig, pluginConfig.Flags, false, language, settings); err != nil {
+				err = fmt.Errorf("plugin %s: command %s: %w", plugin.Name, cmdConfig.Name, err)
 			}
+		}
+		if err != nil {
+			skipped = append(skipped, LoadError{Plugin: plugin.Name, UUID: plugin.UUID, Version: version.Version, Command: cmdConfig.Name, Err: err})
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, skipped, nil
+}
 
-			// Add examples
-			if len(cmdConfigCopy.Examples) > 0 {
-				examples := "\n\nExamples:\n"
-				for _, example := range cmdConfigCopy.Examples {
-					examples += fmt.Sprintf("  %s\n", example.Command)
-				}
-				cmd.Long += examples
+// readCatalog reads and parses plugins.yml, with the versions of each plugin
+// sorted from the latest. Unknown fields are errors when strict is set.
+func readCatalog(logger *slog.Logger, configPath string, strict bool) (*PluginConfig, error) {
+	config := &PluginConfig{}
+	data, err := readFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins.yml: %w", err)
+	}
+
+	if err := decodeYAML(logger, configPath, data, config, strict); err != nil {
+		return nil, err
+	}
+
+	if err := checkLayout(config); err != nil {
+		return nil, err
+	}
+	sortVersions(logger, config)
+	for i := range config.Plugins {
+		config.Plugins[i].Subcommand = strings.Join(config.Plugins[i].GroupPath(), " ")
+	}
+	return config, nil
+}
+
+// checkReservedFlags rejects plugin flags whose command line name or shorthand
+// is used by wpcli itself. owner names where the flags are declared, such as
+// "command install".
+func checkReservedFlags(plugin Plugin, owner string, flagList []*flags.Flag, reserved Reserved) error {
+	for _, flag := range flagList {
+		name := flag.CLIName()
+		for _, reservedName := range reserved.Flags {
+			if name == reservedName {
+				return fmt.Errorf("plugin %s: flag --%s of %s is reserved by wpcli; use expose_as to give it another name", plugin.Name, name, owner)
 			}
+		}
 
-			// Add flags
-			if err := flags.AddFlags(cmd, cmdConfigCopy.Flags); err != nil {
-				return nil, fmt.Errorf("failed to add flags: %w", err)
+		shorthand := flags.NormalizeShorthand(flag.Shorthand)
+		for _, reservedShorthand := range reserved.Shorthands {
+			if shorthand != "" && shorthand == reservedShorthand {
+				return fmt.Errorf("plugin %s: shorthand -%s of flag %s of %s is reserved by wpcli", plugin.Name, shorthand, flag.Name, owner)
 			}
+		}
+	}
+	return nil
+}
 
-			// Add the command to the appropriate parent
-			if parentCmd != nil {
-				parentCmd.AddCommand(cmd)
-			} else {
-				rootCommands = append(rootCommands, cmd)
+// checkPluginFlagConflicts rejects command flags using the name or shorthand
+// of a plugin level flag, which the command would otherwise shadow
+func checkPluginFlagConflicts(pluginFlags []*flags.Flag, cmdConfig PluginCommandConfig) error {
+	for _, pluginFlag := range pluginFlags {
+		pluginShorthand := flags.NormalizeShorthand(pluginFlag.Shorthand)
+		for _, flag := range cmdConfig.Flags {
+			if flag.CLIName() == pluginFlag.CLIName() {
+				return fmt.Errorf("flag --%s of command %s conflicts with the plugin level flag %s", flag.CLIName(), cmdConfig.Name, pluginFlag.Name)
 			}
+			if shorthand := flags.NormalizeShorthand(flag.Shorthand); shorthand != "" && shorthand == pluginShorthand {
+				return fmt.Errorf("shorthand -%s of flag %s of command %s conflicts with the plugin level flag %s", shorthand, flag.Name, cmdConfig.Name, pluginFlag.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// addGroupFlags adds the plugin level flags of a grouped plugin to its group
+// command. Groups can hold several plugins, which cannot declare the same flag.
+func addGroupFlags(logger *slog.Logger, group *cobra.Command, plugin Plugin, pluginFlags []*flags.Flag, language string) error {
+	for _, flag := range pluginFlags {
+		if group.PersistentFlags().Lookup(flag.CLIName()) != nil {
+			return fmt.Errorf("plugin %s: flag --%s is already declared by another plugin of group %s", plugin.Name, flag.CLIName(), group.Name())
 		}
 	}
 
-	return rootCommands, nil
+	if err := flags.AddFlags(logger, group, pluginFlags, language); err != nil {
+		return fmt.Errorf("plugin %s: failed to add plugin flags: %w", plugin.Name, err)
+	}
+	return nil
 }
 
-// Add this function to handle invalid subcommands
-func init() {
-	// Override the default behavior for invalid subcommands
-	cobra.OnInitialize(func() {
-		// This will be called after all flags are parsed
-		// We can check if the command is valid here
+// newPluginCommand builds the cobra command for a single plugin command. The
+// plugin level flags are added to the command unless it inherits them from
+// its group command. The settings tell how the plugin module runs.
+func newPluginCommand(logger *slog.Logger, plugin Plugin, latestVersion Version, cmdConfig PluginCommandConfig, pluginFlags []*flags.Flag, inherited bool, language string, settings *Settings) (*cobra.Command, error) {
+	// Create a copy of cmdConfig for the closure
+	cmdConfigCopy := cmdConfig
+
+	if err := checkProtocol(cmdConfigCopy.Protocol); err != nil {
+		return nil, err
+	}
+	if err := checkEnv(cmdConfigCopy.Env); err != nil {
+		return nil, err
+	}
+	if err := checkMounts(cmdConfigCopy.Mounts); err != nil {
+		return nil, err
+	}
+
+	// Count required arguments
+	requiredArgs := 0
+	for _, arg := range cmdConfigCopy.Args {
+		if arg.Required {
+			requiredArgs++
+		}
+	}
+
+	cmdName := cmdConfigCopy.Name
+
+	// Build usage pattern with arguments. Cobra takes the command name from
+	// the usage, so a command without one is used by its name.
+	usage := cmdConfigCopy.Usage
+	usage = strings.TrimPrefix(usage, "wpcli ")
+	if plugin.Subcommand != "" {
+		usage = strings.TrimPrefix(usage, plugin.Subcommand+" ")
+	}
+	if strings.TrimSpace(usage) == "" {
+		usage = cmdName
+	}
+
+	description := cmdConfigCopy.Description.Get(language)
+
+	// Commands of a group name the group, so aliases reveal where they come from
+	origin := plugin.Name
+	if plugin.Subcommand != "" {
+		origin = fmt.Sprintf("%s, %s group", plugin.Name, plugin.Subcommand)
+	}
+
+	// The flags of the command, including the plugin level ones
+	allFlags := append(append([]*flags.Flag{}, pluginFlags...), cmdConfigCopy.Flags...)
+
+	cmd := &cobra.Command{
+		Use:     usage,
+		Aliases: slices.Clone(cmdConfigCopy.Aliases),
+		Short:   fmt.Sprintf("%s (%s v%s)", description, plugin.Name, latestVersion.Version),
+		Long:    description,
+		Annotations: map[string]string{
+			PluginUUIDAnnotation:          plugin.UUID,
+			PluginNameAnnotation:          plugin.Name,
+			PluginVersionAnnotation:       latestVersion.Version,
+			PluginLatestVersionAnnotation: plugin.Versions[0].Version,
+			PluginGroupAnnotation:         plugin.Subcommand,
+			PluginConfigAnnotation:        latestVersion.ConfigPath,
+			PluginCommandAnnotation:       cmdConfig.Name,
+			CompletionAnnotation:          fmt.Sprintf("%s (%s)", description, origin),
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			// Validate arguments
+			if len(args) < requiredArgs {
+				return fmt.Errorf("requires at least %d argument(s)", requiredArgs)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// PreRunE has validated the flags
+			cmdStr := flags.BuildCommandSummary(cmdName, args, cmd)
+			if isDryRun(cmd) {
+				return writeDryRun(cmd, plugin, latestVersion, cmdConfigCopy, args, allFlags)
+			}
+			if latestVersion.Wasm == "" {
+				// Without a module the command only reports what it would run
+				logger.Debug("Executing: "+cmdStr, "plugin", plugin.Name, "version", latestVersion.Version)
+				return nil
+			}
+
+			policy := settings.Policy(plugin)
+			logger.Info("running plugin command", "plugin", plugin.Name, "version", latestVersion.Version, "command", cmdStr, "trust", policy.Trust)
+			if notInstalled(plugin, latestVersion) {
+				return fmt.Errorf("plugin %s version %s is not installed, run 'wpcli install %s'", plugin.Name, latestVersion.Version, plugin.Name)
+			}
+			if err := checkModule(logger, plugin, latestVersion, settings.ChecksumRequirement(policy)); err != nil {
+				return err
+			}
+			return runModule(logger, cmd, plugin, latestVersion, cmdConfigCopy, args, allFlags, language, settings.ConsentPath, policy)
+		},
+	}
+
+	// Add arguments, in a single section
+	for i, arg := range cmdConfigCopy.Args {
+		argDesc := arg.Description.Get(language)
+		if len(arg.ValidValues) > 0 {
+			argDesc += fmt.Sprintf(" (%s: %s)", flags.ValidValuesLabel(language), strings.Join(arg.ValidValues, ", "))
+		}
+		if i == 0 {
+			cmd.Long += "\n\nArguments:"
+		}
+		cmd.Long += fmt.Sprintf("\n  %s (%s) - %s", arg.Name, arg.Type, argDesc)
+	}
+	cmd.ValidArgsFunction = completeArgs(cmdConfigCopy)
+
+	// Examples have their own help section, each one after its description
+	var examples []string
+	for _, example := range cmdConfigCopy.Examples {
+		if description := example.Description.Get(language); description != "" {
+			examples = append(examples, "  # "+description)
+		}
+		examples = append(examples, "  "+example.Command)
+	}
+	cmd.Example = strings.Join(examples, "\n")
+
+	// The flags are validated before the command runs, after any PreRunE
+	// hook the command has. AddFlags chains its own checks after this one.
+	flags.ChainPreRunE(cmd, func(cmd *cobra.Command, args []string) error {
+		// Missing required flags are asked for when the user can answer,
+		// once deprecated flags have handed their values over
+		if prompter := newPrompter(cmd); prompter != nil {
+			if err := flags.ApplyReplacements(cmd); err != nil {
+				return err
+			}
+			if err := prompter.PromptRequired(cmd, allFlags); err != nil {
+				return err
+			}
+		}
+		return flags.ValidateCommand(cmd, allFlags)
 	})
+
+	// Add flags, leaving the plugin level flags to the group command when
+	// it has them
+	commandFlags, inheritedFlags := allFlags, []*flags.Flag(nil)
+	if inherited {
+		commandFlags, inheritedFlags = cmdConfigCopy.Flags, pluginFlags
+	}
+	if err := flags.AddInheritingFlags(logger, cmd, commandFlags, inheritedFlags, language); err != nil {
+		return nil, fmt.Errorf("failed to add flags: %w", err)
+	}
+	flags.ApplyHelpTemplate(cmd)
+
+	return cmd, nil
+}
+
+// completeArgs completes each positional argument of a command from its
+// valid values, and from files when it declares none
+func completeArgs(cmdConfig PluginCommandConfig) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if len(args) >= len(cmdConfig.Args) || len(cmdConfig.Args[len(args)].ValidValues) == 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+
+		var completions []cobra.Completion
+		for _, value := range cmdConfig.Args[len(args)].ValidValues {
+			if strings.HasPrefix(value, toComplete) {
+				completions = append(completions, value)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// CommandConfig returns the configuration of the plugin version a plugin
+// command was built from, and the configuration of the command in it. Other
+// commands have none.
+func CommandConfig(logger *slog.Logger, cmd *cobra.Command) (*Plugin, *PluginCommandConfig, error) {
+	path := cmd.Annotations[PluginConfigAnnotation]
+	if path == "" {
+		return nil, nil, nil
+	}
+	pluginConfig, err := loadPluginConfig(logger, path, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := cmd.Annotations[PluginCommandAnnotation]
+	index := slices.IndexFunc(pluginConfig.Commands, func(cmdConfig PluginCommandConfig) bool { return cmdConfig.Name == name })
+	if index < 0 {
+		return nil, nil, fmt.Errorf("command %s not found in %s", name, path)
+	}
+	return pluginConfig, &pluginConfig.Commands[index], nil
 }
@@ -0,0 +1,48 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// TestRegisteredFlagsAreUnchanged builds the commands of a plugin declaring
+// every kind of flag and compares the flags they register with those
+// registered before plugin commands validated their flags through the
+// flags package only, recorded in testdata/flags/registered.golden
+func TestRegisteredFlagsAreUnchanged(t *testing.T) {
+	configPath := filepath.Join("testdata", "flags", "plugins.yml")
+	commands, skipped, err := GetPluginCommands(slog.New(slog.DiscardHandler), configPath, nil, Reserved{}, "en", &Settings{}, "flags-uuid")
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("err = %v, skipped = %v", err, skipped)
+	}
+
+	var got strings.Builder
+	var describe func(*cobra.Command)
+	describe = func(cmd *cobra.Command) {
+		cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+			fmt.Fprintf(&got, "%s --%s -%s %s default=%q hidden=%v usage=%q\n",
+				cmd.CommandPath(), flag.Name, flag.Shorthand, flag.Value.Type(), flag.DefValue, flag.Hidden, flag.Usage)
+		})
+		for _, sub := range cmd.Commands() {
+			describe(sub)
+		}
+	}
+	for _, cmd := range commands {
+		describe(cmd)
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "flags", "registered.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != string(want) {
+		t.Errorf("registered flags changed\ngot:\n%s\nwant:\n%s", got.String(), want)
+	}
+}
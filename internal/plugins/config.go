@@ -2,26 +2,56 @@ package plugins
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/i18n"
 	"gopkg.in/yaml.v3"
 )
 
 type Version struct {
 	Version string `yaml:"version"`
 	Conf    string `yaml:"conf"`
+	// Wasm is the WebAssembly module running the commands of the plugin,
+	// next to Conf. Commands of versions without one only print what they
+	// would run.
+	Wasm string `yaml:"wasm,omitempty"`
+	// SHA256 is the hex encoded digest of Wasm, checked before it runs
+	SHA256 string `yaml:"sha256,omitempty"`
+	// ConfigPath is the location of Conf, set when the commands of the
+	// version are built. It does not come from plugins.yml.
+	ConfigPath string `yaml:"-" json:"-"`
 }
 
 type Plugin struct {
-	Name        string                 `yaml:"name"`
-	Description string                 `yaml:"description"`
-	UUID        string                 `yaml:"uuid"`
-	Versions    []Version              `yaml:"versions"`
-	Subcommand  string                 `yaml:"subcommand,omitempty"`
-	Version     string                 `yaml:"version,omitempty"`
-	Commands    []PluginCommandConfig  `yaml:"commands,omitempty"`
+	Name        string    `yaml:"name"`
+	Description i18n.Text `yaml:"description"`
+	UUID        string    `yaml:"uuid"`
+	Versions    []Version `yaml:"versions"`
+	// Subcommand is the group the commands of the plugin are under, which
+	// can be nested as a space separated path such as "db migrate"
+	Subcommand string                `yaml:"subcommand,omitempty"`
+	Version    string                `yaml:"version,omitempty"`
+	Commands   []PluginCommandConfig `yaml:"commands,omitempty"`
+	// Flags are shared by all the commands of the plugin. They are inherited
+	// from the group command of grouped plugins.
+	Flags []*flags.Flag `yaml:"flags,omitempty"`
+	// MemoryLimit caps the memory of the modules of the plugin, such as
+	// 64MiB, see EffectiveMemoryLimit
+	MemoryLimit string `yaml:"memory_limit,omitempty"`
+	// RequiresWpcli is the wpcli version the plugin needs, such as >=0.4.0,
+	// see CheckRequiresWpcli
+	RequiresWpcli string `yaml:"requires_wpcli,omitempty"`
+	// PayloadVersion is the version of the invocation payload the module
+	// reads, PayloadVersion1 by default, see checkPayloadVersion
+	PayloadVersion int `yaml:"payload_version,omitempty"`
+	// Permissions lists the host resources the commands of a plugin version
+	// use, in its configuration, see RequestedPermissions
+	Permissions *Permissions           `yaml:"permissions,omitempty"`
 	Metadata    map[string]interface{} `yaml:"metadata,omitempty"` // For plugin-specific data
 }
 
@@ -31,34 +61,141 @@ type Settings struct {
 	LogLevel           string   `yaml:"log_level"`
 	DefaultLanguage    string   `yaml:"default_language"`
 	SupportedLanguages []string `yaml:"supported_languages"`
+	// CollapseSingleCommandGroups registers a root level alias for subcommand
+	// groups that contain a single command
+	CollapseSingleCommandGroups bool `yaml:"collapse_single_command_groups"`
+	// AutoPrune removes plugin directories no longer in the catalog after each pull
+	AutoPrune bool `yaml:"auto_prune"`
+	// DisableUpdateHints hides the "newer version available" hint in the help
+	// of plugin commands
+	DisableUpdateHints bool `yaml:"disable_update_hints"`
+	// RepositoryRef pins the branch of the repository to follow instead of
+	// its default branch
+	RepositoryRef string `yaml:"repository_ref"`
+	// MirrorRepositories are tried in order when the repository is unreachable
+	MirrorRepositories []string `yaml:"mirror_repositories"`
+	// PluginVersions pins plugins, by name or UUID, to a version other than
+	// the latest
+	PluginVersions map[string]string `yaml:"plugin_versions"`
+	// PluginTimeout halts plugin modules running longer, e.g. "5m". The
+	// --timeout flag overrides it.
+	PluginTimeout string `yaml:"plugin_timeout"`
+	// PluginMemoryLimit caps the memory of plugin modules, such as 64MiB.
+	// Plugins can set their own limit.
+	PluginMemoryLimit string `yaml:"plugin_memory_limit"`
+	// StrictConfig makes unknown fields of plugins.yml and of the plugin
+	// configurations errors instead of warnings. The --strict flag sets it.
+	StrictConfig bool `yaml:"strict_config"`
+	// RequireChecksums refuses to run plugin modules whose version has no
+	// sha256 checksum, instead of warning
+	RequireChecksums bool `yaml:"require_checksums"`
+	// RepositoryTrust maps repository locations to their trust level,
+	// selecting the policy of their plugins, see Settings.Policy. It only
+	// comes from the user configuration, like PluginTrust.
+	RepositoryTrust map[string]string `yaml:"repository_trust"`
+	// PluginTrust maps plugins, by name or UUID, to the trust level
+	// overriding the one of their repository
+	PluginTrust map[string]string `yaml:"plugin_trust"`
+	// Retry controls retries of transient network failures on clone and pull
+	Retry RetrySettings `yaml:"retry"`
+	// InstallDir is where wpcli install places plugin modules, the plugins
+	// directory of the config directory by default
+	InstallDir string `yaml:"install_dir,omitempty"`
+	// OnlyInstalled registers the commands of installed plugins only
+	OnlyInstalled bool `yaml:"only_installed"`
+	// Installed maps the UUID of each installed plugin to its installed
+	// version. It comes from the local state, not from the settings files.
+	Installed map[string]string `yaml:"-" json:"-"`
+	// ConsentPath is the file of the permissions granted to plugin
+	// versions. It comes from the config directory, not from the settings
+	// files.
+	ConsentPath string `yaml:"-" json:"-"`
+}
+
+// RetrySettings configures retries of repository network operations
+type RetrySettings struct {
+	// Attempts is the total number of attempts; 1 disables retries
+	Attempts int `yaml:"attempts"`
+	// InitialDelay is the delay before the first retry, e.g. "500ms"
+	InitialDelay string `yaml:"initial_delay"`
+	// MaxDelay caps the delay between attempts, e.g. "4s"
+	MaxDelay string `yaml:"max_delay"`
+}
+
+// GetDescription returns the description in the given language, falling back
+// like i18n.Text.Get
+func (p Plugin) GetDescription(language string) string {
+	return p.Description.Get(language)
+}
+
+// ChangelogLine returns the first line of the changelog entry of a plugin
+// version, from the changelog of the plugin metadata keyed by version
+func (p Plugin) ChangelogLine(version string) string {
+	changelog, ok := p.Metadata["changelog"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	entry, _ := changelog[version].(string)
+	line, _, _ := strings.Cut(strings.TrimSpace(entry), "\n")
+	return line
 }
 
 type PluginConfig struct {
-	Plugins  []Plugin `yaml:"plugins"`
-	Settings Settings `yaml:"settings"`
+	// LayoutVersion is the version of the registry layout, see CurrentLayoutVersion
+	LayoutVersion int      `yaml:"layout_version"`
+	Plugins       []Plugin `yaml:"plugins"`
+	Settings      Settings `yaml:"settings"`
 }
 
 type ConfigManager struct {
 	configPath string
 	config     *PluginConfig
+	// strict rejects unknown fields instead of warning
+	strict bool
+	logger *slog.Logger
 }
 
 func NewConfigManager(repoPath string) *ConfigManager {
 	return &ConfigManager{
 		configPath: filepath.Join(repoPath, "plugins.yml"),
+		logger:     slog.New(slog.DiscardHandler),
 	}
 }
 
+// SetLogger makes the warnings about plugins.yml go to logger, they are
+// dropped by default
+func (cm *ConfigManager) SetLogger(logger *slog.Logger) {
+	cm.logger = logger
+}
+
+// SetStrict makes unknown fields of plugins.yml errors instead of warnings
+func (cm *ConfigManager) SetStrict(strict bool) {
+	cm.strict = strict
+}
+
 func (cm *ConfigManager) Load() error {
 	data, err := os.ReadFile(cm.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read plugins.yml: %w", err)
 	}
 
+	return cm.Parse(data)
+}
+
+// Parse loads the configuration from the raw contents of a plugins.yml file
+func (cm *ConfigManager) Parse(data []byte) error {
 	config := &PluginConfig{}
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return fmt.Errorf("failed to parse plugins.yml: %w", err)
+	if err := decodeYAML(cm.logger, cm.configPath, data, config, cm.strict); err != nil {
+		return err
+	}
+
+	if err := checkLayout(config); err != nil {
+		return err
 	}
+	if err := checkUniqueUUIDs(config); err != nil {
+		return err
+	}
+	sortVersions(cm.logger, config)
 
 	cm.config = config
 	return nil
@@ -71,18 +208,129 @@ func (cm *ConfigManager) GetPlugins() []Plugin {
 	return cm.config.Plugins
 }
 
+// AmbiguousPluginError is returned when several plugins share the requested name
+type AmbiguousPluginError struct {
+	Name       string
+	Candidates []Plugin
+}
+
+// GroupPath returns the names of the nested groups the commands of the plugin
+// are under, from the root level one, or nil when the plugin has no group
+func (p Plugin) GroupPath() []string {
+	return strings.Fields(p.Subcommand)
+}
+
+func (e *AmbiguousPluginError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "plugin name %s is ambiguous, use the UUID to choose one of:", e.Name)
+	for _, plugin := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s", plugin.UUID)
+		if plugin.Subcommand != "" {
+			fmt.Fprintf(&b, " (subcommand: %s)", plugin.Subcommand)
+		}
+	}
+	return b.String()
+}
+
 func (cm *ConfigManager) GetPluginByName(name string) (*Plugin, error) {
 	if cm.config == nil {
 		return nil, fmt.Errorf("config not loaded")
 	}
 
+	var matches []Plugin
 	for _, plugin := range cm.config.Plugins {
 		if plugin.Name == name {
+			matches = append(matches, plugin)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("plugin %s not found", name)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, &AmbiguousPluginError{Name: name, Candidates: matches}
+	}
+}
+
+// GetPluginByUUID returns the plugin with the given UUID
+func (cm *ConfigManager) GetPluginByUUID(uuid string) (*Plugin, error) {
+	if cm.config == nil {
+		return nil, fmt.Errorf("config not loaded")
+	}
+
+	for _, plugin := range cm.config.Plugins {
+		if plugin.UUID == uuid {
 			return &plugin, nil
 		}
 	}
 
-	return nil, fmt.Errorf("plugin %s not found", name)
+	return nil, fmt.Errorf("plugin with uuid %s not found", uuid)
+}
+
+// GetPlugin resolves a plugin by name, falling back to its UUID. Arguments
+// formatted as UUIDs are looked up by UUID first.
+func (cm *ConfigManager) GetPlugin(nameOrUUID string) (*Plugin, error) {
+	if IsUUID(nameOrUUID) {
+		if plugin, err := cm.GetPluginByUUID(nameOrUUID); err == nil {
+			return plugin, nil
+		}
+	}
+
+	plugin, err := cm.GetPluginByName(nameOrUUID)
+	if err == nil {
+		return plugin, nil
+	}
+
+	if _, ambiguous := err.(*AmbiguousPluginError); ambiguous {
+		return nil, err
+	}
+
+	if plugin, uuidErr := cm.GetPluginByUUID(nameOrUUID); uuidErr == nil {
+		return plugin, nil
+	}
+
+	return nil, err
+}
+
+// uuidFormat matches UUIDs in their canonical text form
+var uuidFormat = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsUUID reports whether s is formatted as a UUID, such as
+// 123e4567-e89b-42d3-a456-426614174000
+func IsUUID(s string) bool {
+	return uuidFormat.MatchString(s)
+}
+
+// checkUniqueUUIDs fails when plugins of the catalog share a UUID, since
+// plugins are told apart by UUID
+func checkUniqueUUIDs(config *PluginConfig) error {
+	seen := make(map[string]string)
+	for _, plugin := range config.Plugins {
+		if other, ok := seen[plugin.UUID]; ok && plugin.UUID != "" {
+			return fmt.Errorf("plugins %s and %s have the same uuid %s", other, plugin.Name, plugin.UUID)
+		}
+		seen[plugin.UUID] = plugin.Name
+	}
+	return nil
+}
+
+// DuplicateNames returns the plugins that share their name with another
+// plugin, keyed by name
+func (cm *ConfigManager) DuplicateNames() map[string][]Plugin {
+	byName := make(map[string][]Plugin)
+	for _, plugin := range cm.GetPlugins() {
+		byName[plugin.Name] = append(byName[plugin.Name], plugin)
+	}
+
+	duplicates := make(map[string][]Plugin)
+	for name, plugins := range byName {
+		if len(plugins) > 1 {
+			duplicates[name] = plugins
+		}
+	}
+	return duplicates
 }
 
 func (cm *ConfigManager) GetSettings() *Settings {
@@ -94,36 +342,113 @@ func (cm *ConfigManager) GetSettings() *Settings {
 
 // PluginCommandConfig represents the configuration for a plugin command
 type PluginCommandConfig struct {
-	Name        string `yaml:"name"`
-	Description string `yaml:"description"`
-	Usage       string `yaml:"usage"`
-	Examples    []struct {
-		Command string `yaml:"command"`
-	} `yaml:"examples"`
-	Args []struct {
-		Name        string `yaml:"name"`
-		Type        string `yaml:"type"`
-		Description string `yaml:"description"`
-		Required    bool   `yaml:"required"`
+	Name string `yaml:"name"`
+	// Aliases are other names the command runs with, such as ls for list.
+	// An alias already in use by another command is dropped.
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Description is a plain string, taken as English, or a map of
+	// translations by language code
+	Description i18n.Text `yaml:"description"`
+	Usage       string    `yaml:"usage"`
+	Examples    []Example `yaml:"examples"`
+	Args        []struct {
+		Name        string    `yaml:"name"`
+		Type        string    `yaml:"type"`
+		Description i18n.Text `yaml:"description"`
+		Required    bool      `yaml:"required"`
+		// ValidValues are the choices shell completion proposes for the
+		// argument
+		ValidValues []string `yaml:"valid_values,omitempty"`
 	} `yaml:"args"`
 	Flags []*flags.Flag `yaml:"flags"`
+	// Protocol tells how the command hands its arguments and flags to the
+	// plugin module, ProtocolArgv by default
+	Protocol string `yaml:"protocol,omitempty"`
+	// Env lists the host environment variables passed to the plugin module,
+	// which sees no others
+	Env []EnvVar `yaml:"env,omitempty"`
+	// Mounts are the host directories the plugin module can access, which
+	// sees no other host files
+	Mounts []Mount `yaml:"mounts,omitempty"`
 	// Additional fields from PluginCommand
 	ConfigFile string `yaml:"config_file,omitempty"`
 	Version    string `yaml:"version,omitempty"`
 	Subcommand string `yaml:"subcommand,omitempty"`
 }
 
-// loadPluginConfig loads a plugin's YAML configuration file
-func loadPluginConfig(configPath string) (*Plugin, error) {
-	data, err := os.ReadFile(configPath)
+// Example is a usage example of a plugin command
+type Example struct {
+	Command     string    `yaml:"command"`
+	Description i18n.Text `yaml:"description,omitempty"`
+}
+
+// UnmarshalYAML accepts both a plain command string and the full example form
+func (e *Example) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Decode(&e.Command)
+	case yaml.MappingNode:
+	default:
+		return fmt.Errorf("line %d: expected an example command or a map with its command and description", node.Line)
+	}
+
+	type plain Example
+	return node.Decode((*plain)(e))
+}
+
+// EnvVar is a host environment variable requested by a plugin command
+type EnvVar struct {
+	Name string `yaml:"name"`
+	// Required variables must be set for the command to run
+	Required bool `yaml:"required,omitempty"`
+}
+
+// UnmarshalYAML accepts both a plain variable name and the full form
+func (e *EnvVar) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Name)
+	}
+
+	type plain EnvVar
+	return node.Decode((*plain)(e))
+}
+
+// Mount makes a host directory available to a plugin module
+type Mount struct {
+	// HostPath may start with ~ and contain environment variables. Relative
+	// paths are resolved against the working directory.
+	HostPath string `yaml:"host_path"`
+	// GuestPath is the absolute path the module sees the directory at
+	GuestPath string `yaml:"guest_path"`
+	ReadOnly  bool   `yaml:"read_only,omitempty"`
+}
+
+// readFile reads plugins.yml and the plugin configurations, which tests
+// replace to see which files commands are built from
+var readFile = os.ReadFile
+
+// loadPluginConfig loads a plugin's YAML configuration file, rejecting
+// unknown fields when strict is set
+func loadPluginConfig(logger *slog.Logger, configPath string, strict bool) (*Plugin, error) {
+	logger.Debug("reading plugin config", "path", configPath)
+	data, err := readFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read plugin config: %w", err)
 	}
 
 	config := &Plugin{}
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse plugin config: %w", err)
+	if err := decodeYAML(logger, configPath, data, config, strict); err != nil {
+		return nil, err
 	}
+	return config, nil
+}
 
+// ParsePluginConfig parses the raw contents of a plugin's YAML configuration,
+// with file naming it in errors
+func ParsePluginConfig(logger *slog.Logger, file string, data []byte) (*Plugin, error) {
+	config := &Plugin{}
+	if err := decodeYAML(logger, file, data, config, false); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
@@ -0,0 +1,187 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// DevVersion is the version of the plugins loaded from a plugin directory
+// whose configuration gives none
+const DevVersion = "0.0.0-dev"
+
+// GetDevPluginCommands returns the root level commands of the plugin in dir,
+// a plugin directory holding a plugin configuration, with a .yml or .yaml
+// extension, and optionally the module of the plugin. The plugin is named
+// after the directory unless its configuration names it. Unlike catalog
+// plugins, a configuration with errors fails instead of being skipped.
+func GetDevPluginCommands(logger *slog.Logger, dir string, reserved Reserved, language string, settings *Settings) ([]*cobra.Command, error) {
+	plugin, pluginConfig, err := readDevPlugin(logger, dir, reserved, settings.StrictConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckRequiresWpcli(logger, plugin, pluginConfig); err != nil {
+		return nil, err
+	}
+	version := plugin.Versions[0]
+
+	// Plugin directories are no repository, so repository_trust does not
+	// apply to their plugins
+	devSettings := *settings
+	devSettings.RepositoryTrust = nil
+	settings = &devSettings
+
+	// Dev modules have no published checksum, so the module runs unchanged
+	// from the one found here
+	if version.Wasm != "" {
+		if version.SHA256, err = FileSHA256(version.Wasm); err != nil {
+			return nil, err
+		}
+		plugin.Versions = []Version{version}
+	}
+	plugin.MemoryLimit = EffectiveMemoryLimit(plugin, pluginConfig, settings)
+	if err := checkPayloadVersion(pluginConfig.PayloadVersion); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", plugin.Name, err)
+	}
+	plugin.PayloadVersion = pluginConfig.PayloadVersion
+	requested := RequestedPermissions(pluginConfig)
+	plugin.Permissions = &requested
+
+	pluginFlags := pluginConfig.Flags
+	for _, flag := range pluginFlags {
+		flag.Persistent = true
+	}
+
+	var parentCmd *cobra.Command
+	var rootCommands []*cobra.Command
+	if plugin.Subcommand != "" {
+		groups := newGroupTree()
+		group, root, err := groups.add(plugin, version)
+		if err != nil {
+			return nil, err
+		}
+		if len(pluginFlags) > 0 {
+			if err := addGroupFlags(logger, group, plugin, pluginFlags, language); err != nil {
+				return nil, err
+			}
+		}
+		root.Short += " (dev)"
+		parentCmd = group
+		rootCommands = append(rootCommands, root)
+	}
+
+	for _, cmdConfig := range pluginConfig.Commands {
+		cmd, err := newPluginCommand(logger, plugin, version, cmdConfig, pluginFlags, parentCmd != nil, language, settings)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: command %s: %w", plugin.Name, cmdConfig.Name, err)
+		}
+		cmd.Short += " (dev)"
+
+		if parentCmd != nil {
+			parentCmd.AddCommand(cmd)
+			continue
+		}
+		rootCommands = append(rootCommands, cmd)
+	}
+
+	// Builtin commands keep their names
+	for _, cmd := range rootCommands {
+		for _, name := range append([]string{cmd.Name()}, cmd.Aliases...) {
+			if slices.Contains(reserved.Commands, name) {
+				return nil, fmt.Errorf("plugin %s: %s is the name of a wpcli command", plugin.Name, name)
+			}
+		}
+	}
+	return rootCommands, nil
+}
+
+// readDevPlugin reads the plugin of a plugin directory, with a single version
+// whose Wasm is the path of the module, if any. The configuration is checked
+// like validate checks the registry, and fails on errors.
+func readDevPlugin(logger *slog.Logger, dir string, reserved Reserved, strict bool) (Plugin, *Plugin, error) {
+	configPath, wasmPath, err := findDevPluginFiles(dir)
+	if err != nil {
+		return Plugin{}, nil, err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return Plugin{}, nil, fmt.Errorf("failed to read plugin config: %w", err)
+	}
+	pluginConfig, err := ParsePluginConfig(logger, configPath, data)
+	if err != nil {
+		return Plugin{}, nil, err
+	}
+
+	plugin := Plugin{
+		Name:        pluginConfig.Name,
+		Description: pluginConfig.Description,
+		UUID:        pluginConfig.UUID,
+		Subcommand:  pluginConfig.Subcommand,
+	}
+	if plugin.Name == "" {
+		plugin.Name = filepath.Base(dir)
+	}
+	if plugin.UUID == "" {
+		plugin.UUID = "dev-" + plugin.Name
+	}
+	version := Version{Version: pluginConfig.Version, Conf: filepath.Base(configPath), Wasm: wasmPath, ConfigPath: configPath}
+	if version.Version == "" {
+		version.Version = DevVersion
+	}
+	plugin.Versions = []Version{version}
+
+	v := &validator{repoPath: dir, reserved: reserved, strict: strict}
+	v.validateConfig(plugin, version, configPath, data)
+	var problems []string
+	for _, finding := range v.findings {
+		if finding.Severity == SeverityError {
+			problems = append(problems, finding.String())
+		}
+	}
+	if len(problems) > 0 {
+		return Plugin{}, nil, fmt.Errorf("invalid plugin configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return plugin, pluginConfig, nil
+}
+
+// findDevPluginFiles returns the plugin configuration of a plugin directory,
+// and its module when there is one
+func findDevPluginFiles(dir string) (string, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var configs, modules []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".yml", ".yaml":
+			configs = append(configs, entry.Name())
+		case ".wasm":
+			modules = append(modules, entry.Name())
+		}
+	}
+
+	switch {
+	case len(configs) == 0:
+		return "", "", fmt.Errorf("the directory has no plugin configuration, a .yml or .yaml file")
+	case len(configs) > 1:
+		return "", "", fmt.Errorf("the directory has several plugin configurations: %s", strings.Join(configs, ", "))
+	case len(modules) > 1:
+		return "", "", fmt.Errorf("the directory has several modules: %s", strings.Join(modules, ", "))
+	}
+
+	var wasmPath string
+	if len(modules) == 1 {
+		wasmPath = filepath.Join(dir, modules[0])
+	}
+	return filepath.Join(dir, configs[0]), wasmPath, nil
+}
@@ -0,0 +1,96 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+// dryRunInvocation is what a plugin command run with --dry-run prints
+// instead of running its module. Scripts compare it across runs, so the
+// fields keep this order and flags and env are sorted by name. The payload
+// version is only given from PayloadVersion2 on.
+type dryRunInvocation struct {
+	Plugin         string                 `json:"plugin"`
+	UUID           string                 `json:"uuid"`
+	Version        string                 `json:"version"`
+	Command        string                 `json:"command"`
+	Protocol       string                 `json:"protocol"`
+	PayloadVersion int                    `json:"payload_version,omitempty"`
+	Wasm           string                 `json:"wasm"`
+	Args           []string               `json:"args"`
+	Flags          map[string]interface{} `json:"flags"`
+	Env            []string               `json:"env"`
+	Mounts         []dryRunMount          `json:"mounts"`
+}
+
+// dryRunMount is a host directory the module would see
+type dryRunMount struct {
+	Host     string `json:"host"`
+	Guest    string `json:"guest"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// isDryRun reports whether the --dry-run flag of the root command is set.
+// Plugins declaring their own --dry-run flag receive it instead.
+func isDryRun(cmd *cobra.Command) bool {
+	flag := cmd.Root().PersistentFlags().Lookup("dry-run")
+	return flag != nil && flag.Changed && flag.Value.String() == "true"
+}
+
+// writeDryRun prints the invocation of a plugin command as indented JSON:
+// the module, the arguments, the typed flag values with sensitive values
+// masked, in the form of the payload version of the plugin, the names of the environment variables passed to the module and
+// the mounts. It fails like the command would before running the module.
+func writeDryRun(cmd *cobra.Command, plugin Plugin, pluginVersion Version, cmdConfig PluginCommandConfig, args []string, flagList []*flags.Flag) error {
+	env, err := lookupEnv(plugin, cmdConfig)
+	if err != nil {
+		return err
+	}
+	mounts, err := expandMounts(plugin, cmdConfig.Mounts)
+	if err != nil {
+		return err
+	}
+	values, err := payloadFlagValues(cmd, plugin.PayloadVersion, flagList, true)
+	if err != nil {
+		return err
+	}
+
+	invocation := dryRunInvocation{
+		Plugin:   plugin.Name,
+		UUID:     plugin.UUID,
+		Version:  pluginVersion.Version,
+		Command:  cmdConfig.Name,
+		Protocol: cmdConfig.Protocol,
+		Wasm:     pluginVersion.Wasm,
+		Args:     append([]string{}, args...),
+		Flags:    values,
+		Env:      []string{},
+		Mounts:   []dryRunMount{},
+	}
+	if plugin.PayloadVersion >= PayloadVersion2 {
+		invocation.PayloadVersion = plugin.PayloadVersion
+	}
+	if invocation.Protocol == "" {
+		invocation.Protocol = ProtocolArgv
+	}
+	for name := range env {
+		invocation.Env = append(invocation.Env, name)
+	}
+	slices.Sort(invocation.Env)
+	for _, mount := range mounts {
+		invocation.Mounts = append(invocation.Mounts, dryRunMount{Host: mount.HostPath, Guest: mount.GuestPath, ReadOnly: mount.ReadOnly})
+	}
+
+	// Values are written as they are, without escaping < > and &
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(invocation); err != nil {
+		return fmt.Errorf("failed to encode the invocation of %s: %w", cmdConfig.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// groupTree holds the subcommand group commands of the plugins by path, such
+// as "db migrate", with the plugins contributing to each. Plugins whose paths
+// share a prefix share its groups.
+type groupTree struct {
+	commands map[string]*cobra.Command
+	// plugins holds the names and versions of the plugins of a group and of
+	// the groups nested in it
+	plugins map[string][]string
+}
+
+func newGroupTree() *groupTree {
+	return &groupTree{
+		commands: make(map[string]*cobra.Command),
+		plugins:  make(map[string][]string),
+	}
+}
+
+// add returns the group command of the path of a plugin, creating it and the
+// groups it is nested in when missing. The root level group is returned too
+// when it is created, to be registered by the caller.
+func (t *groupTree) add(plugin Plugin, version Version) (*cobra.Command, *cobra.Command, error) {
+	path := plugin.GroupPath()
+
+	// A group cannot take the name of a command of its parent
+	for i := 1; i < len(path); i++ {
+		key := strings.Join(path[:i+1], " ")
+		parent, ok := t.commands[strings.Join(path[:i], " ")]
+		if _, exists := t.commands[key]; !exists && ok && hasChild(parent, path[i]) {
+			return nil, nil, fmt.Errorf("plugin %s: group %s conflicts with command %s of group %s", plugin.Name, key, path[i], parent.CommandPath())
+		}
+	}
+
+	var group, root *cobra.Command
+	for i := range path {
+		key := strings.Join(path[:i+1], " ")
+		cmd, exists := t.commands[key]
+		if !exists {
+			cmd = newGroupCommand(key, path[i], plugin, version)
+			t.commands[key] = cmd
+			if group != nil {
+				group.AddCommand(cmd)
+			} else {
+				root = cmd
+			}
+		}
+
+		contributor := fmt.Sprintf("%s v%s", plugin.Name, version.Version)
+		if !slices.Contains(t.plugins[key], contributor) {
+			t.plugins[key] = append(t.plugins[key], contributor)
+		}
+		group = cmd
+	}
+	return group, root, nil
+}
+
+// describe lists the plugins contributing to each group shared by several of
+// them in its help
+func (t *groupTree) describe() {
+	for key, cmd := range t.commands {
+		if contributors := t.plugins[key]; len(contributors) > 1 {
+			cmd.Long = fmt.Sprintf("Commands for %s plugins\n\nPlugins: %s", key, strings.Join(contributors, ", "))
+		}
+	}
+}
+
+// newGroupCommand builds the command of the group at a path, named after the
+// last name of the path
+func newGroupCommand(path, name string, plugin Plugin, version Version) *cobra.Command {
+	return &cobra.Command{
+		Use:   name,
+		Short: fmt.Sprintf("Commands for %s plugins (%s v%s)", path, plugin.Name, version.Version),
+		Long:  fmt.Sprintf("Commands for %s plugins\n\nVersion: %s\n\nPlugin: %s", path, version.Version, plugin.Name),
+		Annotations: map[string]string{
+			CompletionAnnotation: fmt.Sprintf("Commands for %s plugins (%s)", path, plugin.Name),
+		},
+	}
+}
+
+// hasChild tells whether a command has a subcommand of the given name or alias
+func hasChild(cmd *cobra.Command, name string) bool {
+	return slices.ContainsFunc(cmd.Commands(), func(child *cobra.Command) bool { return child.Name() == name || child.HasAlias(name) })
+}
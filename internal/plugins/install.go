@@ -0,0 +1,296 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// partialSuffix ends the name of modules being downloaded. A partial
+// download is resumed by the next install.
+const partialSuffix = ".part"
+
+// IsRemoteModule reports whether the module of a plugin version is given by
+// an http or https URL, and so only exists once installed
+func IsRemoteModule(version Version) bool {
+	return strings.HasPrefix(version.Wasm, "http://") || strings.HasPrefix(version.Wasm, "https://")
+}
+
+// InstallPath returns the location of the installed module of a plugin
+// version inside installDir
+func InstallPath(installDir string, plugin Plugin, version Version) string {
+	name := filepath.Base(version.Wasm)
+	if IsRemoteModule(version) {
+		name = "plugin.wasm"
+		if u, err := url.Parse(version.Wasm); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			name = path.Base(u.Path)
+		}
+	}
+	return filepath.Join(installDir, plugin.UUID, version.Version, name)
+}
+
+// ModuleLocation returns where the module of a plugin version runs from: its
+// installed copy when there is one, otherwise the module in the repository.
+// Remote modules always run from their installed copy.
+func ModuleLocation(repoPath, installDir string, plugin Plugin, version Version) string {
+	installed := InstallPath(installDir, plugin, version)
+	if IsRemoteModule(version) {
+		return installed
+	}
+	if moduleExists(installed) {
+		return installed
+	}
+	return ModulePath(repoPath, plugin, version)
+}
+
+// moduleExists reports whether the module at path exists
+func moduleExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ModuleInstalled reports whether the module of a plugin version is installed
+// in installDir
+func ModuleInstalled(installDir string, plugin Plugin, version Version) bool {
+	return moduleExists(InstallPath(installDir, plugin, version))
+}
+
+// notInstalled reports whether a plugin version has a remote module that is
+// not installed. The module location of version is already resolved, the
+// catalog URL is taken from the versions of the plugin.
+func notInstalled(plugin Plugin, version Version) bool {
+	for _, listed := range plugin.Versions {
+		if listed.Version == version.Version {
+			return IsRemoteModule(listed) && !moduleExists(version.Wasm)
+		}
+	}
+	return false
+}
+
+// Install places the module of a plugin version in installDir: remote modules
+// are downloaded, resuming a partial download, and the others are copied
+// from the repository. The module is verified against the checksum of the
+// version before it takes its place. The download progress is written to
+// progress, unless it is nil. It returns the location of the installed
+// module.
+func Install(ctx context.Context, logger *slog.Logger, repoPath, installDir string, plugin Plugin, version Version, checksumRequiredBy string, progress io.Writer) (string, error) {
+	if version.Wasm == "" {
+		return "", fmt.Errorf("plugin %s version %s has no wasm module to install", plugin.Name, version.Version)
+	}
+	if checksumRequiredBy != "" && version.SHA256 == "" {
+		return "", fmt.Errorf("plugin %s: module %s has no sha256 checksum, which %s demands", plugin.Name, version.Wasm, checksumRequiredBy)
+	}
+
+	target := InstallPath(installDir, plugin, version)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create plugin directory: %w", err)
+	}
+
+	partial := target + partialSuffix
+	if IsRemoteModule(version) {
+		if err := download(ctx, version.Wasm, partial, plugin.Name+" "+version.Version, progress); err != nil {
+			return "", fmt.Errorf("failed to download module of plugin %s: %w", plugin.Name, err)
+		}
+	} else if err := copyModule(ModulePath(repoPath, plugin, version), partial); err != nil {
+		return "", fmt.Errorf("failed to copy module of plugin %s: %w", plugin.Name, err)
+	}
+
+	status, err := VerifyModule(plugin, version, partial)
+	if status == ModuleMismatch {
+		// A corrupt download must not be resumed
+		os.Remove(partial)
+	}
+	if err != nil {
+		return "", err
+	}
+	if status == ModuleUnverified {
+		logger.Warn("plugin module has no sha256 checksum, installing it unverified", "plugin", plugin.Name, "version", version.Version)
+	}
+
+	if err := os.Rename(partial, target); err != nil {
+		return "", fmt.Errorf("failed to install module of plugin %s: %w", plugin.Name, err)
+	}
+	return target, nil
+}
+
+// download fetches source into file, appending to what a previous download
+// left in it when the server supports ranges. A previous download the server
+// does not report as complete is started again.
+func download(ctx context.Context, source, file, label string, progress io.Writer) error {
+	var offset int64
+	if info, err := os.Stat(file); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flag = os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		if completeSize(resp) == offset {
+			return nil
+		}
+		// The file is longer than the module, so it is not a part of it
+		resp.Body.Close()
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		return download(ctx, source, file, label, progress)
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+	default:
+		return fmt.Errorf("GET %s: %s", source, resp.Status)
+	}
+
+	out, err := os.OpenFile(file, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader = resp.Body
+	if progress != nil {
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+		bar := &progressBar{out: progress, label: label, done: offset, total: total}
+		defer bar.finish()
+		body = io.TeeReader(resp.Body, bar)
+	}
+
+	if _, err := io.Copy(out, body); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// completeSize returns the size of the file a 416 response gives in its
+// Content-Range header, such as bytes */1024, or -1 without one
+func completeSize(resp *http.Response) int64 {
+	size, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	if !ok {
+		return -1
+	}
+	parsed, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return parsed
+}
+
+// copyModule copies the module of the repository at src to dst
+func copyModule(src, dst string) error {
+	in, err := os.Open(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("module %s does not exist", src)
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// progressBar draws the progress of a download on a single terminal line
+type progressBar struct {
+	out   io.Writer
+	label string
+	done  int64
+	// total is -1 when the server does not tell the size
+	total int64
+}
+
+func (p *progressBar) Write(data []byte) (int, error) {
+	p.done += int64(len(data))
+	if p.total > 0 {
+		const width = 30
+		filled := int(p.done * width / p.total)
+		fmt.Fprintf(p.out, "\rDownloading %s [%s%s] %3d%% of %s", p.label,
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), p.done*100/p.total, formatSize(p.total))
+	} else {
+		fmt.Fprintf(p.out, "\rDownloading %s: %s", p.label, formatSize(p.done))
+	}
+	return len(data), nil
+}
+
+// finish ends the line of the bar
+func (p *progressBar) finish() {
+	fmt.Fprintln(p.out)
+}
+
+// formatSize formats a number of bytes for humans, such as 2.5MiB
+func formatSize(bytes int64) string {
+	size, unit := float64(bytes), "B"
+	for _, next := range []string{"KiB", "MiB", "GiB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, next
+	}
+	if unit == "B" {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	return fmt.Sprintf("%.1f%s", size, unit)
+}
+
+// Uninstall removes the installed module of a plugin version, with the
+// directory of the plugin once it has no version left
+func Uninstall(installDir string, plugin Plugin, version string) error {
+	pluginDir := filepath.Join(installDir, plugin.UUID)
+	if err := os.RemoveAll(filepath.Join(pluginDir, version)); err != nil {
+		return fmt.Errorf("failed to uninstall plugin %s version %s: %w", plugin.Name, version, err)
+	}
+	if entries, err := os.ReadDir(pluginDir); err == nil && len(entries) == 0 {
+		if err := os.Remove(pluginDir); err != nil {
+			return fmt.Errorf("failed to uninstall plugin %s: %w", plugin.Name, err)
+		}
+	}
+	return nil
+}
+
+// dropUninstalled removes the plugins that are not installed from a parsed
+// plugins.yml when settings.OnlyInstalled is set
+func dropUninstalled(config *PluginConfig, settings *Settings) {
+	if !settings.OnlyInstalled {
+		return
+	}
+	config.Plugins = slices.DeleteFunc(config.Plugins, func(plugin Plugin) bool {
+		_, installed := settings.Installed[plugin.UUID]
+		return !installed
+	})
+}
@@ -0,0 +1,47 @@
+package plugins
+
+import "fmt"
+
+const (
+	// CurrentLayoutVersion is the registry layout written by current registries:
+	// plugins.yml at the root and plugin configs under <uuid>/<version>/<conf>
+	CurrentLayoutVersion = 2
+	// MinLayoutVersion is the oldest layout still read through a compatibility shim
+	MinLayoutVersion = CurrentLayoutVersion - 1
+	// legacyLayoutVersion is assumed for registries that predate layout_version
+	legacyLayoutVersion = 1
+)
+
+// LayoutError is returned when the registry layout version is not supported
+type LayoutError struct {
+	Version int
+}
+
+func (e *LayoutError) Error() string {
+	if e.Version > CurrentLayoutVersion {
+		return fmt.Sprintf("registry layout version %d is newer than this wpcli supports (%d-%d); upgrade wpcli to a release that supports layout version %d",
+			e.Version, MinLayoutVersion, CurrentLayoutVersion, e.Version)
+	}
+	return fmt.Sprintf("registry layout version %d is too old, this wpcli supports layout versions %d-%d; the registry needs to be migrated",
+		e.Version, MinLayoutVersion, CurrentLayoutVersion)
+}
+
+// checkLayout validates the layout version of a parsed plugins.yml and
+// upgrades older supported layouts to the current one
+func checkLayout(config *PluginConfig) error {
+	if config.LayoutVersion == 0 {
+		config.LayoutVersion = legacyLayoutVersion
+	}
+
+	if config.LayoutVersion < MinLayoutVersion || config.LayoutVersion > CurrentLayoutVersion {
+		return &LayoutError{Version: config.LayoutVersion}
+	}
+
+	// Layout 1 has the same structure as layout 2, which only adds the
+	// layout_version field itself
+	if config.LayoutVersion == 1 {
+		config.LayoutVersion = 2
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+)
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 << 10
+
+// EffectiveMemoryLimit returns the memory limit of the modules of a plugin:
+// the one of its configuration, else the one of its catalog entry, else the
+// plugin_memory_limit setting. An empty limit means no limit.
+func EffectiveMemoryLimit(plugin Plugin, pluginConfig *Plugin, settings *Settings) string {
+	if pluginConfig != nil && pluginConfig.MemoryLimit != "" {
+		return pluginConfig.MemoryLimit
+	}
+	if plugin.MemoryLimit != "" {
+		return plugin.MemoryLimit
+	}
+	if settings != nil {
+		return settings.PluginMemoryLimit
+	}
+	return ""
+}
+
+// ParseMemoryLimit parses a memory limit such as 64MiB into a number of
+// bytes, rounded up to whole memory pages. An empty limit is 0, no limit.
+func ParseMemoryLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+
+	bytes, err := flags.ParseBytes(limit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit: %w", err)
+	}
+	if bytes <= 0 {
+		return 0, fmt.Errorf("invalid memory limit '%s': must be positive", limit)
+	}
+	if bytes > 1<<32 {
+		return 0, fmt.Errorf("invalid memory limit '%s': modules cannot use more than 4GiB", limit)
+	}
+	return (bytes + pageSize - 1) / pageSize * pageSize, nil
+}
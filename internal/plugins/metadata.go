@@ -0,0 +1,59 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetadataFilter selects plugins by a value of their metadata, such as
+// metadata.category=database
+type MetadataFilter struct {
+	// Path holds the keys leading to the value in nested metadata maps
+	Path  []string
+	Value string
+}
+
+// ParseMetadataFilter parses a filter written as metadata.<key>=<value>, with
+// the keys of nested maps separated by dots
+func ParseMetadataFilter(filter string) (MetadataFilter, error) {
+	key, value, ok := strings.Cut(filter, "=")
+	path, isMetadata := strings.CutPrefix(key, "metadata.")
+	if !ok || !isMetadata || path == "" {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %s, filters are written as metadata.<key>=<value>", filter)
+	}
+	return MetadataFilter{Path: strings.Split(path, "."), Value: value}, nil
+}
+
+// Match reports whether the metadata of a plugin has the value of the filter
+// at its path. Lists match when one of their items does.
+func (f MetadataFilter) Match(plugin Plugin) bool {
+	value, ok := plugin.MetadataValue(f.Path...)
+	if !ok {
+		return false
+	}
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if fmt.Sprint(item) == f.Value {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(value) == f.Value
+}
+
+// MetadataValue returns the value of the plugin metadata at a path of keys
+// of nested maps
+func (p Plugin) MetadataValue(path ...string) (interface{}, bool) {
+	var value interface{} = p.Metadata
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
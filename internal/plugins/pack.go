@@ -0,0 +1,200 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Release is a version of a plugin packed from a plugin directory
+type Release struct {
+	Plugin Plugin
+	// Version is the catalog entry of the release, with the file names of
+	// the configuration and module and the checksum of the module
+	Version Version
+	// Dir is the plugin directory the files come from
+	Dir string
+}
+
+// NewRelease reads the plugin of a plugin directory, see GetDevPluginCommands,
+// for a release of the given version, or of the version of its configuration
+// when version is empty. The module must be built.
+func NewRelease(logger *slog.Logger, dir, version string, reserved Reserved, strict bool) (*Release, error) {
+	plugin, pluginConfig, err := readDevPlugin(logger, dir, reserved, strict)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = pluginConfig.Version
+	}
+	if version == "" {
+		return nil, fmt.Errorf("no version to pack, set version in %s or give --version", plugin.Versions[0].Conf)
+	}
+	if _, err := parseSemver(version); err != nil {
+		return nil, err
+	}
+
+	module := plugin.Versions[0].Wasm
+	if module == "" {
+		return nil, fmt.Errorf("plugin directory %s has no module, build the .wasm file first", dir)
+	}
+	sum, err := FileSHA256(module)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Release{
+		Plugin:  plugin,
+		Version: Version{Version: version, Conf: plugin.Versions[0].Conf, Wasm: filepath.Base(module), SHA256: sum},
+		Dir:     dir,
+	}, nil
+}
+
+// ArchiveName returns the file name of the archive of the release
+func (r *Release) ArchiveName() string {
+	return fmt.Sprintf("%s-%s.tar.gz", r.Plugin.Name, r.Version.Version)
+}
+
+// WriteArchive writes a gzipped tar archive with the configuration and the
+// module of the release to path
+func (r *Release) WriteArchive(path string) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for _, name := range []string{r.Version.Conf, r.Version.Wasm} {
+		data, err := os.ReadFile(filepath.Join(r.Dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		if _, err := archive.Write(data); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// CatalogEntry returns the versions entry of the release in plugins.yml,
+// with each line after the first starting with indent
+func (r *Release) CatalogEntry(indent string) string {
+	data, err := yaml.Marshal([]Version{r.Version})
+	if err != nil {
+		// A Version always encodes
+		panic(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	return strings.Join(lines, "\n"+indent) + "\n"
+}
+
+// CheckVersion checks the version of the release against the versions of
+// its plugin in the catalog, which it must be above. A version already in
+// the catalog, or below its latest version, is only allowed when force is set.
+func (r *Release) CheckVersion(versions []Version, force bool) error {
+	if force {
+		return nil
+	}
+	latest := ""
+	for _, version := range versions {
+		if version.Version == r.Version.Version {
+			return fmt.Errorf("version %s of plugin %s is already in the catalog, use --force to replace it", r.Version.Version, r.Plugin.Name)
+		}
+		if latest == "" || CompareVersions(version.Version, latest) > 0 {
+			latest = version.Version
+		}
+	}
+	if latest != "" && CompareVersions(r.Version.Version, latest) < 0 {
+		return fmt.Errorf("version %s of plugin %s is not bumped from %s in the catalog, give a higher one with --version or use --force", r.Version.Version, r.Plugin.Name, latest)
+	}
+	return nil
+}
+
+// AddToCatalog returns the contents of plugins.yml, read from file, with the
+// release added first to the versions of its plugin, found by UUID or else by
+// name. The rest of the file is kept as is. The version is checked with
+// CheckVersion first.
+func (r *Release) AddToCatalog(file string, data []byte, force bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, newParseError(file, data, nil, err)
+	}
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+
+	var versions *yaml.Node
+	for _, node := range sequence(mappingValue(root, "plugins")) {
+		uuid, name := mappingValue(node, "uuid"), mappingValue(node, "name")
+		if (uuid != nil && uuid.Value == r.Plugin.UUID) || (name != nil && name.Value == r.Plugin.Name) {
+			versions = mappingValue(node, "versions")
+			break
+		}
+	}
+	items := sequence(versions)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("plugin %s has no versions in the catalog to add %s to, add its entry first", r.Plugin.Name, r.Version.Version)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	dash := strings.Index(lines[items[0].Line-1], "-")
+	if versions.Style&yaml.FlowStyle != 0 || dash < 0 {
+		return nil, fmt.Errorf("the versions of plugin %s in %s are not a block sequence, add %s by hand", r.Plugin.Name, file, r.Version.Version)
+	}
+	indent := lines[items[0].Line-1][:dash]
+
+	var existing []Version
+	for _, item := range items {
+		if value := mappingValue(item, "version"); value != nil {
+			existing = append(existing, Version{Version: value.Value})
+		}
+	}
+	if err := r.CheckVersion(existing, force); err != nil {
+		return nil, err
+	}
+
+	// Items span from their line to the line before the next one; the last
+	// one ends with its last value
+	start, end := items[0].Line-1, items[0].Line-1
+	for i, item := range items {
+		if value := mappingValue(item, "version"); value == nil || value.Value != r.Version.Version {
+			continue
+		}
+		start, end = item.Line-1, lastLine(item)
+		if i+1 < len(items) {
+			end = items[i+1].Line - 1
+		}
+		break
+	}
+
+	entry := indent + r.CatalogEntry(indent)
+	updated := strings.Join(lines[:start], "") + entry + strings.Join(lines[end:], "")
+	return []byte(updated), nil
+}
+
+// lastLine returns the last line a node and its children are on
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
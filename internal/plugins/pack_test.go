@@ -0,0 +1,40 @@
+package plugins
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseCheckVersion(t *testing.T) {
+	catalog := []Version{{Version: "1.0.0"}, {Version: "1.2.0"}, {Version: "1.1.0"}}
+	tests := []struct {
+		version string
+		force   bool
+		wantErr string
+	}{
+		{version: "1.3.0"},
+		{version: "2.0.0"},
+		{version: "1.2.0", wantErr: "version 1.2.0 of plugin hello is already in the catalog, use --force to replace it"},
+		{version: "1.1.0", wantErr: "version 1.1.0 of plugin hello is already in the catalog"},
+		{version: "1.1.5", wantErr: "version 1.1.5 of plugin hello is not bumped from 1.2.0 in the catalog, give a higher one with --version or use --force"},
+		{version: "1.2.0-rc.1", wantErr: "is not bumped from 1.2.0"},
+		{version: "1.2.0", force: true},
+		{version: "0.1.0", force: true},
+	}
+
+	for _, test := range tests {
+		release := &Release{Plugin: Plugin{Name: "hello"}, Version: Version{Version: test.version}}
+		err := release.CheckVersion(catalog, test.force)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s (force %v): unexpected error: %v", test.version, test.force, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s (force %v): error %v, want one containing %q", test.version, test.force, err, test.wantErr)
+		}
+	}
+
+	// The first release of a plugin has nothing to be above
+	if err := (&Release{Plugin: Plugin{Name: "hello"}, Version: Version{Version: "0.1.0"}}).CheckVersion(nil, false); err != nil {
+		t.Errorf("first release: %v", err)
+	}
+}
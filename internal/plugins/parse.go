@@ -0,0 +1,297 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is a YAML file that does not parse, located at the line and
+// column of the problem, with the command and flag the problem is in
+type ParseError struct {
+	File string
+	Line int
+	// Column is 0 when yaml only tells the line
+	Column int
+	// Context names the plugin, command and flag the problem is in, such as
+	// "command greet, flag format"
+	Context string
+	Message string
+	// Snippet shows the lines around the problem
+	Snippet string
+}
+
+func (e *ParseError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			location += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+
+	message := fmt.Sprintf("failed to parse %s: %s", location, e.Message)
+	if e.Context != "" {
+		message += fmt.Sprintf(" (in %s)", e.Context)
+	}
+	if e.Snippet != "" {
+		message += "\n" + e.Snippet
+	}
+	return message
+}
+
+// warnedFields records the unknown fields already reported, since the
+// catalog is parsed more than once per run
+var warnedFields sync.Map
+
+// decodeYAML decodes the YAML document data of file into out. Unknown fields
+// are reported as warnings, or as a *ParseError when strict is set, and so
+// are syntax and type errors.
+func decodeYAML(logger *slog.Logger, file string, data []byte, out interface{}, strict bool) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return newParseError(file, data, nil, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	for _, field := range unknownFields(&doc, reflect.TypeOf(out)) {
+		context, _ := locate(&doc, field.Line)
+		if strict {
+			return &ParseError{
+				File:    file,
+				Line:    field.Line,
+				Column:  field.Column,
+				Context: context,
+				Message: fmt.Sprintf("unknown field %s", field.Value),
+				Snippet: snippet(data, field.Line, field.Column),
+			}
+		}
+
+		key := fmt.Sprintf("%s:%d:%d", file, field.Line, field.Column)
+		if _, warned := warnedFields.LoadOrStore(key, true); !warned {
+			logger.Warn("unknown field in YAML file, ignoring it", "file", file, "line", field.Line, "field", field.Value, "in", context)
+		}
+	}
+
+	if err := doc.Decode(out); err != nil {
+		return newParseError(file, data, &doc, err)
+	}
+	return nil
+}
+
+// yamlErrorLine matches the line yaml errors start with
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// newParseError locates a yaml error. The document is nil for syntax
+// errors, whose context comes from the lines that precede the problem.
+func newParseError(file string, data []byte, doc *yaml.Node, err error) *ParseError {
+	message := err.Error()
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		message = typeErr.Errors[0]
+		if more := len(typeErr.Errors) - 1; more > 0 {
+			message += fmt.Sprintf(" (and %d more)", more)
+		}
+	}
+
+	parseErr := &ParseError{File: file, Message: strings.TrimPrefix(message, "yaml: ")}
+	match := yamlErrorLine.FindStringSubmatch(message)
+	if match == nil {
+		return parseErr
+	}
+	parseErr.Line, _ = strconv.Atoi(match[1])
+	parseErr.Message = match[2]
+
+	lines := strings.Split(string(data), "\n")
+	if doc == nil {
+		// The lines before the problem usually parse
+		var prefix yaml.Node
+		if parseErr.Line > 0 && parseErr.Line <= len(lines) && yaml.Unmarshal([]byte(strings.Join(lines[:parseErr.Line-1], "\n")), &prefix) == nil {
+			doc = &prefix
+		}
+	}
+	var node *yaml.Node
+	if doc != nil {
+		parseErr.Context, node = locate(doc, parseErr.Line)
+	}
+	if node != nil {
+		parseErr.Column = node.Column
+	} else if parseErr.Line > 0 && parseErr.Line <= len(lines) {
+		// The first character of the line
+		text := lines[parseErr.Line-1]
+		parseErr.Column = len(text) - len(strings.TrimLeft(text, " \t")) + 1
+	}
+
+	parseErr.Snippet = snippet(data, parseErr.Line, parseErr.Column)
+	return parseErr
+}
+
+// locate walks a document down to a line, returning the plugins, commands
+// and flags the line is in, such as "command greet, flag format", and the node
+// of the line when it is part of the document
+func locate(node *yaml.Node, line int) (string, *yaml.Node) {
+	var names []string
+	var found *yaml.Node
+
+	var walk func(node *yaml.Node, kind string)
+	walk = func(node *yaml.Node, kind string) {
+		if found == nil && node.Line == line {
+			found = node
+		}
+
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) > 0 {
+				walk(node.Content[0], "")
+			}
+		case yaml.SequenceNode:
+			// The item the line is in is the last one starting before it
+			var item *yaml.Node
+			for _, child := range node.Content {
+				if child.Line <= line {
+					item = child
+				}
+			}
+			if item != nil {
+				walk(item, kind)
+			}
+		case yaml.MappingNode:
+			if kind != "" {
+				if name := mappingValue(node, "name"); name != nil && name.Value != "" {
+					names = append(names, kind+" "+name.Value)
+				}
+			}
+
+			var key, value *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Line <= line {
+					key, value = node.Content[i], node.Content[i+1]
+				}
+			}
+			if key == nil {
+				return
+			}
+			// A scalar value is what a type error is about
+			if found == nil && value.Line == line && value.Kind == yaml.ScalarNode {
+				found = value
+			}
+			if found == nil && key.Line == line {
+				found = key
+			}
+
+			switch key.Value {
+			case "plugins":
+				walk(value, "plugin")
+			case "commands":
+				walk(value, "command")
+			case "flags":
+				walk(value, "flag")
+			default:
+				walk(value, "")
+			}
+		}
+	}
+	walk(node, "")
+
+	return strings.Join(names, ", "), found
+}
+
+// snippet shows the line of a problem with the lines before and after it,
+// pointing at its column when known
+func snippet(data []byte, line, column int) string {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	var b strings.Builder
+	for n := line - 1; n <= line+1; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %4d | %s\n", marker, n, lines[n-1])
+		if n == line && column > 0 {
+			fmt.Fprintf(&b, "       | %s^\n", strings.Repeat(" ", column-1))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// unknownFields returns the key nodes of the mappings of a document that t, the
+// type the document is decoded into, has no field for. Nodes whose kind does
+// not match the type, such as the values accepted by custom unmarshalers,
+// are not checked.
+func unknownFields(node *yaml.Node, t reflect.Type) []*yaml.Node {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var unknown []*yaml.Node
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			unknown = append(unknown, unknownFields(child, t)...)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for _, child := range node.Content {
+				unknown = append(unknown, unknownFields(child, t.Elem())...)
+			}
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 1; i < len(node.Content); i += 2 {
+				unknown = append(unknown, unknownFields(node.Content[i], t.Elem())...)
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				fieldType, ok := fields[node.Content[i].Value]
+				if !ok {
+					unknown = append(unknown, node.Content[i])
+					continue
+				}
+				unknown = append(unknown, unknownFields(node.Content[i+1], fieldType)...)
+			}
+		}
+	}
+	return unknown
+}
+
+// yamlFields returns the types of the fields of a struct by their yaml key
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
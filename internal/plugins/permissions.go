@@ -0,0 +1,228 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/consent"
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Permissions are the host resources a plugin version requests: the
+// environment variables it reads, the host paths it reads and writes, and
+// the network hosts it connects to. Paths are written as the host_path of
+// mounts, before expansion.
+type Permissions struct {
+	Env     []string `yaml:"env,omitempty" json:"env,omitempty"`
+	Read    []string `yaml:"read,omitempty" json:"read,omitempty"`
+	Write   []string `yaml:"write,omitempty" json:"write,omitempty"`
+	Network []string `yaml:"network,omitempty" json:"network,omitempty"`
+}
+
+// RequestedPermissions returns the permissions a plugin version requests:
+// the ones of its permissions block, and the environment variables and
+// mounts of its commands, so that none escapes the consent of the user. The
+// lists are sorted, and paths written to are not listed as read too.
+func RequestedPermissions(pluginConfig *Plugin) Permissions {
+	var p Permissions
+	if pluginConfig == nil {
+		return p
+	}
+	if pluginConfig.Permissions != nil {
+		p = Permissions{
+			Env:     slices.Clone(pluginConfig.Permissions.Env),
+			Read:    slices.Clone(pluginConfig.Permissions.Read),
+			Write:   slices.Clone(pluginConfig.Permissions.Write),
+			Network: slices.Clone(pluginConfig.Permissions.Network),
+		}
+	}
+	for _, cmdConfig := range pluginConfig.Commands {
+		for _, variable := range cmdConfig.Env {
+			p.Env = append(p.Env, variable.Name)
+		}
+		for _, mount := range cmdConfig.Mounts {
+			if mount.ReadOnly {
+				p.Read = append(p.Read, mount.HostPath)
+			} else {
+				p.Write = append(p.Write, mount.HostPath)
+			}
+		}
+	}
+
+	p.Env = sortedSet(p.Env)
+	p.Write = sortedSet(p.Write)
+	p.Read = slices.DeleteFunc(sortedSet(p.Read), func(path string) bool { return slices.Contains(p.Write, path) })
+	p.Network = sortedSet(p.Network)
+	return p
+}
+
+// sortedSet sorts values and drops the duplicate and blank ones
+func sortedSet(values []string) []string {
+	values = slices.DeleteFunc(values, func(value string) bool { return strings.TrimSpace(value) == "" })
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+// IsEmpty reports whether no permission is requested
+func (p Permissions) IsEmpty() bool {
+	return len(p.Env) == 0 && len(p.Read) == 0 && len(p.Write) == 0 && len(p.Network) == 0
+}
+
+// Hash identifies a set of permissions, see RequestedPermissions
+func (p Permissions) Hash() string {
+	// Sorted lists of strings always encode
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Describe writes the permissions one kind per line, each line starting
+// with indent
+func (p Permissions) Describe(w io.Writer, indent string) {
+	for _, kind := range []struct {
+		label  string
+		values []string
+	}{
+		{"Environment variables", p.Env},
+		{"Read access", p.Read},
+		{"Write access", p.Write},
+		{"Network access", p.Network},
+	} {
+		if len(kind.values) > 0 {
+			fmt.Fprintf(w, "%s%s: %s\n", indent, kind.label, strings.Join(kind.values, ", "))
+		}
+	}
+}
+
+// checkPermissions rejects permissions with blank entries
+func checkPermissions(p *Permissions) error {
+	if p == nil {
+		return nil
+	}
+	if err := checkEnv(envVars(p.Env)); err != nil {
+		return fmt.Errorf("permissions: %w", err)
+	}
+	for _, list := range [][]string{p.Read, p.Write, p.Network} {
+		if slices.ContainsFunc(list, func(value string) bool { return strings.TrimSpace(value) == "" }) {
+			return fmt.Errorf("permissions: empty entry")
+		}
+	}
+	return nil
+}
+
+// envVars returns environment variables of the given names
+func envVars(names []string) []EnvVar {
+	vars := make([]EnvVar, 0, len(names))
+	for _, name := range names {
+		vars = append(vars, EnvVar{Name: name})
+	}
+	return vars
+}
+
+// checkConsent makes sure the user granted the permissions of a plugin
+// version before its module gets any of them. A missing or outdated grant
+// is asked for in a terminal, and given without asking with the --yes flag
+// of the root command. Grants are saved to the consent file at path.
+// Policies not asking for consent grant the permissions without asking.
+func checkConsent(logger *slog.Logger, cmd *cobra.Command, plugin Plugin, version Version, path string, policy Policy) error {
+	if plugin.Permissions == nil || plugin.Permissions.IsEmpty() {
+		return nil
+	}
+	if !policy.AskConsent {
+		logger.Info("permissions granted by the trust level", "plugin", plugin.Name, "version", version.Version, "trust", policy.Trust)
+		return nil
+	}
+	permissions := *plugin.Permissions
+	hash := permissions.Hash()
+
+	grants, err := consent.Load(path)
+	if err != nil {
+		return err
+	}
+	if grants.Granted(plugin.UUID, version.Version, hash) {
+		return nil
+	}
+	_, changed := grants.Lookup(plugin.UUID, version.Version)
+
+	if !autoApproved(cmd) {
+		in, ok := interactiveInput(cmd)
+		if !ok {
+			reason := "were not granted"
+			if changed {
+				reason = "changed since they were granted"
+			}
+			return fmt.Errorf("the permissions of plugin %s %s %s; run the command in a terminal to review them, or pass --yes to grant them (see 'wpcli plugin permissions %s')", plugin.Name, version.Version, reason, plugin.Name)
+		}
+
+		stderr := cmd.ErrOrStderr()
+		if changed {
+			fmt.Fprintf(stderr, "The permissions of plugin %s %s changed since they were granted.\n", plugin.Name, version.Version)
+		}
+		fmt.Fprintf(stderr, "Plugin %s %s requests:\n", plugin.Name, version.Version)
+		permissions.Describe(stderr, "  ")
+		fmt.Fprint(stderr, "Allow? [y/N] ")
+
+		answer, err := flags.ReadLine(in)
+		fmt.Fprintln(stderr)
+		if err != nil || !isYes(answer) {
+			return fmt.Errorf("plugin %s was denied its permissions", plugin.Name)
+		}
+	}
+
+	grants.Grant(consent.Grant{
+		Plugin:    plugin.Name,
+		UUID:      plugin.UUID,
+		Version:   version.Version,
+		Hash:      hash,
+		GrantedAt: time.Now().UTC(),
+	})
+	// The module runs anyway, the user is asked again next time
+	if err := grants.Save(); err != nil {
+		logger.Warn("failed to save the permissions granted", "plugin", plugin.Name, "error", err)
+	}
+	return nil
+}
+
+// autoApproved reports whether the --yes flag of the root command, or the
+// deprecated --allow-mount flag, is set
+func autoApproved(cmd *cobra.Command) bool {
+	for _, name := range []string{"yes", "allow-mount"} {
+		if granted, err := cmd.Flags().GetBool(name); err == nil && granted {
+			return true
+		}
+	}
+	return false
+}
+
+// isYes reports whether an answer to a confirmation is yes
+func isYes(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// interactiveInput returns the terminal the user answers prompts from. There
+// is none when stdin is not a terminal, or the --non-interactive flag of the
+// root command is set.
+func interactiveInput(cmd *cobra.Command) (*os.File, bool) {
+	if nonInteractive, err := cmd.Flags().GetBool("non-interactive"); err == nil && nonInteractive {
+		return nil, false
+	}
+	in, ok := cmd.InOrStdin().(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return nil, false
+	}
+	return in, true
+}
@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// uuidPattern matches directory names that look like plugin UUIDs
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FindOrphans returns the plugin directories under repoPath that the catalog
+// no longer references: <uuid> directories of removed plugins and
+// <uuid>/<version> directories of removed versions. Unknown top level
+// directories are only considered when their name looks like a UUID.
+func FindOrphans(repoPath string, plugins []Plugin) ([]string, error) {
+	versions := make(map[string]map[string]bool)
+	for _, plugin := range plugins {
+		if versions[plugin.UUID] == nil {
+			versions[plugin.UUID] = make(map[string]bool)
+		}
+		for _, version := range plugin.Versions {
+			versions[plugin.UUID][version.Version] = true
+		}
+	}
+
+	entries, err := os.ReadDir(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository directory: %w", err)
+	}
+
+	var orphans []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		known, ok := versions[entry.Name()]
+		if !ok {
+			if uuidPattern.MatchString(entry.Name()) {
+				orphans = append(orphans, filepath.Join(repoPath, entry.Name()))
+			}
+			continue
+		}
+
+		versionEntries, err := os.ReadDir(filepath.Join(repoPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+		}
+		for _, versionEntry := range versionEntries {
+			if versionEntry.IsDir() && !known[versionEntry.Name()] {
+				orphans = append(orphans, filepath.Join(repoPath, entry.Name(), versionEntry.Name()))
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// RemoveOrphans deletes the given directories, refusing any path that is not
+// strictly inside repoPath
+func RemoveOrphans(repoPath string, orphans []string) error {
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	for _, orphan := range orphans {
+		path, err := filepath.Abs(orphan)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to delete %s: not inside %s", orphan, repoPath)
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", orphan, err)
+		}
+	}
+
+	return nil
+}
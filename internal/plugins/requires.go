@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/version"
+)
+
+// constraint is a parsed requires_wpcli constraint: the versions from min,
+// included or not, up to max excluded when set
+type constraint struct {
+	min         semver
+	minExcluded bool
+	max         *semver
+	exact       bool
+}
+
+// parseConstraint parses a version constraint:
+//
+//	>=1.2.3  1.2.3 or later
+//	>1.2.3   later than 1.2.3
+//	=1.2.3   exactly 1.2.3, as is 1.2.3 without an operator
+//	~1.2.3   1.2.3 or a later patch release of 1.2, like ~1.2
+//	~1       1.0.0 or a later release of 1
+func parseConstraint(s string) (constraint, error) {
+	s = strings.TrimSpace(s)
+	var c constraint
+	var operand string
+	switch {
+	case strings.HasPrefix(s, ">="):
+		operand = s[2:]
+	case strings.HasPrefix(s, ">"):
+		operand, c.minExcluded = s[1:], true
+	case strings.HasPrefix(s, "="):
+		operand, c.exact = s[1:], true
+	case strings.HasPrefix(s, "~"):
+		operand = s[1:]
+	default:
+		operand, c.exact = s, true
+	}
+	if strings.TrimSpace(operand) == "" {
+		return constraint{}, fmt.Errorf("invalid version constraint %q: no version, constraints are written as >=, >, =, or ~ followed by a version", s)
+	}
+
+	v, err := parseSemver(operand)
+	if err != nil {
+		return constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+	}
+	c.min = v
+
+	// The tilde allows the releases up to the next minor version, or the
+	// next major version when only the major number is given
+	if strings.HasPrefix(s, "~") {
+		max := semver{major: v.major, minor: v.minor + 1}
+		if !strings.Contains(strings.TrimSpace(operand), ".") {
+			max = semver{major: v.major + 1}
+		}
+		c.max = &max
+	}
+	return c, nil
+}
+
+// allows reports whether a version satisfies the constraint
+func (c constraint) allows(v semver) bool {
+	cmp := v.compare(c.min)
+	switch {
+	case c.exact:
+		return cmp == 0
+	case cmp < 0 || (cmp == 0 && c.minExcluded):
+		return false
+	case c.max != nil:
+		return v.compare(*c.max) < 0
+	}
+	return true
+}
+
+// EffectiveRequiresWpcli returns the wpcli version constraint of a plugin:
+// the one of its configuration, else the one of its catalog entry
+func EffectiveRequiresWpcli(plugin Plugin, pluginConfig *Plugin) string {
+	if pluginConfig != nil && pluginConfig.RequiresWpcli != "" {
+		return pluginConfig.RequiresWpcli
+	}
+	return plugin.RequiresWpcli
+}
+
+// CheckRequiresWpcli returns an error when the running wpcli does not
+// satisfy the requires_wpcli constraint of a plugin. Development builds,
+// without a release version, satisfy every constraint.
+func CheckRequiresWpcli(logger *slog.Logger, plugin Plugin, pluginConfig *Plugin) error {
+	requires := EffectiveRequiresWpcli(plugin, pluginConfig)
+	if requires == "" {
+		return nil
+	}
+	c, err := parseConstraint(requires)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", plugin.Name, err)
+	}
+
+	current := version.Current()
+	v, err := parseSemver(current)
+	if err != nil {
+		logger.Debug("not checking the wpcli version constraint of a development build", "plugin", plugin.Name, "requires_wpcli", requires, "version", current)
+		return nil
+	}
+	if !c.allows(v) {
+		return fmt.Errorf("plugin %s requires wpcli %s, this is wpcli %s", plugin.Name, requires, current)
+	}
+	return nil
+}
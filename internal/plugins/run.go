@@ -0,0 +1,372 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/runtime"
+	"github.com/ploffredi/wpcli/internal/version"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// Protocols of plugin commands, telling how a command hands its arguments
+// and flags to the plugin module
+const (
+	// ProtocolArgv passes the command name, the arguments and the flags with
+	// a value as --name=value arguments of the module. It is the default.
+	ProtocolArgv = "argv"
+	// ProtocolJSONStdin writes an invocationPayload to the standard input
+	// of the module, which gets the command name as its only argument
+	ProtocolJSONStdin = "json-stdin"
+)
+
+// Formats of the results modules report, see runtime.Result
+const (
+	ResultFormatText = "text"
+	ResultFormatJSON = "json"
+)
+
+// Versions of the invocation payload, which plugins choose with the
+// payload_version of their configuration
+const (
+	// PayloadVersion1 gives each flag as its typed value. It is the default.
+	PayloadVersion1 = 1
+	// PayloadVersion2 gives each flag as a payloadFlag, which also tells
+	// where the value comes from
+	PayloadVersion2 = 2
+)
+
+// invocationPayload is the document modules using ProtocolJSONStdin read
+// from their standard input. Flags are keyed by the name declared by the
+// plugin and keep their type; durations are given as strings such as 1m30s.
+// The version is only given from PayloadVersion2 on.
+type invocationPayload struct {
+	PayloadVersion int                    `json:"payload_version,omitempty"`
+	Command        string                 `json:"command"`
+	Args           []string               `json:"args"`
+	Flags          map[string]interface{} `json:"flags"`
+	Language       string                 `json:"language"`
+	WpcliVersion   string                 `json:"wpcli_version"`
+}
+
+// payloadFlag is a flag of the payload from PayloadVersion2 on: its value,
+// its source, one of the flags.Source constants, and whether the value
+// differs from the default declared by the plugin
+type payloadFlag struct {
+	Value   interface{} `json:"value"`
+	Source  string      `json:"source"`
+	Changed bool        `json:"changed"`
+}
+
+// checkProtocol rejects protocols wpcli does not know
+func checkProtocol(protocol string) error {
+	switch protocol {
+	case "", ProtocolArgv, ProtocolJSONStdin:
+		return nil
+	default:
+		return fmt.Errorf("unknown protocol %s, supported protocols are %s and %s", protocol, ProtocolArgv, ProtocolJSONStdin)
+	}
+}
+
+// checkPayloadVersion rejects payload versions wpcli does not know, such as
+// those of plugins written for a newer wpcli
+func checkPayloadVersion(payloadVersion int) error {
+	switch payloadVersion {
+	case 0, PayloadVersion1, PayloadVersion2:
+		return nil
+	default:
+		return fmt.Errorf("unsupported payload_version %d, supported versions are %d and %d", payloadVersion, PayloadVersion1, PayloadVersion2)
+	}
+}
+
+// runModule runs a plugin command in the wasm module of the plugin version,
+// handing it the arguments and flags with the protocol of the command.
+// Modules receive the values of sensitive flags unmasked. Commands getting
+// environment variables or mounts need the permissions of the plugin
+// version granted, see checkConsent, as the policy of the plugin demands.
+func runModule(logger *slog.Logger, cmd *cobra.Command, plugin Plugin, pluginVersion Version, cmdConfig PluginCommandConfig, args []string, flagList []*flags.Flag, language, consentPath string, policy Policy) error {
+	format, err := resultFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Missing variables fail the command before the module is loaded
+	env, err := lookupEnv(plugin, cmdConfig)
+	if err != nil {
+		return err
+	}
+	mounts, err := expandMounts(plugin, cmdConfig.Mounts)
+	if err != nil {
+		return err
+	}
+	if err := policy.checkNetwork(plugin); err != nil {
+		return err
+	}
+	if len(cmdConfig.Env) > 0 || len(cmdConfig.Mounts) > 0 {
+		if err := checkConsent(logger, cmd, plugin, pluginVersion, consentPath, policy); err != nil {
+			return err
+		}
+	}
+
+	argv := []string{cmdConfig.Name}
+	stdin := cmd.InOrStdin()
+
+	if cmdConfig.Protocol == ProtocolJSONStdin {
+		payload, err := newInvocationPayload(cmd, plugin.PayloadVersion, cmdConfig.Name, args, flagList, language)
+		if err != nil {
+			return err
+		}
+		stdin = bytes.NewReader(payload)
+	} else {
+		values, err := flags.GetFlagValues(cmd, flagList)
+		if err != nil {
+			return err
+		}
+
+		argv = append(argv, args...)
+		for _, flag := range flagList {
+			if value := values[flag.Name]; value != "" {
+				argv = append(argv, "--"+flags.NormalizeFlagName(flag.Name)+"="+value)
+			}
+		}
+	}
+
+	// The --timeout flag of the root command, which takes its default from
+	// the plugin_timeout setting
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	// Validated when the command was built
+	memoryLimit, err := ParseMemoryLimit(plugin.MemoryLimit)
+	if err != nil {
+		return err
+	}
+
+	result, err := runtime.Run(cmd.Context(), runtime.Invocation{
+		Plugin:      plugin.Name,
+		Logger:      logger,
+		Path:        pluginVersion.Wasm,
+		Args:        argv,
+		Env:         env,
+		Mounts:      mounts,
+		Timeout:     timeout,
+		MemoryLimit: memoryLimit,
+		Stdin:       stdin,
+		Stdout:      cmd.OutOrStdout(),
+		Stderr:      cmd.ErrOrStderr(),
+	})
+
+	// The result is shown even when the module failed, since it tells why
+	if result != nil {
+		if renderErr := renderResult(cmd, result, format); renderErr != nil && err == nil {
+			err = renderErr
+		}
+	}
+	var exitErr *runtime.ExitError
+	if errors.As(err, &exitErr) && exitErr.Err == nil && exitErr.Code == runtime.ExitUsage {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+	return err
+}
+
+// resultFormat returns the format of the results modules report, given
+// with the --result-format flag of the root command
+func resultFormat(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("result-format")
+	if err != nil || format == "" {
+		return ResultFormatText, nil
+	}
+	if format != ResultFormatText && format != ResultFormatJSON {
+		return "", fmt.Errorf("invalid value for flag --result-format: %s. Valid values are: %s, %s", format, ResultFormatText, ResultFormatJSON)
+	}
+	return format, nil
+}
+
+// renderResult shows the result a module reported. As text, the message is
+// printed, followed by the data as YAML, and warnings go to stderr. As
+// JSON, the result is printed as a whole.
+func renderResult(cmd *cobra.Command, result *runtime.Result, format string) error {
+	out := cmd.OutOrStdout()
+	if format == ResultFormatJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode the result: %w", err)
+		}
+		return nil
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
+	if result.Message != "" {
+		fmt.Fprintln(out, result.Message)
+	}
+	if result.Data != nil {
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(result.Data); err != nil {
+			return fmt.Errorf("failed to encode the result: %w", err)
+		}
+		return encoder.Close()
+	}
+	return nil
+}
+
+// checkEnv rejects environment variables without a valid name
+func checkEnv(env []EnvVar) error {
+	for _, variable := range env {
+		if variable.Name == "" || strings.ContainsAny(variable.Name, "= ") {
+			return fmt.Errorf("invalid environment variable name '%s'", variable.Name)
+		}
+	}
+	return nil
+}
+
+// lookupEnv returns the host environment variables a command requests that
+// are set, failing when a required one is not
+func lookupEnv(plugin Plugin, cmdConfig PluginCommandConfig) (map[string]string, error) {
+	env := make(map[string]string, len(cmdConfig.Env))
+	for _, variable := range cmdConfig.Env {
+		value, ok := os.LookupEnv(variable.Name)
+		if !ok {
+			if variable.Required {
+				return nil, fmt.Errorf("plugin %s requires the environment variable %s, which is not set", plugin.Name, variable.Name)
+			}
+			continue
+		}
+		env[variable.Name] = value
+	}
+	return env, nil
+}
+
+// checkMounts rejects mounts without a host path or an absolute guest path
+func checkMounts(mounts []Mount) error {
+	for _, mount := range mounts {
+		if strings.TrimSpace(mount.HostPath) == "" {
+			return fmt.Errorf("mount %s has no host_path", mount.GuestPath)
+		}
+		if !path.IsAbs(mount.GuestPath) {
+			return fmt.Errorf("mount %s: guest_path '%s' is not an absolute path", mount.HostPath, mount.GuestPath)
+		}
+	}
+	return nil
+}
+
+// expandMounts expands the host paths of the mounts of a command, checking
+// that they are existing directories
+func expandMounts(plugin Plugin, mounts []Mount) ([]runtime.Mount, error) {
+	resolved := make([]runtime.Mount, 0, len(mounts))
+	for _, mount := range mounts {
+		// An unset variable must not mount the working directory
+		if strings.TrimSpace(os.ExpandEnv(mount.HostPath)) == "" {
+			return nil, fmt.Errorf("plugin %s: host path %s of mount %s is empty", plugin.Name, mount.HostPath, mount.GuestPath)
+		}
+		hostPath, err := flags.ExpandPath(mount.HostPath)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: mount %s: %w", plugin.Name, mount.GuestPath, err)
+		}
+
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: mount %s: %w", plugin.Name, mount.GuestPath, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("plugin %s: mount %s: %s is not a directory", plugin.Name, mount.GuestPath, hostPath)
+		}
+
+		resolved = append(resolved, runtime.Mount{HostPath: hostPath, GuestPath: mount.GuestPath, ReadOnly: mount.ReadOnly})
+	}
+	return resolved, nil
+}
+
+// newPrompter returns the prompter asking for missing required flags, or
+// nil when the user cannot answer: stdin is not a terminal, or the
+// --non-interactive flag of the root command is set
+func newPrompter(cmd *cobra.Command) *flags.Prompter {
+	in, ok := interactiveInput(cmd)
+	if !ok {
+		return nil
+	}
+	return &flags.Prompter{
+		In:  in,
+		Out: cmd.ErrOrStderr(),
+		ReadSecret: func() (string, error) {
+			answer, err := term.ReadPassword(int(in.Fd()))
+			return string(answer), err
+		},
+	}
+}
+
+// payloadFlagValues returns the flags of the payload version keyed by the
+// name declared by the plugin: their typed values, with durations as
+// strings such as 1m30s, and from PayloadVersion2 on where they come from.
+// Sensitive values are masked when mask is set.
+func payloadFlagValues(cmd *cobra.Command, payloadVersion int, flagList []*flags.Flag, mask bool) (map[string]interface{}, error) {
+	values, err := flags.GetTypedFlagValues(cmd, flagList)
+	if err != nil {
+		return nil, err
+	}
+	// Computed before durations become strings, to compare the typed values
+	provenance := flags.GetFlagProvenance(cmd, flagList, values)
+
+	for name, value := range values {
+		if duration, ok := value.(time.Duration); ok {
+			values[name] = duration.String()
+		}
+	}
+	if mask {
+		for _, flag := range flagList {
+			name := flags.NormalizeFlagName(flag.Name)
+			if flag.Sensitive && values[name] != "" {
+				values[name] = flags.MaskedValue
+			}
+		}
+	}
+
+	if payloadVersion < PayloadVersion2 {
+		return values, nil
+	}
+	for name, value := range values {
+		values[name] = payloadFlag{Value: value, Source: provenance[name].Source, Changed: provenance[name].Changed}
+	}
+	return values, nil
+}
+
+// newInvocationPayload builds the JSON document of ProtocolJSONStdin in the
+// payload version of the plugin
+func newInvocationPayload(cmd *cobra.Command, payloadVersion int, cmdName string, args []string, flagList []*flags.Flag, language string) ([]byte, error) {
+	values, err := payloadFlagValues(cmd, payloadVersion, flagList, false)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := invocationPayload{
+		Command:      cmdName,
+		Args:         args,
+		Flags:        values,
+		Language:     language,
+		WpcliVersion: version.Current(),
+	}
+	if payloadVersion >= PayloadVersion2 {
+		payload.PayloadVersion = payloadVersion
+	}
+	if payload.Args == nil {
+		payload.Args = []string{}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the invocation of %s: %w", cmdName, err)
+	}
+	return data, nil
+}
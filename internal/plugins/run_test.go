@@ -0,0 +1,56 @@
+package plugins
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+func TestInvocationPayloadKeepsFlagTypes(t *testing.T) {
+	flagList := []*flags.Flag{
+		{Name: "--count", Type: flags.TypeInt, Default: "1"},
+		{Name: "--upper", Type: flags.TypeBool},
+		{Name: "--wait", Type: flags.TypeDuration, Default: "1m30s"},
+		{Name: "--ports", Type: flags.TypeIntSlice},
+		{Name: "--token", Type: flags.TypeString, Sensitive: true},
+	}
+	cmd := &cobra.Command{Use: "echo-json"}
+	if err := flags.AddFlags(slog.New(slog.DiscardHandler), cmd, flagList, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.ParseFlags([]string{"--count", "3", "--upper", "--ports", "80,443", "--token", "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := newInvocationPayload(cmd, PayloadVersion1, "echo-json", []string{"hello"}, flagList, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Command string                 `json:"command"`
+		Args    []string               `json:"args"`
+		Flags   map[string]interface{} `json:"flags"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"count": float64(3),
+		"upper": true,
+		"wait":  "1m30s",
+		"ports": []interface{}{float64(80), float64(443)},
+		// Modules get sensitive values unmasked
+		"token": "s3cr3t",
+	}
+	if !reflect.DeepEqual(payload.Flags, want) {
+		t.Errorf("flags = %#v, want %#v", payload.Flags, want)
+	}
+	if payload.Command != "echo-json" || !reflect.DeepEqual(payload.Args, []string{"hello"}) {
+		t.Errorf("command = %s, args = %v", payload.Command, payload.Args)
+	}
+}
@@ -0,0 +1,163 @@
+package plugins
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ploffredi/wpcli/internal/i18n"
+)
+
+// Weights of the fields searched, so that matching names rank above
+// matching descriptions
+const (
+	nameWeight               = 4
+	commandNameWeight        = 3
+	descriptionWeight        = 2
+	commandDescriptionWeight = 1
+)
+
+// SearchMatch is a plugin matching a search query, with the field matching
+// it best
+type SearchMatch struct {
+	Plugin string `json:"plugin"`
+	UUID   string `json:"uuid"`
+	Score  int    `json:"score"`
+	// Field names the field that matched, such as "name", "description (it)"
+	// or "command pkg install"
+	Field string `json:"field"`
+	Text  string `json:"text"`
+	// Positions are the indexes of the characters, not bytes, of Text
+	// matching the query
+	Positions []int `json:"positions"`
+}
+
+// searchField is a piece of text of a plugin that queries are matched against
+type searchField struct {
+	label  string
+	text   string
+	weight int
+}
+
+// Search returns the plugins of the catalog matching query, best first. The
+// query is matched case insensitively against the names of the plugins and
+// of their commands, given by plugin UUID, and against their descriptions
+// in every language. Matches are substrings, scored higher at the start of
+// the text or of a word; fuzzy matching also accepts the characters of the
+// query in order with gaps, scored higher the closer they are.
+func Search(catalog []Plugin, commands map[string][]PluginCommandConfig, query string, fuzzy bool) []SearchMatch {
+	pattern := []rune(strings.ToLower(strings.TrimSpace(query)))
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	var matches []SearchMatch
+	for _, plugin := range catalog {
+		var best *SearchMatch
+		for _, field := range searchFields(plugin, commands[plugin.UUID]) {
+			quality, positions := matchText(pattern, field.text, fuzzy)
+			if quality == 0 || (best != nil && quality*field.weight <= best.Score) {
+				continue
+			}
+			best = &SearchMatch{
+				Plugin:    plugin.Name,
+				UUID:      plugin.UUID,
+				Score:     quality * field.weight,
+				Field:     field.label,
+				Text:      field.text,
+				Positions: positions,
+			}
+		}
+		if best != nil {
+			matches = append(matches, *best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Plugin < matches[j].Plugin
+	})
+	return matches
+}
+
+// searchFields returns the texts of a plugin and of its commands to search
+func searchFields(plugin Plugin, commands []PluginCommandConfig) []searchField {
+	fields := []searchField{{label: "name", text: plugin.Name, weight: nameWeight}}
+	fields = append(fields, descriptionFields("description", plugin.Description, descriptionWeight)...)
+
+	for _, command := range commands {
+		name := strings.TrimSpace(plugin.Subcommand + " " + command.Name)
+		fields = append(fields, searchField{label: "command " + name, text: name, weight: commandNameWeight})
+		fields = append(fields, descriptionFields("command "+name+" description", command.Description, commandDescriptionWeight)...)
+	}
+	return fields
+}
+
+// descriptionFields returns a field for each language of a description,
+// labelled with the language unless it is English
+func descriptionFields(label string, description i18n.Text, weight int) []searchField {
+	var fields []searchField
+	for _, language := range description.Languages() {
+		fieldLabel := label
+		if language != i18n.DefaultLanguage {
+			fieldLabel += " (" + language + ")"
+		}
+		fields = append(fields, searchField{label: fieldLabel, text: description[language], weight: weight})
+	}
+	return fields
+}
+
+// matchText scores how well the lower case pattern matches text, from 0 for
+// no match to 100 for the whole text, and returns the matching positions
+func matchText(pattern []rune, text string, fuzzy bool) (int, []int) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	if start := indexRunes(lower, pattern); start >= 0 {
+		positions := make([]int, len(pattern))
+		for i := range positions {
+			positions[i] = start + i
+		}
+		switch {
+		case len(pattern) == len(lower):
+			return 100, positions
+		case start == 0:
+			return 90, positions
+		case !unicode.IsLetter(lower[start-1]) && !unicode.IsDigit(lower[start-1]):
+			return 80, positions
+		default:
+			return 70, positions
+		}
+	}
+	if !fuzzy {
+		return 0, nil
+	}
+
+	// The characters of the pattern in order, as close as they come
+	positions := make([]int, 0, len(pattern))
+	for i := 0; i < len(lower) && len(positions) < len(pattern); i++ {
+		if lower[i] == pattern[len(positions)] {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) < len(pattern) {
+		return 0, nil
+	}
+	span := positions[len(positions)-1] - positions[0] + 1
+	return max(1, 60*len(pattern)/span), positions
+}
+
+// indexRunes returns the index of the first occurrence of pattern in text, or -1
+func indexRunes(text, pattern []rune) int {
+	for i := 0; i+len(pattern) <= len(text); i++ {
+		if string(text[i:i+len(pattern)]) == string(pattern) {
+			return i
+		}
+	}
+	return -1
+}
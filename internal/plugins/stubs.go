@@ -0,0 +1,134 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// PluginStubAnnotation marks the stub commands standing for the commands of
+// plugins that are not built yet. It holds the comma separated UUIDs of the
+// plugins to build when the stub is invoked.
+const PluginStubAnnotation = "wpcli/plugin-stub"
+
+// GetCommandStubs returns lightweight commands standing for the plugin
+// commands, built from plugins.yml alone: one for each subcommand group, and
+// one for each command the catalog lists under the commands of a plugin
+// without a group. The configuration of a plugin without a group is only
+// read when the catalog does not list its commands. Replace a stub with the
+// commands of StubPlugins, built with GetPluginCommands, before running it.
+// The names plugins cannot take are returned as collisions, and the plugins
+// involved get a hidden stub named after their UUID. The files are read from
+// cache when it holds them. Descriptions are in the given language.
+func GetCommandStubs(logger *slog.Logger, configPath string, cache *CommandCache, reserved Reserved, language string, settings *Settings) ([]*cobra.Command, []Collision, error) {
+	config, err := cache.readCatalog(logger, configPath, settings.StrictConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	dropUninstalled(config, settings)
+	rootCommands := rootCommandConfigs(logger, config, configPath, cache, settings)
+	collisions := findCollisions(config, rootCommands, reserved)
+
+	taken := make(map[string]bool)
+	for _, name := range reserved.Commands {
+		taken[name] = true
+	}
+	groups := make(map[string]*cobra.Command)
+	var stubs []*cobra.Command
+
+	for _, plugin := range config.Plugins {
+		version, err := settings.SelectVersion(plugin)
+		if err != nil {
+			version = plugin.Versions[0]
+		}
+
+		// The plugins of a group, or of groups nested in the same root level
+		// group, share its stub
+		if path := plugin.GroupPath(); len(path) > 0 {
+			name := path[0]
+			if stub, ok := groups[name]; ok {
+				stub.Annotations[PluginStubAnnotation] += "," + plugin.UUID
+				continue
+			}
+			if taken[name] {
+				continue
+			}
+			stub := newStubCommand(name,
+				fmt.Sprintf("Commands for %s plugins (%s v%s)", name, plugin.Name, version.Version),
+				fmt.Sprintf("Commands for %s plugins (%s)", name, plugin.Name),
+				plugin.UUID)
+			groups[name] = stub
+			taken[name] = true
+			stubs = append(stubs, stub)
+			continue
+		}
+
+		for _, command := range rootCommands[plugin.UUID] {
+			if taken[command.Name] {
+				continue
+			}
+			taken[command.Name] = true
+			description := command.Description.Get(language)
+			stub := newStubCommand(command.Name,
+				fmt.Sprintf("%s (%s v%s)", description, plugin.Name, version.Version),
+				fmt.Sprintf("%s (%s)", description, plugin.Name),
+				plugin.UUID)
+			for _, alias := range command.Aliases {
+				if !taken[alias] {
+					taken[alias] = true
+					stub.Aliases = append(stub.Aliases, alias)
+				}
+			}
+			stubs = append(stubs, stub)
+		}
+	}
+
+	colliding := collidingPlugins(collisions)
+	for _, plugin := range config.Plugins {
+		if !colliding[plugin.UUID] || taken[plugin.UUID] {
+			continue
+		}
+		version, err := settings.SelectVersion(plugin)
+		if err != nil {
+			version = plugin.Versions[0]
+		}
+		taken[plugin.UUID] = true
+		stub := newStubCommand(plugin.UUID,
+			uuidGroupShort(plugin, version),
+			fmt.Sprintf("Commands of %s", plugin.Name),
+			plugin.UUID)
+		stub.Hidden = true
+		stubs = append(stubs, stub)
+	}
+
+	return stubs, collisions, nil
+}
+
+// newStubCommand builds a stub command. It only runs when the commands it
+// stands for could not be built, which was reported as a warning.
+func newStubCommand(name, short, completion, uuid string) *cobra.Command {
+	return &cobra.Command{
+		Use:   name,
+		Short: short,
+		Annotations: map[string]string{
+			PluginStubAnnotation: uuid,
+			CompletionAnnotation: completion,
+		},
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("the plugin commands of %s are not available", cmd.CommandPath())
+		},
+	}
+}
+
+// StubPlugins returns the UUIDs of the plugins a stub command stands for,
+// and nil for other commands
+func StubPlugins(cmd *cobra.Command) []string {
+	uuids, ok := cmd.Annotations[PluginStubAnnotation]
+	if !ok {
+		return nil
+	}
+	return strings.Split(uuids, ",")
+}
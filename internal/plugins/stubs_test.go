@@ -0,0 +1,112 @@
+package plugins
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// countReads replaces readFile for the duration of the test with one
+// counting the files read, by path relative to dir
+func countReads(t *testing.T, dir string) map[string]int {
+	t.Helper()
+	reads := make(map[string]int)
+	original := readFile
+	readFile = func(path string) ([]byte, error) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reads[filepath.ToSlash(rel)]++
+		return original(path)
+	}
+	t.Cleanup(func() { readFile = original })
+	return reads
+}
+
+func writeRegistryFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnlyTheInvokedPluginConfigIsRead(t *testing.T) {
+	dir := t.TempDir()
+	writeRegistryFile(t, filepath.Join(dir, "plugins.yml"), `plugins:
+  - name: alpha-plugin
+    description: The alpha plugin
+    uuid: alpha-uuid
+    commands:
+      - name: alpha
+        description: Run alpha
+    versions:
+      - version: 1.0.0
+        conf: alpha.yml
+  - name: beta-plugin
+    description: The beta plugin
+    uuid: beta-uuid
+    commands:
+      - name: beta
+        description: Run beta
+    versions:
+      - version: 1.0.0
+        conf: beta.yml
+  - name: gamma-plugin
+    description: The gamma plugin
+    uuid: gamma-uuid
+    subcommand: gamma
+    versions:
+      - version: 1.0.0
+        conf: gamma.yml
+`)
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		writeRegistryFile(t, filepath.Join(dir, name+"-uuid", "1.0.0", name+".yml"), `commands:
+  - name: `+name+`
+    description: Run `+name+`
+    usage: wpcli `+name+`
+`)
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	configPath := filepath.Join(dir, "plugins.yml")
+	settings := &Settings{}
+	reads := countReads(t, dir)
+
+	// The stubs are built from the catalog alone
+	stubs, _, err := GetCommandStubs(logger, configPath, nil, Reserved{}, "en", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, stub := range stubs {
+		names = append(names, stub.Name())
+	}
+	if !slices.Equal(names, []string{"alpha", "beta", "gamma"}) {
+		t.Errorf("stubs = %v, want alpha, beta and gamma", names)
+	}
+	want := map[string]int{"plugins.yml": 1}
+	if !maps.Equal(reads, want) {
+		t.Errorf("building the stubs read %v, want %v", reads, want)
+	}
+
+	// Invoking beta reads its configuration, and no other
+	clear(reads)
+	commands, skipped, err := GetPluginCommands(logger, configPath, nil, Reserved{}, "en", settings, "beta-uuid")
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("err = %v, skipped = %v", err, skipped)
+	}
+	if len(commands) != 1 || commands[0].Name() != "beta" {
+		t.Errorf("commands = %v, want beta", commands)
+	}
+	want = map[string]int{"plugins.yml": 1, "beta-uuid/1.0.0/beta.yml": 1}
+	if !maps.Equal(reads, want) {
+		t.Errorf("building beta read %v, want %v", reads, want)
+	}
+}
@@ -0,0 +1,154 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+// Coverage is how much of the text of the plugins of a registry is
+// translated in a language
+type Coverage struct {
+	Language   string
+	Total      int
+	Translated int
+	// Missing names the texts without a translation, such as
+	// "greet-plugin 1.0.0: command greet: flag --formal"
+	Missing []string
+}
+
+// pluginText is a translatable text of a plugin and where it comes from
+type pluginText struct {
+	label string
+	text  i18n.Text
+}
+
+// TranslationCoverage reports, for each language, how many descriptions of
+// the latest version of every plugin in repoPath are translated: those of
+// the plugins, their commands, examples, arguments, flags and flag values.
+// Without languages, every language the registry uses other than English is
+// reported. Plugins whose configuration does not load are left out, as
+// ValidateRegistry reports them.
+func TranslationCoverage(repoPath string, languages []string) ([]Coverage, error) {
+	logger := slog.New(slog.DiscardHandler)
+	catalog, err := readCatalog(logger, filepath.Join(repoPath, "plugins.yml"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []pluginText
+	for _, plugin := range catalog.Plugins {
+		texts = append(texts, pluginText{"plugin " + plugin.Name, plugin.Description})
+		if len(plugin.Versions) == 0 {
+			continue
+		}
+		version := plugin.Versions[0]
+		config, err := loadPluginConfig(logger, filepath.Join(repoPath, plugin.UUID, version.Version, version.Conf), false)
+		if err != nil {
+			continue
+		}
+		texts = append(texts, configTexts(fmt.Sprintf("%s %s", plugin.Name, version.Version), config)...)
+	}
+
+	if len(languages) == 0 {
+		for _, text := range texts {
+			for _, language := range text.text.Languages() {
+				if language != i18n.DefaultLanguage && !slices.Contains(languages, language) {
+					languages = append(languages, language)
+				}
+			}
+		}
+		slices.Sort(languages)
+	}
+
+	var coverage []Coverage
+	for _, language := range languages {
+		if language == i18n.DefaultLanguage {
+			continue
+		}
+		result := Coverage{Language: language, Total: len(texts)}
+		for _, text := range texts {
+			if text.text.Has(language) {
+				result.Translated++
+			} else {
+				result.Missing = append(result.Missing, text.label)
+			}
+		}
+		coverage = append(coverage, result)
+	}
+	return coverage, nil
+}
+
+// DescriptionForms lists the plugins of the catalog in repoPath by the form
+// of their description: a plain string, which is the English text, or a map
+// of languages. Plugins without a description are left out.
+func DescriptionForms(repoPath string) (plain, mapped []string, err error) {
+	data, err := os.ReadFile(filepath.Join(repoPath, "plugins.yml"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read plugins.yml: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse plugins.yml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, nil
+	}
+
+	for _, node := range sequence(mappingValue(doc.Content[0], "plugins")) {
+		name := mappingValue(node, "name")
+		description := mappingValue(node, "description")
+		if name == nil || description == nil {
+			continue
+		}
+		switch description.Kind {
+		case yaml.ScalarNode:
+			plain = append(plain, name.Value)
+		case yaml.MappingNode:
+			mapped = append(mapped, name.Value)
+		}
+	}
+	return plain, mapped, nil
+}
+
+// configTexts lists the descriptions of a plugin configuration
+func configTexts(prefix string, config *Plugin) []pluginText {
+	texts := flagTexts(prefix, config.Flags)
+	for _, command := range config.Commands {
+		commandPrefix := fmt.Sprintf("%s: command %s", prefix, command.Name)
+		texts = append(texts, pluginText{commandPrefix, command.Description})
+		for i, example := range command.Examples {
+			if len(example.Description) > 0 {
+				texts = append(texts, pluginText{fmt.Sprintf("%s: example %d", commandPrefix, i+1), example.Description})
+			}
+		}
+		for _, arg := range command.Args {
+			if len(arg.Description) > 0 {
+				texts = append(texts, pluginText{fmt.Sprintf("%s: argument %s", commandPrefix, arg.Name), arg.Description})
+			}
+		}
+		texts = append(texts, flagTexts(commandPrefix, command.Flags)...)
+	}
+	return texts
+}
+
+// flagTexts lists the descriptions of flags and of their valid values
+func flagTexts(prefix string, flagList []*flags.Flag) []pluginText {
+	var texts []pluginText
+	for _, flag := range flagList {
+		flagPrefix := fmt.Sprintf("%s: flag --%s", prefix, flag.CLIName())
+		texts = append(texts, pluginText{flagPrefix, flag.Description})
+		for _, value := range flag.ValidValues {
+			if description, ok := flag.ValueDescriptions[value]; ok && len(description) > 0 {
+				texts = append(texts, pluginText{fmt.Sprintf("%s: value %s", flagPrefix, value), description})
+			}
+		}
+	}
+	return texts
+}
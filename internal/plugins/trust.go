@@ -0,0 +1,158 @@
+package plugins
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+// Trust levels of repositories and plugins, each selecting a Policy
+const (
+	// TrustFull grants plugins their permissions without asking
+	TrustFull = "full"
+	// TrustStandard asks for the permissions of each plugin version before
+	// it first runs. It is the level of repositories without one.
+	TrustStandard = "standard"
+	// TrustRestricted also refuses network access and modules without a
+	// checksum, and asks before installing modules
+	TrustRestricted = "restricted"
+)
+
+// Policy is how wpcli runs and installs the plugins of a trust level
+type Policy struct {
+	Trust string `json:"trust" yaml:"trust"`
+	// Source is the setting the trust level comes from, repository_trust or
+	// plugin_trust, and empty for the default level
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// AskConsent asks the user to grant the permissions of plugin versions,
+	// see checkConsent. Without it they are granted without asking.
+	AskConsent bool `json:"ask_consent" yaml:"ask_consent"`
+	// AllowNetwork lets plugin versions be granted network hosts
+	AllowNetwork bool `json:"allow_network" yaml:"allow_network"`
+	// RequireChecksums refuses modules without a sha256 checksum
+	RequireChecksums bool `json:"require_checksums" yaml:"require_checksums"`
+	// ConfirmInstall asks before installing modules
+	ConfirmInstall bool `json:"confirm_install" yaml:"confirm_install"`
+}
+
+// policies are the bundled policies of the trust levels
+var policies = map[string]Policy{
+	TrustFull:       {Trust: TrustFull, AllowNetwork: true},
+	TrustStandard:   {Trust: TrustStandard, AskConsent: true, AllowNetwork: true},
+	TrustRestricted: {Trust: TrustRestricted, AskConsent: true, RequireChecksums: true, ConfirmInstall: true},
+}
+
+// checkTrust rejects a trust level without a policy, set by setting for key
+func checkTrust(setting, key, level string) error {
+	if _, ok := policies[level]; ok {
+		return nil
+	}
+	levels := slices.Sorted(maps.Keys(policies))
+	return fmt.Errorf("invalid %s %q for %s, valid levels are: %s", setting, level, key, strings.Join(levels, ", "))
+}
+
+// CheckTrust rejects the repository_trust and plugin_trust settings with
+// unknown trust levels
+func (s *Settings) CheckTrust() error {
+	for _, repository := range slices.Sorted(maps.Keys(s.RepositoryTrust)) {
+		if err := checkTrust("repository_trust", repository, s.RepositoryTrust[repository]); err != nil {
+			return err
+		}
+	}
+	for _, plugin := range slices.Sorted(maps.Keys(s.PluginTrust)) {
+		if err := checkTrust("plugin_trust", plugin, s.PluginTrust[plugin]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Policy returns the policy of a plugin: the one of its trust level in
+// plugin_trust, by UUID or name, else the one of the trust level of the
+// repository in repository_trust, else the standard one. The
+// require_checksums setting applies to every level.
+func (s *Settings) Policy(plugin Plugin) Policy {
+	policy, source := policies[TrustStandard], ""
+	if level, ok := s.RepositoryTrust[s.DefaultRepository]; ok {
+		policy, source = policies[level], "repository_trust"
+	}
+	for _, key := range []string{plugin.Name, plugin.UUID} {
+		if level, ok := s.PluginTrust[key]; ok {
+			policy, source = policies[level], "plugin_trust"
+		}
+	}
+	// Unknown levels are rejected with the settings
+	if policy.Trust == "" {
+		policy = policies[TrustStandard]
+	}
+	policy.Source = source
+	policy.RequireChecksums = policy.RequireChecksums || s.RequireChecksums
+	return policy
+}
+
+// ChecksumRequirement returns what demands modules to have a checksum, or
+// nothing when none does
+func (s *Settings) ChecksumRequirement(policy Policy) string {
+	switch {
+	case s.RequireChecksums:
+		return "require_checksums"
+	case policy.RequireChecksums:
+		return fmt.Sprintf("the %s trust level", policy.Trust)
+	}
+	return ""
+}
+
+// Describe returns the rules of the policy in a sentence
+func (p Policy) Describe() string {
+	var rules []string
+	if p.AskConsent {
+		rules = append(rules, "permissions asked before the first run")
+	} else {
+		rules = append(rules, "permissions granted without asking")
+	}
+	if !p.AllowNetwork {
+		rules = append(rules, "no network access")
+	}
+	if p.RequireChecksums {
+		rules = append(rules, "checksums required")
+	}
+	if p.ConfirmInstall {
+		rules = append(rules, "installs confirmed")
+	}
+	return strings.Join(rules, ", ")
+}
+
+// checkNetwork refuses plugins requesting network hosts when the policy
+// grants none
+func (p Policy) checkNetwork(plugin Plugin) error {
+	if p.AllowNetwork || plugin.Permissions == nil || len(plugin.Permissions.Network) == 0 {
+		return nil
+	}
+	return fmt.Errorf("plugin %s requests network access to %s, which the %s trust level does not grant", plugin.Name, strings.Join(plugin.Permissions.Network, ", "), p.Trust)
+}
+
+// ConfirmInstallation asks the user to confirm the installation of a plugin
+// version when the policy demands it, unless the --yes flag of the root
+// command is set
+func (p Policy) ConfirmInstallation(cmd *cobra.Command, plugin Plugin, version Version) error {
+	if !p.ConfirmInstall || autoApproved(cmd) {
+		return nil
+	}
+	in, ok := interactiveInput(cmd)
+	if !ok {
+		return fmt.Errorf("installing plugins of the %s trust level takes confirmation; run the command in a terminal, or pass --yes to install %s %s", p.Trust, plugin.Name, version.Version)
+	}
+
+	stderr := cmd.ErrOrStderr()
+	fmt.Fprintf(stderr, "Plugin %s has the %s trust level. Install %s? [y/N] ", plugin.Name, p.Trust, version.Version)
+	answer, err := flags.ReadLine(in)
+	fmt.Fprintln(stderr)
+	if err != nil || !isYes(answer) {
+		return fmt.Errorf("installation of plugin %s cancelled", plugin.Name)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package plugins
+
+import "testing"
+
+func TestSettingsPolicy(t *testing.T) {
+	settings := &Settings{
+		DefaultRepository: "https://example.com/registry.git",
+		RepositoryTrust: map[string]string{
+			"https://example.com/registry.git": TrustRestricted,
+			"https://example.com/other.git":    TrustFull,
+		},
+		PluginTrust: map[string]string{
+			"trusted-plugin": TrustFull,
+			"uuid-standard":  TrustStandard,
+		},
+	}
+	tests := []struct {
+		name   string
+		plugin Plugin
+		want   Policy
+	}{
+		{name: "repository trust", plugin: Plugin{Name: "hello", UUID: "uuid-hello"},
+			want: Policy{Trust: TrustRestricted, Source: "repository_trust", AskConsent: true, RequireChecksums: true, ConfirmInstall: true}},
+		{name: "plugin trust by name", plugin: Plugin{Name: "trusted-plugin", UUID: "uuid-trusted"},
+			want: Policy{Trust: TrustFull, Source: "plugin_trust", AllowNetwork: true}},
+		{name: "plugin trust by uuid", plugin: Plugin{Name: "other", UUID: "uuid-standard"},
+			want: Policy{Trust: TrustStandard, Source: "plugin_trust", AskConsent: true, AllowNetwork: true}},
+	}
+	for _, test := range tests {
+		if got := settings.Policy(test.plugin); got != test.want {
+			t.Errorf("%s: policy = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	// Repositories without a trust level are standard, and require_checksums
+	// applies to every level
+	other := &Settings{DefaultRepository: "https://example.com/unknown.git", RequireChecksums: true, PluginTrust: map[string]string{"trusted-plugin": TrustFull}}
+	if got := other.Policy(Plugin{Name: "hello"}); got.Trust != TrustStandard || got.Source != "" || !got.RequireChecksums {
+		t.Errorf("policy without trust level = %+v", got)
+	}
+	if got := other.Policy(Plugin{Name: "trusted-plugin"}); got.Trust != TrustFull || !got.RequireChecksums {
+		t.Errorf("full policy with require_checksums = %+v", got)
+	}
+	if got := other.ChecksumRequirement(other.Policy(Plugin{Name: "hello"})); got != "require_checksums" {
+		t.Errorf("checksum requirement = %q", got)
+	}
+	if got := settings.ChecksumRequirement(settings.Policy(Plugin{Name: "hello"})); got != "the restricted trust level" {
+		t.Errorf("checksum requirement = %q", got)
+	}
+}
+
+func TestSettingsCheckTrust(t *testing.T) {
+	valid := &Settings{RepositoryTrust: map[string]string{"a": TrustFull}, PluginTrust: map[string]string{"b": TrustRestricted}}
+	if err := valid.CheckTrust(); err != nil {
+		t.Errorf("valid levels: %v", err)
+	}
+	invalid := &Settings{RepositoryTrust: map[string]string{"https://example.com/registry.git": "high"}}
+	want := `invalid repository_trust "high" for https://example.com/registry.git, valid levels are: full, restricted, standard`
+	if err := invalid.CheckTrust(); err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %s", err, want)
+	}
+}
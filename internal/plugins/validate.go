@@ -0,0 +1,591 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/ploffredi/wpcli/internal/i18n"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of validation findings. Registries with error findings break
+// plugin commands, warnings point at likely mistakes.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is a problem ValidateRegistry found in a file of the registry
+type Finding struct {
+	// File is relative to the registry, with forward slashes
+	File string
+	// Line is 0 when the problem concerns the whole file
+	Line     int
+	Severity string
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", f.File, f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Severity, f.Message)
+}
+
+// validator collects the findings of a registry
+type validator struct {
+	repoPath string
+	reserved Reserved
+	// strict reports unknown fields as errors instead of warnings
+	strict   bool
+	findings []Finding
+	// logger drops the warnings of the commands built for checking, which
+	// are reported as findings
+	logger *slog.Logger
+}
+
+func (v *validator) report(file string, line int, severity, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{File: file, Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateRegistry checks plugins.yml in repoPath and the configuration of
+// every plugin version it references: required fields, files missing on
+// disk, invalid flags and commands, and names used more than once or taken
+// by wpcli, as listed in reserved. Unknown fields are errors when strict is
+// set. It only fails when plugins.yml cannot be read.
+func ValidateRegistry(repoPath string, reserved Reserved, strict bool) ([]Finding, error) {
+	data, err := os.ReadFile(filepath.Join(repoPath, "plugins.yml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins.yml: %w", err)
+	}
+
+	v := &validator{repoPath: repoPath, reserved: reserved, strict: strict, logger: slog.New(slog.DiscardHandler)}
+	v.validateCatalog(data)
+	return v.findings, nil
+}
+
+// catalogCommand records where a plugin command is defined, to report names
+// used more than once
+type catalogCommand struct {
+	plugin string
+	file   string
+	line   int
+}
+
+func (v *validator) validateCatalog(data []byte) {
+	const file = "plugins.yml"
+
+	root, ok := v.parse(file, data)
+	if !ok {
+		return
+	}
+	config := &PluginConfig{}
+	v.reportUnknownFields(file, root, config)
+	if err := root.Decode(config); err != nil {
+		v.reportYAMLError(file, err)
+		return
+	}
+	if err := checkLayout(config); err != nil {
+		v.report(file, keyLine(root, "layout_version"), SeverityError, "%v", err)
+		return
+	}
+
+	pluginNodes := sequence(mappingValue(root, "plugins"))
+	if len(pluginNodes) == 0 {
+		v.report(file, 0, SeverityWarning, "the catalog has no plugins")
+	}
+
+	uuids := make(map[string]int)
+	names := make(map[string]int)
+	// Root level commands and subcommand groups share the root namespace,
+	// and the commands of a group share the group
+	rootNames := make(map[string]catalogCommand)
+	groups := make(map[string]bool)
+	groupCommands := make(map[string]map[string]catalogCommand)
+	for _, name := range v.reserved.Commands {
+		rootNames[name] = catalogCommand{}
+	}
+
+	for i, node := range pluginNodes {
+		if i >= len(config.Plugins) {
+			break
+		}
+		plugin := config.Plugins[i]
+
+		if plugin.Name == "" {
+			v.report(file, node.Line, SeverityError, "plugin has no name")
+			plugin.Name = plugin.UUID
+			if plugin.Name == "" {
+				plugin.Name = fmt.Sprintf("#%d", i+1)
+			}
+		} else if line, ok := names[plugin.Name]; ok {
+			v.report(file, node.Line, SeverityWarning, "plugin name %s is also used at line %d, so the plugin can only be selected by UUID", plugin.Name, line)
+		} else {
+			names[plugin.Name] = node.Line
+		}
+
+		if plugin.UUID == "" {
+			v.report(file, node.Line, SeverityError, "plugin %s has no uuid", plugin.Name)
+		} else if line, ok := uuids[plugin.UUID]; ok {
+			v.report(file, node.Line, SeverityError, "plugin %s: uuid %s is also used at line %d", plugin.Name, plugin.UUID, line)
+		} else {
+			uuids[plugin.UUID] = node.Line
+		}
+
+		if _, err := ParseMemoryLimit(plugin.MemoryLimit); err != nil {
+			v.report(file, keyLine(node, "memory_limit"), SeverityError, "plugin %s: %v", plugin.Name, err)
+		}
+		if plugin.RequiresWpcli != "" {
+			if _, err := parseConstraint(plugin.RequiresWpcli); err != nil {
+				v.report(file, keyLine(node, "requires_wpcli"), SeverityError, "plugin %s: %v", plugin.Name, err)
+			}
+		}
+
+		if len(plugin.Versions) == 0 {
+			v.report(file, node.Line, SeverityError, "plugin %s has no versions", plugin.Name)
+			continue
+		}
+		if plugin.UUID == "" {
+			continue
+		}
+
+		configs := v.validateVersions(plugin, sequence(mappingValue(node, "versions")))
+
+		// Commands come from the latest version
+		latest := plugin.Versions[0].Version
+		for _, version := range plugin.Versions {
+			if CompareVersions(version.Version, latest) > 0 {
+				latest = version.Version
+			}
+		}
+		latestConfig, ok := configs[latest]
+		if !ok {
+			continue
+		}
+		v.validateCatalogCommands(file, node, plugin, latestConfig)
+
+		// Plugins can share a group, or the groups it is nested in, whose
+		// names share the names of the commands of their parent
+		groupPath := plugin.GroupPath()
+		group := strings.Join(groupPath, " ")
+		conflict := false
+		for i := range groupPath {
+			key := strings.Join(groupPath[:i+1], " ")
+			if groups[key] {
+				continue
+			}
+			names := rootNames
+			if i > 0 {
+				names = groupNames(groupCommands, strings.Join(groupPath[:i], " "))
+			}
+			if other, taken := names[groupPath[i]]; taken {
+				v.reportTaken(file, keyLine(node, "subcommand"), plugin.Name, "subcommand "+key, other)
+				conflict = true
+				break
+			}
+			names[groupPath[i]] = catalogCommand{plugin: plugin.Name, file: file, line: keyLine(node, "subcommand")}
+			groups[key] = true
+		}
+		if conflict {
+			continue
+		}
+		for _, command := range latestConfig.commands {
+			names := rootNames
+			if group != "" {
+				names = groupNames(groupCommands, group)
+			}
+			if other, taken := names[command.name]; taken {
+				v.reportTaken(latestConfig.file, command.line, plugin.Name, "command "+command.name, other)
+				continue
+			}
+			names[command.name] = catalogCommand{plugin: plugin.Name, file: latestConfig.file, line: command.line}
+
+			// Aliases share the names of their command
+			for _, alias := range command.aliases {
+				if other, taken := names[alias]; taken {
+					v.reportTaken(latestConfig.file, command.line, plugin.Name, fmt.Sprintf("alias %s of command %s", alias, command.name), other)
+					continue
+				}
+				names[alias] = catalogCommand{plugin: plugin.Name, file: latestConfig.file, line: command.line}
+			}
+		}
+	}
+}
+
+// groupNames returns the names used in a group, by the path of the group
+func groupNames(groupCommands map[string]map[string]catalogCommand, group string) map[string]catalogCommand {
+	if groupCommands[group] == nil {
+		groupCommands[group] = make(map[string]catalogCommand)
+	}
+	return groupCommands[group]
+}
+
+// reportTaken reports a command or group name already in use
+func (v *validator) reportTaken(file string, line int, plugin, what string, other catalogCommand) {
+	if other.file == "" {
+		v.report(file, line, SeverityError, "plugin %s: %s is reserved by wpcli", plugin, what)
+		return
+	}
+	v.report(file, line, SeverityError, "plugin %s: %s is also defined by %s (%s:%d); the first one wins", plugin, what, other.plugin, other.file, other.line)
+}
+
+// versionConfig is what validateVersions learns from a plugin configuration
+type versionConfig struct {
+	file     string
+	commands []configCommand
+}
+
+type configCommand struct {
+	name    string
+	aliases []string
+	line    int
+}
+
+// validateVersions checks the versions of a plugin and their configurations,
+// returning the configurations that parse by version
+func (v *validator) validateVersions(plugin Plugin, nodes []*yaml.Node) map[string]versionConfig {
+	const file = "plugins.yml"
+
+	configs := make(map[string]versionConfig)
+	seen := make(map[string]int)
+	for i, node := range nodes {
+		if i >= len(plugin.Versions) {
+			break
+		}
+		version := plugin.Versions[i]
+
+		if version.Version == "" {
+			v.report(file, node.Line, SeverityError, "plugin %s: version has no version number", plugin.Name)
+			continue
+		}
+		if line, ok := seen[version.Version]; ok {
+			v.report(file, node.Line, SeverityError, "plugin %s: version %s is also listed at line %d", plugin.Name, version.Version, line)
+			continue
+		}
+		seen[version.Version] = node.Line
+		if _, err := parseSemver(version.Version); err != nil {
+			v.report(file, keyLine(node, "version"), SeverityWarning, "plugin %s: version %s is not a semantic version and sorts as the oldest", plugin.Name, version.Version)
+		}
+
+		if version.Wasm != "" {
+			if _, err := os.Stat(ModulePath(v.repoPath, plugin, version)); err != nil {
+				v.report(file, keyLine(node, "wasm"), SeverityError, "plugin %s %s: module %s does not exist", plugin.Name, version.Version, path.Join(plugin.UUID, version.Version, version.Wasm))
+			}
+		}
+
+		if version.Conf == "" {
+			v.report(file, node.Line, SeverityError, "plugin %s %s has no conf", plugin.Name, version.Version)
+			continue
+		}
+		configFile := path.Join(plugin.UUID, version.Version, version.Conf)
+		data, err := os.ReadFile(filepath.Join(v.repoPath, filepath.FromSlash(configFile)))
+		if err != nil {
+			v.report(file, keyLine(node, "conf"), SeverityError, "plugin %s %s: config %s does not exist", plugin.Name, version.Version, configFile)
+			continue
+		}
+
+		if config, ok := v.validateConfig(plugin, version, configFile, data); ok {
+			configs[version.Version] = config
+		}
+	}
+	return configs
+}
+
+// validateCatalogCommands checks the commands the catalog lists for a plugin
+// against those of its latest configuration, since the catalog list is what
+// wpcli offers before reading the configuration
+func (v *validator) validateCatalogCommands(file string, node *yaml.Node, plugin Plugin, config versionConfig) {
+	if len(plugin.Commands) == 0 {
+		return
+	}
+	if plugin.Subcommand != "" {
+		v.report(file, keyLine(node, "commands"), SeverityWarning, "plugin %s: the commands of plugins with a subcommand are not read from the catalog", plugin.Name)
+		return
+	}
+
+	defined := make(map[string]configCommand)
+	for _, command := range config.commands {
+		defined[command.name] = command
+	}
+	listed := make(map[string]bool)
+	for i, command := range plugin.Commands {
+		listed[command.Name] = true
+		line := keyLine(node, "commands")
+		if items := sequence(mappingValue(node, "commands")); i < len(items) {
+			line = items[i].Line
+		}
+		definition, ok := defined[command.Name]
+		if !ok {
+			v.report(file, line, SeverityError, "plugin %s: command %s is listed in the catalog but not defined in %s", plugin.Name, command.Name, config.file)
+			continue
+		}
+		// The root level names come from the catalog
+		for _, alias := range definition.aliases {
+			if !slices.Contains(command.Aliases, alias) {
+				v.report(file, line, SeverityWarning, "plugin %s: alias %s of command %s is not listed in the catalog, so it cannot be used", plugin.Name, alias, command.Name)
+			}
+		}
+	}
+	for _, command := range config.commands {
+		if !listed[command.name] {
+			v.report(file, keyLine(node, "commands"), SeverityError, "plugin %s: command %s of %s is not listed in the catalog, so it cannot be run", plugin.Name, command.name, config.file)
+		}
+	}
+}
+
+// validateConfig checks the configuration of a plugin version
+func (v *validator) validateConfig(plugin Plugin, version Version, file string, data []byte) (versionConfig, bool) {
+	result := versionConfig{file: file}
+
+	root, ok := v.parse(file, data)
+	if !ok {
+		return result, false
+	}
+	config := &Plugin{}
+	v.reportUnknownFields(file, root, config)
+	if err := root.Decode(config); err != nil {
+		v.reportYAMLError(file, err)
+		return result, false
+	}
+
+	if _, err := ParseMemoryLimit(config.MemoryLimit); err != nil {
+		v.report(file, keyLine(root, "memory_limit"), SeverityError, "%v", err)
+	}
+	if err := checkPayloadVersion(config.PayloadVersion); err != nil {
+		v.report(file, keyLine(root, "payload_version"), SeverityError, "%v", err)
+	}
+	if config.RequiresWpcli != "" {
+		if _, err := parseConstraint(config.RequiresWpcli); err != nil {
+			v.report(file, keyLine(root, "requires_wpcli"), SeverityError, "%v", err)
+		}
+	}
+	v.validatePermissions(file, root, config)
+
+	// Plugin level flags are inherited by every command
+	pluginFlags := config.Flags
+	flagNodes := sequence(mappingValue(root, "flags"))
+	valid := v.validateFlags(file, flagNodes, pluginFlags)
+	for _, flag := range pluginFlags {
+		flag.Persistent = true
+	}
+	if err := checkReservedFlags(plugin, "the plugin", pluginFlags, v.reserved); err != nil {
+		v.report(file, keyLine(root, "flags"), SeverityError, "%v", err)
+		valid = false
+	}
+	if valid {
+		if err := flags.AddFlags(v.logger, &cobra.Command{Use: "validate"}, pluginFlags, i18n.DefaultLanguage); err != nil {
+			v.report(file, keyLine(root, "flags"), SeverityError, "%v", err)
+			valid = false
+		}
+	}
+
+	commandNodes := sequence(mappingValue(root, "commands"))
+	if len(commandNodes) == 0 {
+		v.report(file, 0, SeverityWarning, "the plugin defines no commands")
+	}
+	seen := make(map[string]int)
+	for i, node := range commandNodes {
+		if i >= len(config.Commands) {
+			break
+		}
+		command := config.Commands[i]
+
+		if command.Name == "" {
+			v.report(file, node.Line, SeverityError, "command has no name")
+			continue
+		}
+		if line, ok := seen[command.Name]; ok {
+			v.report(file, node.Line, SeverityError, "command %s is also defined at line %d", command.Name, line)
+			continue
+		}
+		seen[command.Name] = node.Line
+		result.commands = append(result.commands, configCommand{name: command.Name, aliases: command.Aliases, line: node.Line})
+
+		if command.Description.String() == "" {
+			v.report(file, node.Line, SeverityWarning, "command %s has no description", command.Name)
+		}
+
+		args := make(map[string]bool)
+		for j, arg := range command.Args {
+			line := node.Line
+			if items := sequence(mappingValue(node, "args")); j < len(items) {
+				line = items[j].Line
+			}
+			if arg.Name == "" {
+				v.report(file, line, SeverityError, "command %s: argument has no name", command.Name)
+			} else if args[arg.Name] {
+				v.report(file, line, SeverityError, "command %s: argument %s is defined twice", command.Name, arg.Name)
+			}
+			args[arg.Name] = true
+		}
+
+		// The command is built like wpcli builds it once its own flags are
+		// known to be valid, which checks the rest of its configuration
+		if !v.validateFlags(file, sequence(mappingValue(node, "flags")), command.Flags) || !valid {
+			continue
+		}
+		if err := checkReservedFlags(plugin, "command "+command.Name, command.Flags, v.reserved); err != nil {
+			v.report(file, node.Line, SeverityError, "%v", err)
+			continue
+		}
+		if err := checkPluginFlagConflicts(pluginFlags, command); err != nil {
+			v.report(file, node.Line, SeverityError, "%v", err)
+			continue
+		}
+		if _, err := newPluginCommand(v.logger, plugin, version, command, pluginFlags, plugin.Subcommand != "", i18n.DefaultLanguage, &Settings{}); err != nil {
+			v.report(file, node.Line, SeverityError, "command %s: %v", command.Name, err)
+		}
+	}
+
+	return result, true
+}
+
+// validateFlags checks each flag of a list with Flag.Validate, reporting
+// whether they are all valid
+func (v *validator) validateFlags(file string, nodes []*yaml.Node, flagList []*flags.Flag) bool {
+	valid := true
+	for i, flag := range flagList {
+		line := 0
+		if i < len(nodes) {
+			line = nodes[i].Line
+		}
+		if flag == nil {
+			v.report(file, line, SeverityError, "empty flag")
+			valid = false
+			continue
+		}
+		if err := flag.Validate(); err != nil {
+			v.report(file, line, SeverityError, "%v", err)
+			valid = false
+			continue
+		}
+		if flags.ParseFlagType(string(flag.Type)) != flag.Type {
+			v.report(file, line, SeverityWarning, "flag %s has unknown type %s and is treated as a string flag", flag.Name, flag.Type)
+		}
+	}
+	return valid
+}
+
+// parse parses a YAML file into its root node, reporting syntax errors
+func (v *validator) parse(file string, data []byte) (*yaml.Node, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		v.reportYAMLError(file, err)
+		return nil, false
+	}
+	if len(doc.Content) == 0 {
+		v.report(file, 0, SeverityError, "the file is empty")
+		return nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(file, root.Line, SeverityError, "expected a mapping at the top level")
+		return nil, false
+	}
+	return root, true
+}
+
+// reportUnknownFields reports the fields of a file out has no field for
+func (v *validator) reportUnknownFields(file string, root *yaml.Node, out interface{}) {
+	severity := SeverityWarning
+	if v.strict {
+		severity = SeverityError
+	}
+	for _, field := range unknownFields(root, reflect.TypeOf(out)) {
+		v.report(file, field.Line, severity, "unknown field %s", field.Value)
+	}
+}
+
+// reportYAMLError reports a yaml.v3 error at the line it names, reporting
+// each error of a *yaml.TypeError
+func (v *validator) reportYAMLError(file string, err error) {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	for _, message := range messages {
+		line := 0
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			line, _ = strconv.Atoi(match[1])
+			message = match[2]
+		}
+		v.report(file, line, SeverityError, "%s", message)
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line of key in a mapping node, or the line of the
+// node when the key is missing
+func keyLine(node *yaml.Node, key string) int {
+	if node == nil {
+		return 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i].Line
+		}
+	}
+	return node.Line
+}
+
+// sequence returns the items of a sequence node, or nil
+func sequence(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// validatePermissions checks the permissions block of a plugin version, and
+// warns about the environment variables and mounts of its commands the
+// block does not list. They are requested all the same, see
+// RequestedPermissions.
+func (v *validator) validatePermissions(file string, root *yaml.Node, config *Plugin) {
+	if config.Permissions == nil {
+		return
+	}
+	line := keyLine(root, "permissions")
+	if err := checkPermissions(config.Permissions); err != nil {
+		v.report(file, line, SeverityError, "%v", err)
+		return
+	}
+
+	declared := config.Permissions
+	for _, command := range config.Commands {
+		for _, variable := range command.Env {
+			if !slices.Contains(declared.Env, variable.Name) {
+				v.report(file, line, SeverityWarning, "command %s uses the environment variable %s, which permissions does not list", command.Name, variable.Name)
+			}
+		}
+		for _, mount := range command.Mounts {
+			listed := slices.Contains(declared.Write, mount.HostPath) || (mount.ReadOnly && slices.Contains(declared.Read, mount.HostPath))
+			if !listed {
+				v.report(file, line, SeverityWarning, "command %s mounts %s, which permissions does not list", command.Name, mount.HostPath)
+			}
+		}
+	}
+}
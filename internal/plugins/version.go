@@ -0,0 +1,193 @@
+package plugins
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// semver is a parsed semantic version. Build metadata is dropped since it
+// does not take part in the ordering.
+type semver struct {
+	major, minor, patch uint64
+	prerelease          []string
+}
+
+// parseSemver parses a semantic version such as 1.2.3, 1.0.0-rc.1 or
+// 1.0.0+build.5. A leading v is accepted, and so are versions with only a
+// major or major and minor number, such as 1.10.
+func parseSemver(version string) (semver, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	var v semver
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		for _, identifier := range v.prerelease {
+			if identifier == "" {
+				return semver{}, fmt.Errorf("invalid version %s: empty pre-release identifier", version)
+			}
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return semver{}, fmt.Errorf("invalid version %s: too many numbers", version)
+	}
+	numbers := []*uint64{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return semver{}, fmt.Errorf("invalid version %s: '%s' is not a number", version, part)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 when v is lower than, equal to or greater than w
+func (v semver) compare(w semver) int {
+	for _, pair := range [][2]uint64{{v.major, w.major}, {v.minor, w.minor}, {v.patch, w.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A pre-release is lower than the release itself
+	switch {
+	case len(v.prerelease) == 0 && len(w.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(w.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(w.prerelease); i++ {
+		if c := comparePrerelease(v.prerelease[i], w.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(w.prerelease):
+		return -1
+	case len(v.prerelease) > len(w.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares pre-release identifiers: numeric ones
+// numerically and lower than alphanumeric ones, which compare as strings
+func comparePrerelease(a, b string) int {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if x == y {
+			return 0
+		}
+		if x < y {
+			return -1
+		}
+		return 1
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// CompareVersions compares plugin versions semantically, returning -1, 0 or
+// 1 when a is lower than, equal to or greater than b. Versions that are not
+// semantic versions are lower than all the others.
+func CompareVersions(a, b string) int {
+	va, errA := parseSemver(a)
+	vb, errB := parseSemver(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.compare(vb)
+}
+
+// warnedVersions records the invalid versions already reported, since the
+// catalog is sorted more than once per run
+var warnedVersions sync.Map
+
+// SortVersions sorts the versions of a plugin from the latest to the oldest.
+// Versions that are not semantic versions come last, with a warning.
+func SortVersions(logger *slog.Logger, plugin *Plugin) {
+	for _, version := range plugin.Versions {
+		if _, err := parseSemver(version.Version); err != nil {
+			if _, warned := warnedVersions.LoadOrStore(plugin.UUID+"@"+version.Version, true); !warned {
+				logger.Warn("plugin version is not a semantic version, treating it as the oldest", "plugin", plugin.Name, "version", version.Version)
+			}
+		}
+	}
+
+	sort.SliceStable(plugin.Versions, func(i, j int) bool {
+		return CompareVersions(plugin.Versions[i].Version, plugin.Versions[j].Version) > 0
+	})
+}
+
+// sortVersions sorts the versions of every plugin of a parsed plugins.yml
+func sortVersions(logger *slog.Logger, config *PluginConfig) {
+	for i := range config.Plugins {
+		SortVersions(logger, &config.Plugins[i])
+	}
+}
+
+// SelectVersion returns the version of a plugin to use: the one selected in
+// versions, keyed by plugin name or UUID, or else the latest one. Versions
+// must be sorted with SortVersions.
+func SelectVersion(plugin Plugin, versions map[string]string) (Version, error) {
+	requested, ok := versions[plugin.UUID]
+	if !ok {
+		requested, ok = versions[plugin.Name]
+	}
+	if !ok || requested == "" {
+		return plugin.Versions[0], nil
+	}
+
+	available := make([]string, len(plugin.Versions))
+	for i, version := range plugin.Versions {
+		if version.Version == requested {
+			return version, nil
+		}
+		available[i] = version.Version
+	}
+	return Version{}, fmt.Errorf("plugin %s has no version %s, available versions are: %s", plugin.Name, requested, strings.Join(available, ", "))
+}
+
+// SelectVersion returns the version of a plugin commands are built from: the
+// one pinned in PluginVersions, else the installed one while the catalog
+// lists it, else the latest one
+func (s *Settings) SelectVersion(plugin Plugin) (Version, error) {
+	_, pinned := s.PluginVersions[plugin.UUID]
+	if _, ok := s.PluginVersions[plugin.Name]; ok {
+		pinned = true
+	}
+	if installed, ok := s.Installed[plugin.UUID]; ok && !pinned {
+		for _, version := range plugin.Versions {
+			if version.Version == installed {
+				return version, nil
+			}
+		}
+	}
+	return SelectVersion(plugin, s.PluginVersions)
+}
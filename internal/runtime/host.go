@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HostModule is the name of the module of the functions wpcli exports to
+// plugin modules, next to WASI
+const HostModule = "wpcli_host"
+
+// Levels of the log host function. Lower levels are logged as debug
+// messages, and higher ones as errors.
+const (
+	LogDebug = 0
+	LogInfo  = 1
+	LogWarn  = 2
+	LogError = 3
+)
+
+// maxLogMessage caps the length of a logged message, longer ones are cut
+const maxLogMessage = 64 << 10
+
+// instantiateHostModule exports the wpcli host functions to the modules of
+// a runtime:
+//
+//	log(level i32, ptr i32, len i32)
+//
+// log writes the UTF-8 message of len bytes at ptr in the memory of the
+// module to the wpcli log, at the given level. A message out of the bounds
+// of the memory is not logged, and does not stop the module.
+func instantiateHostModule(ctx context.Context, r wazero.Runtime, plugin string, logger *slog.Logger) error {
+	_, err := r.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, m api.Module, stack []uint64) {
+			hostLog(ctx, logger, m, plugin, int32(api.DecodeI32(stack[0])), api.DecodeU32(stack[1]), api.DecodeU32(stack[2]))
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("level", "ptr", "len").
+		Export("log").
+		Instantiate(ctx)
+	return err
+}
+
+// hostLog implements the log host function
+func hostLog(ctx context.Context, logger *slog.Logger, m api.Module, plugin string, level int32, ptr, length uint32) {
+	truncated := length > maxLogMessage
+	if truncated {
+		length = maxLogMessage
+	}
+	var message []byte
+	var ok bool
+	if m.Memory() != nil {
+		message, ok = m.Memory().Read(ptr, length)
+	}
+	if !ok {
+		logger.Warn("ignoring a log message of a plugin module out of the bounds of its memory", "plugin", plugin, "ptr", ptr, "len", length)
+		return
+	}
+
+	attrs := []any{"plugin", plugin}
+	if truncated {
+		attrs = append(attrs, "truncated", true)
+	}
+	logger.Log(ctx, logLevel(level), string(message), attrs...)
+}
+
+// logLevel maps a level of the log host function to a slog level
+func logLevel(level int32) slog.Level {
+	switch {
+	case level <= LogDebug:
+		return slog.LevelDebug
+	case level == LogInfo:
+		return slog.LevelInfo
+	case level == LogWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
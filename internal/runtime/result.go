@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Exit codes of the plugin contract: modules exit with ExitSuccess when the
+// command succeeds, ExitFailure when it fails and ExitUsage when it is run
+// with invalid arguments or flags. wpcli exits with the code of the module.
+const (
+	ExitSuccess = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+// ResultMarker starts the line a module can report its Result with, as a
+// JSON object. It must be the last line of the standard output of the
+// module, and is never shown to the user.
+const ResultMarker = "##wpcli-result##"
+
+// Result is the outcome a module reports on its result line
+type Result struct {
+	// Message is a summary of the outcome for the user
+	Message string `json:"message,omitempty"`
+	// Data holds values for scripts, in any JSON form
+	Data interface{} `json:"data,omitempty"`
+	// Warnings are problems that did not make the command fail
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// resultWriter passes the standard output of a module on, holding back the
+// lines starting with ResultMarker. Lines are written as soon as they can no
+// longer be a result line, so output is not delayed.
+type resultWriter struct {
+	out    io.Writer
+	logger *slog.Logger
+	// line is the start of the current line while it may be a result line
+	line []byte
+	// passing tells that the current line is not a result line and is
+	// being written through
+	passing bool
+	// result is the last result line, without its marker, and misplaced
+	// tells that output followed it
+	result    []byte
+	misplaced bool
+}
+
+func (w *resultWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			chunk = chunk[:i+1]
+		}
+		written += len(chunk)
+		end := chunk[len(chunk)-1] == '\n'
+
+		if !w.passing {
+			w.line = append(w.line, chunk...)
+			switch {
+			case bytes.HasPrefix(w.line, []byte(ResultMarker)):
+				if end {
+					w.setResult(w.line)
+					w.line = nil
+				}
+				continue
+			case !end && bytes.HasPrefix([]byte(ResultMarker), w.line):
+				// Too short to tell yet
+				continue
+			}
+			chunk, w.line = w.line, nil
+			w.passing = !end
+		} else if end {
+			w.passing = false
+		}
+
+		w.followed(chunk)
+		if _, err := w.out.Write(chunk); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// setResult records a result line
+func (w *resultWriter) setResult(line []byte) {
+	w.result = bytes.TrimSpace(bytes.TrimPrefix(line, []byte(ResultMarker)))
+	w.misplaced = false
+}
+
+// followed records output written after a result line, which is then no
+// longer the last line. Blank lines do not count.
+func (w *resultWriter) followed(output []byte) {
+	if w.result != nil && len(bytes.TrimSpace(output)) > 0 {
+		w.misplaced = true
+	}
+}
+
+// Close writes the end of an unterminated last line, and returns the result
+// the module reported, or nil when it reported none. A result line that is
+// not the last line, or is not valid JSON, is dropped with a warning.
+func (w *resultWriter) Close(plugin string) (*Result, error) {
+	if len(w.line) > 0 {
+		if bytes.HasPrefix(w.line, []byte(ResultMarker)) {
+			w.setResult(w.line)
+		} else {
+			w.followed(w.line)
+			if _, err := w.out.Write(w.line); err != nil {
+				return nil, err
+			}
+		}
+		w.line = nil
+	}
+
+	if w.result == nil {
+		return nil, nil
+	}
+	if w.misplaced {
+		w.logger.Warn("ignoring the result line of a plugin module, which is not the last line of its output", "plugin", plugin)
+		return nil, nil
+	}
+	result, err := ParseResult(w.result)
+	if err != nil {
+		w.logger.Warn("ignoring the result line of a plugin module", "plugin", plugin, "error", err)
+		return nil, nil
+	}
+	return result, nil
+}
+
+// ParseResult parses the JSON object of a result line, after its marker
+func ParseResult(data []byte) (*Result, error) {
+	var result Result
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid result: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid result: data after the JSON object")
+	}
+	return &result, nil
+}
@@ -0,0 +1,286 @@
+package runtime
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/ploffredi/wpcli/internal/flags"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Invocation describes a run of a plugin module
+type Invocation struct {
+	// Plugin is the name of the plugin, used in error messages
+	Plugin string
+	// Path is the location of the wasm module
+	Path string
+	// Args are the arguments of the module, starting with the name of the
+	// command being run
+	Args []string
+	// Env holds the environment variables of the module, which sees no
+	// others
+	Env map[string]string
+	// Mounts are the host directories of the module, which sees no other
+	// host files
+	Mounts []Mount
+	// Timeout halts the module when it runs longer, 0 means no limit
+	Timeout time.Duration
+	// MemoryLimit caps the memory of the module in bytes, a multiple of the
+	// 64KiB page size. 0 means no limit.
+	MemoryLimit int64
+	// Stdin, Stdout and Stderr are the standard streams of the module
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Logger receives the warnings of the run and the messages the module
+	// logs, which are dropped when it is nil
+	Logger *slog.Logger
+}
+
+// logger returns the logger of the invocation
+func (inv Invocation) logger() *slog.Logger {
+	if inv.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return inv.Logger
+}
+
+// Mount makes a host directory available to a module as a WASI preopen
+type Mount struct {
+	HostPath  string
+	GuestPath string
+	ReadOnly  bool
+}
+
+// cacheDirKey is the context key of the compiled module cache directory
+type cacheDirKey struct{}
+
+// WithCacheDir returns a context making Run keep compiled modules in dir,
+// which saves compiling them again on the next runs. Entries are keyed by
+// the digest of the module, and wazero keeps the entries of each of its
+// versions apart, so changed modules and wazero upgrades never use stale
+// entries.
+func WithCacheDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, cacheDirKey{}, dir)
+}
+
+// wazeroModule is the module path of the wasm runtime
+const wazeroModule = "github.com/tetratelabs/wazero"
+
+// Version returns the name and version of the wasm runtime wpcli is built
+// with, such as wazero-v1.9.0, which compiled modules are only valid for
+func Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == wazeroModule {
+				return "wazero-" + dep.Version
+			}
+		}
+	}
+	return "wazero-unknown"
+}
+
+// Exit codes of wpcli when a module is halted, the ones used by timeout(1)
+// and shells
+const (
+	TimeoutExitCode   = 124
+	InterruptExitCode = 130
+)
+
+// ExitError reports a module that exited with a non-zero code, which wpcli
+// exits with in turn. Err explains why wpcli halted the module, and is nil
+// when the module exited by itself.
+type ExitError struct {
+	Plugin string
+	Code   int
+	Err    error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("plugin %s exited with code %d", e.Plugin, e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Run compiles and runs a plugin module with wazero until it exits. Modules
+// get the WASI system interface, their arguments, environment variables,
+// standard streams and mounts, but no other host files, and the functions of
+// HostModule. A non-zero exit code is returned as an *ExitError, and so are
+// the timeout and the cancellation of ctx, which halt the module. Output the
+// module wrote until then is kept.
+// The Result the module reports on its last line of output is returned,
+// with the error of a failing module too, and is nil when it reports none.
+func Run(ctx context.Context, inv Invocation) (*Result, error) {
+	stdout := &resultWriter{out: inv.Stdout, logger: inv.logger()}
+	err := run(ctx, inv, stdout)
+	result, closeErr := stdout.Close(inv.Plugin)
+	if err == nil {
+		err = closeErr
+	}
+	return result, err
+}
+
+// run runs a module for Run, with stdout as its standard output
+func run(ctx context.Context, inv Invocation, stdout io.Writer) error {
+	wasm, err := os.ReadFile(inv.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("plugin %s: module %s not found", inv.Plugin, inv.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %s: failed to read module %s: %w", inv.Plugin, inv.Path, err)
+	}
+
+	// The module is closed as soon as ctx is done, even in a busy loop
+	runtimeConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if dir, _ := ctx.Value(cacheDirKey{}).(string); dir != "" {
+		// The module still runs when the cache cannot be used, only slower
+		cache, err := wazero.NewCompilationCacheWithDir(dir)
+		if err != nil {
+			inv.logger().Warn("compiled module cache unavailable", "dir", dir, "error", err)
+		} else {
+			defer cache.Close(context.Background())
+			runtimeConfig = runtimeConfig.WithCompilationCache(cache)
+		}
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	defer r.Close(context.Background())
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	if err := instantiateHostModule(ctx, r, inv.Plugin, inv.logger()); err != nil {
+		return fmt.Errorf("plugin %s: failed to export the host functions: %w", inv.Plugin, err)
+	}
+
+	compiled, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		return fmt.Errorf("plugin %s: failed to compile module %s: %w", inv.Plugin, inv.Path, err)
+	}
+
+	config := wazero.NewModuleConfig().
+		WithArgs(inv.Args...).
+		WithStdin(inv.Stdin).
+		WithStdout(stdout).
+		WithStderr(inv.Stderr).
+		// Modules get the real time and random numbers, since programs
+		// compiled from most languages expect both
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+
+	names := make([]string, 0, len(inv.Env))
+	for name := range inv.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config = config.WithEnv(name, inv.Env[name])
+	}
+
+	fsConfig := wazero.NewFSConfig()
+	for _, mount := range inv.Mounts {
+		if mount.ReadOnly {
+			fsConfig = fsConfig.WithReadOnlyDirMount(mount.HostPath, mount.GuestPath)
+		} else {
+			fsConfig = fsConfig.WithDirMount(mount.HostPath, mount.GuestPath)
+		}
+	}
+	config = config.WithFSConfig(fsConfig)
+
+	// The timeout applies to the run of the module, not to its compilation
+	if inv.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inv.Timeout)
+		defer cancel()
+	}
+
+	// Memory grows through an allocator, which records the module asking for
+	// more than its limit: modules usually report the failure on their own
+	// terms, typically as an out of memory crash
+	var memory *limitedMemory
+	if inv.MemoryLimit > 0 {
+		for _, definition := range compiled.ExportedMemories() {
+			if minimum := uint64(definition.Min()) * pageSize; minimum > uint64(inv.MemoryLimit) {
+				return fmt.Errorf("plugin %s needs %s of memory, more than its limit of %s", inv.Plugin, flags.FormatBytes(int64(minimum)), flags.FormatBytes(inv.MemoryLimit))
+			}
+		}
+		memory = &limitedMemory{limit: uint64(inv.MemoryLimit)}
+		ctx = experimental.WithMemoryAllocator(ctx, memory)
+	}
+
+	_, err = r.InstantiateModule(ctx, compiled, config)
+	if err != nil && memory != nil && memory.exceeded {
+		return fmt.Errorf("plugin %s exceeded memory limit of %s", inv.Plugin, flags.FormatBytes(inv.MemoryLimit))
+	}
+	if err != nil && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &ExitError{Plugin: inv.Plugin, Code: TimeoutExitCode, Err: fmt.Errorf("plugin %s timed out after %s", inv.Plugin, inv.Timeout)}
+		}
+		return &ExitError{Plugin: inv.Plugin, Code: InterruptExitCode, Err: fmt.Errorf("plugin %s was interrupted", inv.Plugin)}
+	}
+
+	var exitErr *sys.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		if exitErr.ExitCode() != 0 {
+			return &ExitError{Plugin: inv.Plugin, Code: int(exitErr.ExitCode())}
+		}
+	case err != nil:
+		return fmt.Errorf("plugin %s: module %s failed: %w", inv.Plugin, inv.Path, err)
+	}
+	return nil
+}
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 << 10
+
+// limitedMemory allocates the linear memory of a module, refusing to grow it
+// beyond its limit
+type limitedMemory struct {
+	limit    uint64
+	buffer   []byte
+	exceeded bool
+}
+
+// Allocate implements experimental.MemoryAllocator
+func (m *limitedMemory) Allocate(capacity, _ uint64) experimental.LinearMemory {
+	m.buffer = make([]byte, 0, min(capacity, m.limit))
+	return m
+}
+
+// Reallocate implements experimental.LinearMemory
+func (m *limitedMemory) Reallocate(size uint64) []byte {
+	if size > m.limit {
+		m.exceeded = true
+		return nil
+	}
+	if size > uint64(cap(m.buffer)) {
+		// Grow geometrically, as append does, within the limit
+		buffer := make([]byte, size, min(max(size, 2*uint64(cap(m.buffer))), m.limit))
+		copy(buffer, m.buffer)
+		m.buffer = buffer
+	}
+	m.buffer = m.buffer[:size]
+	return m.buffer
+}
+
+// Free implements experimental.LinearMemory
+func (m *limitedMemory) Free() {
+	m.buffer = nil
+}
@@ -0,0 +1,134 @@
+// Package scaffold generates the files of new plugins
+package scaffold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Templates of the module source plugin init can generate
+const (
+	TemplateTinyGo = "tinygo"
+	TemplateRust   = "rust"
+	TemplateNone   = "none"
+)
+
+// Templates lists the valid module source templates
+var Templates = []string{TemplateTinyGo, TemplateRust, TemplateNone}
+
+// InitialVersion is the version of generated plugins
+const InitialVersion = "0.1.0"
+
+//go:embed templates
+var templates embed.FS
+
+// validName matches the plugin names that make valid command names, file
+// names and package names
+var validName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Plugin describes the plugin to generate
+type Plugin struct {
+	Name    string
+	UUID    string
+	Version string
+	// Languages are the languages the descriptions are written in, the
+	// default language first
+	Languages []string
+	// Template is the module source template, one of Templates
+	Template string
+}
+
+// Module returns the file name of the module of the plugin, or "" when no
+// module source is generated
+func (p Plugin) Module() string {
+	if p.Template == TemplateNone {
+		return ""
+	}
+	return p.Name + ".wasm"
+}
+
+// file is a generated file, relative to the plugin directory, with the
+// template it is rendered from
+type file struct {
+	path     string
+	template string
+}
+
+// files returns the files generated for a plugin
+func (p Plugin) files() []file {
+	files := []file{
+		{p.Name + ".yml", "plugin.yml.tmpl"},
+		// Not a .yml file, so the directory can be given to --plugin-dir
+		{"plugins.yml.snippet", "catalog.tmpl"},
+	}
+	switch p.Template {
+	case TemplateTinyGo:
+		files = append(files, file{"main.go", "tinygo/main.go.tmpl"}, file{"go.mod", "tinygo/go.mod.tmpl"})
+	case TemplateRust:
+		files = append(files, file{"Cargo.toml", "rust/Cargo.toml.tmpl"}, file{filepath.Join("src", "main.rs"), "rust/main.rs.tmpl"})
+	}
+	return files
+}
+
+// Generate writes the files of a plugin to dir, creating it when missing.
+// Existing files are only overwritten when force is set, and no file is
+// written otherwise. It returns the paths of the files written.
+func Generate(dir string, p Plugin, force bool) ([]string, error) {
+	if !validName.MatchString(p.Name) {
+		return nil, fmt.Errorf("invalid plugin name %q: use lower case letters, digits and dashes, starting with a letter", p.Name)
+	}
+	if !slices.Contains(Templates, p.Template) {
+		return nil, fmt.Errorf("invalid template %s, valid templates are: %s", p.Template, strings.Join(Templates, ", "))
+	}
+
+	// Render every file first, so that nothing is written on errors
+	files := p.files()
+	contents := make([][]byte, len(files))
+	for i, f := range files {
+		tmpl, err := template.ParseFS(templates, "templates/"+f.template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", f.template, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, p); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", f.path, err)
+		}
+		contents[i] = buf.Bytes()
+
+		if _, err := os.Stat(filepath.Join(dir, f.path)); err == nil && !force {
+			return nil, fmt.Errorf("%s already exists, use --force to overwrite it", filepath.Join(dir, f.path))
+		}
+	}
+
+	var written []string
+	for i, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return written, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, contents[i], 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// NewUUID returns a random version 4 UUID for a new plugin
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate a UUID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const fileName = "state.json"
+
+// State holds local bookkeeping persisted between wpcli runs
+type State struct {
+	// LastPull is the time of the last successful repository pull
+	LastPull time.Time `json:"last_pull,omitempty"`
+	// Source is the repository URL the last successful pull used
+	Source string `json:"source,omitempty"`
+	// Installed maps the UUID of each installed plugin to its installed version
+	Installed map[string]string `json:"installed,omitempty"`
+	// InstalledVersions lists every version of each plugin whose module is
+	// installed, by UUID, including the ones kept besides the installed one
+	InstalledVersions map[string][]string `json:"installed_versions,omitempty"`
+
+	path string
+}
+
+// Path returns the location of the state file inside the config directory
+func Path(configDir string) string {
+	return filepath.Join(configDir, fileName)
+}
+
+// Load reads the state file, returning an empty state if it does not exist
+func Load(path string) (*State, error) {
+	s := &State{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Save writes the state back to the file it was loaded from
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// IsInstalled reports whether the module of a plugin version is installed
+func (s *State) IsInstalled(uuid, version string) bool {
+	return slices.Contains(s.InstalledVersions[uuid], version)
+}
+
+// AddInstalled records an installed plugin version, which becomes the
+// installed version of the plugin
+func (s *State) AddInstalled(uuid, version string) {
+	if s.Installed == nil {
+		s.Installed = make(map[string]string)
+	}
+	if s.InstalledVersions == nil {
+		s.InstalledVersions = make(map[string][]string)
+	}
+	s.Installed[uuid] = version
+	// The versions are kept in the order they were installed
+	versions := slices.DeleteFunc(s.InstalledVersions[uuid], func(v string) bool { return v == version })
+	s.InstalledVersions[uuid] = append(versions, version)
+}
+
+// RemoveInstalled forgets an installed plugin version. The plugin is no
+// longer installed once it has no version left; otherwise, when the version
+// was the installed one, the most recently installed version left replaces it.
+func (s *State) RemoveInstalled(uuid, version string) {
+	versions := slices.DeleteFunc(s.InstalledVersions[uuid], func(v string) bool { return v == version })
+	if len(versions) == 0 {
+		delete(s.InstalledVersions, uuid)
+		delete(s.Installed, uuid)
+		return
+	}
+	s.InstalledVersions[uuid] = versions
+	if s.Installed[uuid] == version {
+		s.Installed[uuid] = versions[len(versions)-1]
+	}
+}
@@ -0,0 +1,19 @@
+package version
+
+import "runtime/debug"
+
+// Version is the wpcli version, set when building releases with
+// -ldflags "-X github.com/ploffredi/wpcli/internal/version.Version=1.2.3"
+var Version string
+
+// Current returns the wpcli version: Version when set, else the module
+// version of builds installed with go install, else "dev"
+func Current() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -1,15 +1,43 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/ploffredi/wpcli/cmd"
+	"github.com/ploffredi/wpcli/internal/runtime"
+	"github.com/spf13/cobra"
 )
 
 func main() {
-	if err := cmd.Execute(); err != nil {
-		log.Println(err)
+	// Interrupting wpcli halts the plugin module it runs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Commands are listed in the order they are registered, the builtin
+	// ones first. Cobra only has a process wide setting for it, which is
+	// left to the binary.
+	cobra.EnableCommandSorting = false
+
+	rootCmd := cmd.NewRootCommand(cmd.Dependencies{})
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
+
+		// Plugins report their own errors, wpcli only passes on the exit
+		// code, and explains it when it halted the module
+		var exitErr *runtime.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.Err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(exitErr.Code)
+		}
+
+		// Print the error message and exit with code 1 for any other error
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
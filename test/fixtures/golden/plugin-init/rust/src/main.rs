@@ -0,0 +1,22 @@
+// hello-world is the module of the hello-world wpcli plugin. wpcli runs it with the
+// name of the command, then its arguments, then its flags as --name=value.
+fn main() {
+    let mut greeting = String::from("Hello");
+    let mut shout = false;
+    let mut targets = Vec::new();
+    for arg in std::env::args().skip(1) {
+        if let Some(value) = arg.strip_prefix("--greeting=") {
+            greeting = value.to_string();
+        } else if arg == "--shout=true" {
+            shout = true;
+        } else if !arg.starts_with("--") {
+            targets.push(arg);
+        }
+    }
+
+    let mut message = format!("{}, {}!", greeting, targets.join(" "));
+    if shout {
+        message = message.to_uppercase();
+    }
+    println!("{}", message);
+}
@@ -0,0 +1,36 @@
+//go:build wasip1
+
+// hello-world is the module of the hello-world wpcli plugin. wpcli runs it with the
+// name of the command, then its arguments, then its flags as --name=value.
+//
+// Build it from this directory with:
+//
+//	tinygo build -target=wasip1 -o hello-world.wasm .
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	greeting, shout := "Hello", false
+	var targets []string
+	for _, arg := range os.Args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "--greeting="):
+			greeting = strings.TrimPrefix(arg, "--greeting=")
+		case arg == "--shout=true":
+			shout = true
+		case !strings.HasPrefix(arg, "--"):
+			targets = append(targets, arg)
+		}
+	}
+
+	message := fmt.Sprintf("%s, %s!", greeting, strings.Join(targets, " "))
+	if shout {
+		message = strings.ToUpper(message)
+	}
+	fmt.Println(message)
+}
@@ -0,0 +1,122 @@
+//go:build wasip1
+
+// echo is the wasm module of the echo-plugin fixture. It prints its
+// arguments, one per line, and exits with the code given with --exit-code.
+// The echo-json command prints the invocation read from stdin instead, and
+// echo-env its environment variables, and echo-mounts lists the directories
+// given as arguments and tries to write to them. echo-spin never returns, and
+// echo-grow allocates memory until it crashes. echo-result prints its
+// arguments as lines, such as a result line, the last one unterminated with
+// --no-newline. echo-log logs a message at each level of the wpcli_host log
+// function, then one out of the bounds of its memory.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// hostLog is the log function wpcli exports to modules
+//
+//go:wasmimport wpcli_host log
+func hostLog(level, ptr, length uint32)
+
+// logMessage logs a message with hostLog
+func logMessage(level uint32, message string) {
+	hostLog(level, uint32(uintptr(unsafe.Pointer(unsafe.StringData(message)))), uint32(len(message)))
+}
+
+func main() {
+	if os.Args[0] == "echo-json" {
+		io.Copy(os.Stdout, os.Stdin)
+		fmt.Println()
+		return
+	}
+
+	if os.Args[0] == "echo-env" {
+		for _, variable := range os.Environ() {
+			fmt.Printf("env:%s\n", variable)
+		}
+		return
+	}
+
+	if os.Args[0] == "echo-spin" {
+		fmt.Println("spinning")
+		for {
+		}
+	}
+
+	if os.Args[0] == "echo-grow" {
+		var chunks [][]byte
+		for {
+			chunk := make([]byte, 1<<20)
+			for i := range chunk {
+				chunk[i] = 1
+			}
+			chunks = append(chunks, chunk)
+			fmt.Printf("allocated %dMiB\n", len(chunks))
+		}
+	}
+
+	if os.Args[0] == "echo-result" {
+		var lines []string
+		newline := "\n"
+		code := 0
+		for _, arg := range os.Args[1:] {
+			switch {
+			case arg == "--no-newline=true":
+				newline = ""
+			case strings.HasPrefix(arg, "--exit-code="):
+				code, _ = strconv.Atoi(strings.TrimPrefix(arg, "--exit-code="))
+			case !strings.HasPrefix(arg, "--"):
+				lines = append(lines, arg)
+			}
+		}
+		fmt.Print(strings.Join(lines, "\n") + newline)
+		os.Exit(code)
+	}
+
+	if os.Args[0] == "echo-log" {
+		for level, name := range []string{"debug", "info", "warn", "error"} {
+			logMessage(uint32(level), "echo: "+name+" message")
+		}
+		hostLog(1, 0xfffffff0, 64)
+		fmt.Println("logged")
+		return
+	}
+
+	if os.Args[0] == "echo-mounts" {
+		for _, dir := range os.Args[1:] {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				fmt.Printf("ls:%s=%v\n", dir, err)
+				continue
+			}
+			for _, entry := range entries {
+				fmt.Printf("ls:%s/%s\n", dir, entry.Name())
+			}
+			if err := os.WriteFile(dir+"/written.txt", []byte("written\n"), 0o644); err != nil {
+				fmt.Printf("write:%s=denied\n", dir)
+			} else {
+				fmt.Printf("write:%s=ok\n", dir)
+			}
+		}
+		return
+	}
+
+	for i, arg := range os.Args {
+		fmt.Printf("argv[%d]=%s\n", i, arg)
+	}
+	fmt.Fprintln(os.Stderr, "echo: done")
+
+	for _, arg := range os.Args[1:] {
+		if value, ok := strings.CutPrefix(arg, "--exit-code="); ok {
+			code, _ := strconv.Atoi(value)
+			os.Exit(code)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Command server serves a directory over HTTP for the end-to-end tests of
+// downloads. It listens on a free local port, prints its base URL and serves
+// until it is killed. Range requests are supported, so downloads can resume.
+//
+// With -git, the git repositories in the directory are served instead,
+// through the smart HTTP protocol of git http-backend, so clones and pulls
+// can be tested without network access.
+//
+// With -fail N, the connections of the first N requests are closed without a
+// response, like a flaky network, to test retries.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+func main() {
+	serveGit := flag.Bool("git", false, "serve the git repositories of the directory")
+	failures := flag.Int("fail", 0, "close the connections of the first `N` requests")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: server [-git] [-fail N] <dir>")
+		os.Exit(2)
+	}
+	dir := flag.Arg(0)
+
+	handler := http.FileServer(http.Dir(dir))
+	if *serveGit {
+		gitHandler, err := gitBackend(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		handler = gitHandler
+	}
+	if *failures > 0 {
+		handler = &flaky{handler: handler, failures: *failures}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("http://%s\n", listener.Addr())
+
+	if err := http.Serve(listener, handler); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// gitBackend runs git http-backend for the repositories of dir
+func gitBackend(dir string) (http.Handler, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cgi.Handler{
+		Path: git,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+		InheritEnv: []string{"PATH", "HOME"},
+	}, nil
+}
+
+// flaky closes the connections of its first requests and serves the others
+type flaky struct {
+	handler  http.Handler
+	mu       sync.Mutex
+	failures int
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	fail := f.failures > 0
+	if fail {
+		f.failures--
+	}
+	f.mu.Unlock()
+
+	if !fail {
+		f.handler.ServeHTTP(w, r)
+		return
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	conn.Close()
+}
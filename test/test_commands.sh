@@ -87,9 +87,15 @@ run_test "Show help for pkg install command" "$WPCLI pkg install --help"
 run_test "Show help for greet command" "$WPCLI greet --help"
 run_test "Show help for pkg command" "$WPCLI pkg --help"
 run_test "Show general help" "$WPCLI --help"
+run_test "Show help for a plugin command through help" "$WPCLI help greet"
+
+# Test completion
+run_test "Complete commands with the plugin they come from" "$WPCLI __complete ''"
+run_test "Complete the commands of a group" "$WPCLI __complete pkg ''"
 
 # Test invalid commands
 run_test "Invalid command" "$WPCLI invalid-command" 1
+run_test "Misspelled command suggests the closest match" "$WPCLI gret" 1
 run_test "Invalid subcommand" "$WPCLI pkg invalid-subcommand" 0
 
 echo "All tests completed!"